@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/bradford-hamilton/monkey-lang/token"
@@ -155,6 +156,28 @@ func TestConstStatement(t *testing.T) {
 	}
 }
 
+func TestEnumStatement(t *testing.T) {
+	es := &EnumStatement{
+		Token: token.Token{Type: token.Enum, Literal: "enum"},
+		Name: &Identifier{
+			Token: token.Token{Type: token.Identifier, Literal: "Color"},
+			Value: "Color",
+		},
+		Variants: []*Identifier{
+			{Token: token.Token{Type: token.Identifier, Literal: "Red"}, Value: "Red"},
+			{Token: token.Token{Type: token.Identifier, Literal: "Green"}, Value: "Green"},
+		},
+	}
+
+	if es.TokenLiteral() != "enum" {
+		t.Errorf("Wrong TokenLiteral for EnumStatement. Expected: 'enum'. Got: %s", es.TokenLiteral())
+	}
+
+	if es.String() != "enum Color { Red, Green }" {
+		t.Errorf("Wrong String representation for EnumStatement. Expected: 'enum Color { Red, Green }'. Got: %s", es.String())
+	}
+}
+
 func TestExpressionStatement(t *testing.T) {
 	es := &ExpressionStatement{
 		Token: token.Token{Type: token.Integer, Literal: "1000"},
@@ -459,6 +482,24 @@ func TestReturnStatement(t *testing.T) {
 	}
 }
 
+func TestYieldStatement(t *testing.T) {
+	ys := &YieldStatement{
+		Token: token.Token{Type: token.Yield, Literal: "yield"},
+		Value: &IntegerLiteral{
+			Token: token.Token{Type: token.Integer, Literal: "42"},
+			Value: 42,
+		},
+	}
+
+	if ys.TokenLiteral() != "yield" {
+		t.Errorf("Wrong TokenLiteral for YieldStatement. Expected: 'yield'. Got: %s", ys.TokenLiteral())
+	}
+
+	if ys.String() != "yield 42;" {
+		t.Errorf("Wrong String representation for YieldStatement. Expected: 'yield 42;'. Got: %s", ys.String())
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	sl := &StringLiteral{
 		Token: token.Token{Type: token.String, Literal: "this string is so literal"},
@@ -473,3 +514,231 @@ func TestStringLiteral(t *testing.T) {
 		t.Errorf("Wrong String representation for StringLiteral. Expected: 'this string is so literal'. Got: %s", sl.String())
 	}
 }
+
+func TestSpawnExpression(t *testing.T) {
+	se := &SpawnExpression{
+		Token: token.Token{Type: token.Spawn, Literal: "spawn"},
+		Call: &CallExpression{
+			Token: token.Token{Type: token.LeftParen, Literal: "("},
+			Function: &Identifier{
+				Token: token.Token{Type: token.Identifier, Literal: "work"},
+				Value: "work",
+			},
+			Arguments: []Expression{
+				&IntegerLiteral{
+					Token: token.Token{Type: token.Integer, Literal: "1"},
+					Value: 1,
+				},
+			},
+		},
+	}
+
+	if se.TokenLiteral() != "spawn" {
+		t.Errorf("Wrong TokenLiteral for SpawnExpression. Expected: 'spawn'. Got: %s", se.TokenLiteral())
+	}
+
+	if se.String() != "spawn work(1)" {
+		t.Errorf("Wrong String representation for SpawnExpression. Expected: 'spawn work(1)'. Got: %s", se.String())
+	}
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+
+		if integer.Value != 1 {
+			return node
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&RootNode{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&RootNode{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&IfExpression{
+				Condition: one(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+			},
+			&IfExpression{
+				Condition: two(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+			},
+		},
+		{&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		{&LetStatement{Value: one()}, &LetStatement{Value: two()}},
+		{&ConstStatement{Value: one()}, &ConstStatement{Value: two()}},
+		{&YieldStatement{Value: one()}, &YieldStatement{Value: two()}},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+			},
+		},
+		{
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), one()}},
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two(), two()}},
+		},
+		{
+			&SpawnExpression{Call: &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one()}}},
+			&SpawnExpression{Call: &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two()}}},
+		},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+	}
+
+	for _, tt := range tests {
+		modified, err := Modify(tt.input, turnOneIntoTwo)
+		if err != nil {
+			t.Fatalf("Modify returned an error: %s", err)
+		}
+
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("Not equal. Got: %#v. Expected: %#v", modified, tt.expected)
+		}
+	}
+
+	hashLiteral := &HashLiteral{
+		Pairs: map[Expression]Expression{
+			one(): one(),
+			one(): one(),
+		},
+	}
+
+	if _, err := Modify(hashLiteral, turnOneIntoTwo); err != nil {
+		t.Fatalf("Modify returned an error: %s", err)
+	}
+
+	for key, val := range hashLiteral.Pairs {
+		key, _ := key.(*IntegerLiteral)
+		if key.Value != 2 {
+			t.Errorf("value is not %d. Got: %d", 2, key.Value)
+		}
+		val, _ := val.(*IntegerLiteral)
+		if val.Value != 2 {
+			t.Errorf("value is not %d. Got: %d", 2, val.Value)
+		}
+	}
+}
+
+func TestModifyErrors(t *testing.T) {
+	call := func() *CallExpression {
+		return &CallExpression{Token: token.Token{Literal: "("}, Function: &Identifier{Value: "f"}, Arguments: []Expression{}}
+	}
+	replaceCall := func(replacement Node) ModifierFunc {
+		return func(node Node) Node {
+			if _, ok := node.(*CallExpression); ok {
+				return replacement
+			}
+			return node
+		}
+	}
+	one := &IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1}
+	block := &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: call()}}}
+
+	tests := []struct {
+		input    Node
+		modifier ModifierFunc
+		expected string
+	}{
+		{&SpawnExpression{Call: call()}, replaceCall(one), "cannot replace f() with 1: expected a call"},
+		{&ExpressionStatement{Expression: call()}, replaceCall(&ReturnStatement{Token: token.Token{Literal: "return"}, ReturnValue: one}), "cannot replace f() with return 1;: expected an expression"},
+		{&ArrayLiteral{Elements: []Expression{call()}}, replaceCall(nil), "cannot replace f() with nothing: expected an expression"},
+		{
+			&IfExpression{Condition: one, Consequence: block},
+			func(node Node) Node {
+				if node == block {
+					return one
+				}
+				return node
+			},
+			"cannot replace f() with 1: expected a block",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Modify(tt.input, tt.modifier)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Modify returned wrong error. Expected: %q. Got: %v", tt.expected, err)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1} }
+	call := &CallExpression{Token: token.Token{Literal: "("}, Function: &Identifier{Token: token.Token{Literal: "f"}, Value: "f"}, Arguments: []Expression{one()}}
+	original := &RootNode{Statements: []Statement{
+		&ExpressionStatement{Expression: &IfExpression{
+			Condition:   one(),
+			Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: call}}},
+		}},
+		&ExpressionStatement{Expression: &SpawnExpression{Call: call}},
+		&ExpressionStatement{Expression: &HashLiteral{Pairs: map[Expression]Expression{one(): &ArrayLiteral{Elements: []Expression{one()}}}}},
+	}}
+	before := original.String()
+
+	copied := Copy(original)
+	if !reflect.DeepEqual(copied, original) {
+		t.Fatalf("Copy returned a different tree. Got: %s", copied.String())
+	}
+
+	// Changing the copy leaves the original as it was
+	if _, err := Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			return &IntegerLiteral{Token: token.Token{Literal: "2"}, Value: integer.Value + 1}
+		}
+		return node
+	}); err != nil {
+		t.Fatalf("Modify returned an error: %s", err)
+	}
+	MarkTailCalls(copied)
+	if original.String() != before || call.IsTailCall {
+		t.Errorf("Changing a copy changed the original. Got: %s", original.String())
+	}
+	if copied.String() == before {
+		t.Errorf("Copy wasn't changed. Got: %s", copied.String())
+	}
+}
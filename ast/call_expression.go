@@ -8,11 +8,13 @@ import (
 )
 
 // CallExpression - holds the token, the function expression, and its arguments ([]Expression).
-// Structure: <expression>(<comma separated expressions>)
+// Structure: <expression>(<comma separated expressions>). IsTailCall is set by MarkTailCalls
+// when the enclosing function returns the call's result directly
 type CallExpression struct {
-	Token     token.Token // The '(' token
-	Function  Expression
-	Arguments []Expression
+	Token      token.Token // The '(' token
+	Function   Expression
+	Arguments  []Expression
+	IsTailCall bool
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -0,0 +1,149 @@
+package ast
+
+// Copy returns a copy of the tree rooted at node that can be changed, for example by Modify or
+// MarkTailCalls, without changing node. Nodes without children (identifiers, literals and the
+// like) are never changed in place, so the copy shares them
+func Copy(node Node) Node {
+	switch node := node.(type) {
+
+	case *RootNode:
+		c := *node
+		c.Statements = copyStatements(node.Statements)
+		return &c
+
+	case *ExpressionStatement:
+		c := *node
+		c.Expression = copyExpression(node.Expression)
+		return &c
+
+	case *BlockStatement:
+		return copyBlock(node)
+
+	case *ReturnStatement:
+		c := *node
+		c.ReturnValue = copyExpression(node.ReturnValue)
+		return &c
+
+	case *LetStatement:
+		c := *node
+		c.Value = copyExpression(node.Value)
+		return &c
+
+	case *ConstStatement:
+		c := *node
+		c.Value = copyExpression(node.Value)
+		return &c
+
+	case *YieldStatement:
+		c := *node
+		c.Value = copyExpression(node.Value)
+		return &c
+
+	case *InfixExpression:
+		c := *node
+		c.Left = copyExpression(node.Left)
+		c.Right = copyExpression(node.Right)
+		return &c
+
+	case *PrefixExpression:
+		c := *node
+		c.Right = copyExpression(node.Right)
+		return &c
+
+	case *IndexExpression:
+		c := *node
+		c.Left = copyExpression(node.Left)
+		c.Index = copyExpression(node.Index)
+		return &c
+
+	case *IfExpression:
+		c := *node
+		c.Condition = copyExpression(node.Condition)
+		c.Consequence = copyBlock(node.Consequence)
+		c.Alternative = copyBlock(node.Alternative)
+		return &c
+
+	case *FunctionLiteral:
+		c := *node
+		c.Body = copyBlock(node.Body)
+		return &c
+
+	case *MacroLiteral:
+		c := *node
+		c.Body = copyBlock(node.Body)
+		return &c
+
+	case *CallExpression:
+		return copyCall(node)
+
+	case *SpawnExpression:
+		c := *node
+		c.Call = copyCall(node.Call)
+		return &c
+
+	case *ArrayLiteral:
+		c := *node
+		c.Elements = copyExpressions(node.Elements)
+		return &c
+
+	case *HashLiteral:
+		c := *node
+		c.Pairs = make(map[Expression]Expression, len(node.Pairs))
+		for key, val := range node.Pairs {
+			c.Pairs[copyExpression(key)] = copyExpression(val)
+		}
+		return &c
+	}
+
+	return node
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	return Copy(exp).(Expression)
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	copied := make([]Expression, len(exps))
+	for i, exp := range exps {
+		copied[i] = copyExpression(exp)
+	}
+	return copied
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	copied := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		if stmt != nil {
+			copied[i] = Copy(stmt).(Statement)
+		}
+	}
+	return copied
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	c := *block
+	c.Statements = copyStatements(block.Statements)
+	return &c
+}
+
+func copyCall(call *CallExpression) *CallExpression {
+	if call == nil {
+		return nil
+	}
+	c := *call
+	c.Function = copyExpression(call.Function)
+	c.Arguments = copyExpressions(call.Arguments)
+	return &c
+}
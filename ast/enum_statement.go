@@ -0,0 +1,39 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/bradford-hamilton/monkey-lang/token"
+)
+
+// EnumStatement - Name holds the identifier the enum is bound to and Variants holds the
+// identifiers of each of its variants, in declaration order: enum Color { Red, Green }
+type EnumStatement struct {
+	Token    token.Token // The token.Enum token
+	Name     *Identifier
+	Variants []*Identifier
+}
+
+func (es *EnumStatement) statementNode() {}
+
+// TokenLiteral returns the EnumStatement's Literal and satisfies the Node interface.
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+
+// String - returns a string representation of the EnumStatement and satisfies our Node interface
+func (es *EnumStatement) String() string {
+	var out bytes.Buffer
+
+	variants := []string{}
+	for _, v := range es.Variants {
+		variants = append(variants, v.String())
+	}
+
+	out.WriteString(es.TokenLiteral() + " ")
+	out.WriteString(es.Name.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(variants, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
@@ -0,0 +1,17 @@
+package ast
+
+import "github.com/bradford-hamilton/monkey-lang/token"
+
+// FloatLiteral - holds the token and it's value (float64)
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+// TokenLiteral returns the FloatLiteral's Literal and satisfies the Node interface.
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+
+// String - returns a string representation of the FloatLiteral and satisfies our Node interface
+func (fl *FloatLiteral) String() string { return fl.Token.Literal }
@@ -9,12 +9,14 @@ import (
 )
 
 // FunctionLiteral - holds the token, the function params (a slice of *Identifier), and
-// the function Body (*BlockStatement). Structure: func <parameters> <block statement>
+// the function Body (*BlockStatement). Structure: func <parameters> <block statement>.
+// IsGenerator is set when the body (not counting nested functions) contains a yield
 type FunctionLiteral struct {
-	Token      token.Token // The 'func' token
-	Parameters []*Identifier
-	Body       *BlockStatement
-	Name       string
+	Token       token.Token // The 'func' token
+	Parameters  []*Identifier
+	Body        *BlockStatement
+	Name        string
+	IsGenerator bool
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
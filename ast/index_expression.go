@@ -6,9 +6,10 @@ import (
 	"github.com/bradford-hamilton/monkey-lang/token"
 )
 
-// IndexExpression - holds the '[' token, the object being accessed, and the index
+// IndexExpression - holds the '[' token, the object being accessed, and the index. Member
+// access (Color.Red) is parsed into an IndexExpression holding the '.' token and a string index
 type IndexExpression struct {
-	Token token.Token // The '[' token
+	Token token.Token // The '[' or '.' token
 	Left  Expression  // The object being accessed
 	Index Expression  // Can be any expression, but must produce an integer
 }
@@ -18,11 +19,21 @@ func (ie *IndexExpression) expressionNode() {}
 // TokenLiteral returns the IndexExpression's Literal and satisfies the Node interface.
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
 
-// String - returns string representation of the IndexExpression: (leftExpr[indexExpr]).
-// Satisfies our Node interface
+// String - returns string representation of the IndexExpression: (leftExpr[indexExpr]) or
+// (leftExpr.member) for member access. Satisfies our Node interface
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
+	if ie.Token.Type == token.Dot {
+		out.WriteString("(")
+		out.WriteString(ie.Left.String())
+		out.WriteString(".")
+		out.WriteString(ie.Index.String())
+		out.WriteString(")")
+
+		return out.String()
+	}
+
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
 	out.WriteString("[")
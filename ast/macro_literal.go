@@ -0,0 +1,40 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/bradford-hamilton/monkey-lang/token"
+)
+
+// MacroLiteral - holds the token, the macro params (a slice of *Identifier), and the macro
+// Body (*BlockStatement). Structure: macro <parameters> <block statement>
+type MacroLiteral struct {
+	Token      token.Token // The 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode() {}
+
+// TokenLiteral returns the MacroLiteral's Literal and satisfies the Node interface.
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+
+// String - returns a string representation of the MacroLiteral. Prints it's token,
+// params, and body. Satisfies our Node interface
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
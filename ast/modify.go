@@ -0,0 +1,162 @@
+package ast
+
+import "fmt"
+
+// ModifierFunc is called by Modify with every node in the tree and returns the node to put in
+// its place. Returning the node it was given leaves the tree unchanged
+type ModifierFunc func(Node) Node
+
+// Modify walks the tree rooted at node depth first, replacing every child with the result of
+// calling modifier on it, and finally returns modifier(node). Children are replaced in place, so
+// the tree passed in is changed. Identifiers that only name things (let/const/enum names, function
+// and macro parameters) are not visited since they must stay identifiers. It stops with an error
+// when modifier returns a node that can't take the place of the one it was given, such as a
+// statement where an expression goes or anything but a call after `spawn`
+func Modify(node Node, modifier ModifierFunc) (Node, error) {
+	m := &modification{modifier: modifier}
+	node = m.modify(node)
+	return node, m.err
+}
+
+// modification is a call to Modify, which stops at the first error
+type modification struct {
+	modifier ModifierFunc
+	err      error
+}
+
+func (m *modification) modify(node Node) Node {
+	switch node := node.(type) {
+
+	case *RootNode:
+		for i := range node.Statements {
+			node.Statements[i] = m.statement(node.Statements[i])
+		}
+
+	case *ExpressionStatement:
+		node.Expression = m.expression(node.Expression)
+
+	case *BlockStatement:
+		for i := range node.Statements {
+			node.Statements[i] = m.statement(node.Statements[i])
+		}
+
+	case *ReturnStatement:
+		node.ReturnValue = m.expression(node.ReturnValue)
+
+	case *LetStatement:
+		node.Value = m.expression(node.Value)
+
+	case *ConstStatement:
+		node.Value = m.expression(node.Value)
+
+	case *YieldStatement:
+		node.Value = m.expression(node.Value)
+
+	case *InfixExpression:
+		node.Left = m.expression(node.Left)
+		node.Right = m.expression(node.Right)
+
+	case *PrefixExpression:
+		node.Right = m.expression(node.Right)
+
+	case *IndexExpression:
+		node.Left = m.expression(node.Left)
+		node.Index = m.expression(node.Index)
+
+	case *IfExpression:
+		node.Condition = m.expression(node.Condition)
+		node.Consequence = m.block(node.Consequence)
+		if node.Alternative != nil {
+			node.Alternative = m.block(node.Alternative)
+		}
+
+	case *FunctionLiteral:
+		node.Body = m.block(node.Body)
+
+	case *MacroLiteral:
+		node.Body = m.block(node.Body)
+
+	case *CallExpression:
+		node.Function = m.expression(node.Function)
+		for i := range node.Arguments {
+			node.Arguments[i] = m.expression(node.Arguments[i])
+		}
+
+	case *SpawnExpression:
+		result := m.modify(node.Call)
+		if call, ok := result.(*CallExpression); m.replace(node.Call, result, ok, "a call") {
+			node.Call = call
+		}
+
+	case *ArrayLiteral:
+		for i := range node.Elements {
+			node.Elements[i] = m.expression(node.Elements[i])
+		}
+
+	case *HashLiteral:
+		newPairs := make(map[Expression]Expression)
+		for key, val := range node.Pairs {
+			newPairs[m.expression(key)] = m.expression(val)
+		}
+		node.Pairs = newPairs
+	}
+
+	if m.err != nil {
+		return node
+	}
+	return m.modifier(node)
+}
+
+// expression modifies the expression exp, returning it unchanged when that fails
+func (m *modification) expression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	result := m.modify(exp)
+	modified, ok := result.(Expression)
+	if !m.replace(exp, result, ok, "an expression") {
+		return exp
+	}
+	return modified
+}
+
+// statement modifies the statement stmt, returning it unchanged when that fails
+func (m *modification) statement(stmt Statement) Statement {
+	if stmt == nil {
+		return nil
+	}
+	result := m.modify(stmt)
+	modified, ok := result.(Statement)
+	if !m.replace(stmt, result, ok, "a statement") {
+		return stmt
+	}
+	return modified
+}
+
+// block modifies the block statement block, returning it unchanged when that fails
+func (m *modification) block(block *BlockStatement) *BlockStatement {
+	result := m.modify(block)
+	modified, ok := result.(*BlockStatement)
+	if !m.replace(block, result, ok, "a block") {
+		return block
+	}
+	return modified
+}
+
+// replace reports whether result, what modifying old returned, can take its place. ok is
+// whether result is the kind of node described by kind
+func (m *modification) replace(old, result Node, ok bool, kind string) bool {
+	if m.err != nil {
+		return false
+	}
+	if ok {
+		return true
+	}
+
+	got := "nothing"
+	if result != nil {
+		got = result.String()
+	}
+	m.err = fmt.Errorf("cannot replace %s with %s: expected %s", old.String(), got, kind)
+	return false
+}
@@ -0,0 +1,22 @@
+package ast
+
+import (
+	"regexp"
+
+	"github.com/bradford-hamilton/monkey-lang/token"
+)
+
+// RegexLiteral - holds the token, the pattern it was written with and the compiled regular expression
+type RegexLiteral struct {
+	Token token.Token
+	Value string
+	Regex *regexp.Regexp
+}
+
+func (rl *RegexLiteral) expressionNode() {}
+
+// TokenLiteral returns the RegexLiteral's Literal (the pattern) and satisfies the Node interface.
+func (rl *RegexLiteral) TokenLiteral() string { return rl.Token.Literal }
+
+// String - returns a string representation of the RegexLiteral and satisfies our Node interface
+func (rl *RegexLiteral) String() string { return "re\"" + rl.Value + "\"" }
@@ -0,0 +1,21 @@
+package ast
+
+import (
+	"github.com/bradford-hamilton/monkey-lang/token"
+)
+
+// SpawnExpression - holds the SPAWN token and the call to run concurrently: spawn worker(1, 2)
+type SpawnExpression struct {
+	Token token.Token // The 'spawn' token
+	Call  *CallExpression
+}
+
+func (se *SpawnExpression) expressionNode() {}
+
+// TokenLiteral returns the SpawnExpression's Literal and satisfies the Node interface.
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+
+// String - returns a string representation of the SpawnExpression and satisfies our Node interface
+func (se *SpawnExpression) String() string {
+	return se.TokenLiteral() + " " + se.Call.String()
+}
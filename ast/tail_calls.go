@@ -0,0 +1,95 @@
+package ast
+
+// MarkTailCalls sets IsTailCall on every call in the tree that is in tail position and clears
+// it on every other call. A call is in tail position when the function it's in returns its
+// result directly: it's the value of a return statement, or the last expression statement of
+// the function body, looking through the branches of an if expression in either place. The
+// engines use it to make calls in tail position without growing the call stack
+func MarkTailCalls(node Node) {
+	markTailCalls(node, false, false)
+}
+
+func markTailCalls(node Node, inFunction, tail bool) {
+	switch node := node.(type) {
+
+	case *RootNode:
+		for _, s := range node.Statements {
+			markTailCalls(s, false, false)
+		}
+
+	case *BlockStatement:
+		markBlockTailCalls(node, inFunction, tail)
+
+	case *ExpressionStatement:
+		markTailCalls(node.Expression, inFunction, tail)
+
+	case *ReturnStatement:
+		markTailCalls(node.ReturnValue, inFunction, inFunction)
+
+	case *LetStatement:
+		markTailCalls(node.Value, inFunction, false)
+
+	case *ConstStatement:
+		markTailCalls(node.Value, inFunction, false)
+
+	case *YieldStatement:
+		markTailCalls(node.Value, inFunction, false)
+
+	case *CallExpression:
+		node.IsTailCall = tail
+		markTailCalls(node.Function, inFunction, false)
+		for _, a := range node.Arguments {
+			markTailCalls(a, inFunction, false)
+		}
+
+	case *IfExpression:
+		markTailCalls(node.Condition, inFunction, false)
+		markBlockTailCalls(node.Consequence, inFunction, tail)
+		markBlockTailCalls(node.Alternative, inFunction, tail)
+
+	case *FunctionLiteral:
+		markBlockTailCalls(node.Body, true, true)
+
+	case *MacroLiteral:
+		markBlockTailCalls(node.Body, false, false)
+
+	case *SpawnExpression:
+		if node.Call != nil {
+			markTailCalls(node.Call, inFunction, false)
+		}
+
+	case *InfixExpression:
+		markTailCalls(node.Left, inFunction, false)
+		markTailCalls(node.Right, inFunction, false)
+
+	case *PrefixExpression:
+		markTailCalls(node.Right, inFunction, false)
+
+	case *IndexExpression:
+		markTailCalls(node.Left, inFunction, false)
+		markTailCalls(node.Index, inFunction, false)
+
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			markTailCalls(el, inFunction, false)
+		}
+
+	case *HashLiteral:
+		for key, val := range node.Pairs {
+			markTailCalls(key, inFunction, false)
+			markTailCalls(val, inFunction, false)
+		}
+	}
+}
+
+// Only the last statement of a block produces its value, so it is the only one that can be in
+// tail position (return statements anywhere in the block are handled on their own)
+func markBlockTailCalls(block *BlockStatement, inFunction, tail bool) {
+	if block == nil {
+		return
+	}
+
+	for i, s := range block.Statements {
+		markTailCalls(s, inFunction, tail && i == len(block.Statements)-1)
+	}
+}
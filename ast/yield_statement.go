@@ -0,0 +1,33 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/bradford-hamilton/monkey-lang/token"
+)
+
+// YieldStatement - holds the YIELD token and the value handed to the generator's caller
+type YieldStatement struct {
+	Token token.Token // The 'yield' token
+	Value Expression
+}
+
+func (ys *YieldStatement) statementNode() {}
+
+// TokenLiteral returns the YieldStatement's Literal and satisfies the Node interface.
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+
+// String - returns a string representation of the YieldStatement and satisfies our Node interface
+func (ys *YieldStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ys.TokenLiteral() + " ")
+
+	if ys.Value != nil {
+		out.WriteString(ys.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
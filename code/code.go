@@ -114,10 +114,34 @@ const (
 	// Builtin functions
 	OpGetBuiltin
 
+	// Builtin modules, namespaces of builtins such as math
+	OpGetModule
+
 	// Closures and it's variables
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
+
+	// Suspend a generator, handing the value on top of the stack to its caller
+	OpYield
+
+	// Call a function on a new goroutine, leaving a channel that receives its result
+	OpSpawn
+
+	// Call a function in tail position, reusing the current frame instead of pushing a new one
+	OpTailCall
+
+	// Wide forms: the same as their narrow counterparts but with wider operands. The compiler
+	// switches to one of these whenever an operand doesn't fit in the narrow form (see Widen)
+	OpConstantWide
+	OpGetLocalWide
+	OpSetLocalWide
+	OpGetBuiltinWide
+	OpCallWide
+	OpTailCallWide
+	OpSpawnWide
+	OpClosureWide
+	OpGetFreeWide
 )
 
 // Definition for an opcode. Name helps to make an Opcode readable and OperandWidths
@@ -161,6 +185,7 @@ var definitions = map[Opcode]*Definition{
 	OpGetLocal:      {"OpGetLocal", []int{1}},
 	OpSetLocal:      {"OpSetLocal", []int{1}},
 	OpGetBuiltin:    {"OpGetBuiltin", []int{1}},
+	OpGetModule:     {"OpGetModule", []int{1}},
 
 	// Has two operands, first is two bytes wide - the constant index. Specifies where in the constant pool we
 	// can find the *object.CompiledFunction that's to be converted into a closure. It's two bytes wide because
@@ -169,6 +194,62 @@ var definitions = map[Opcode]*Definition{
 	OpClosure:        {"OpClosure", []int{2, 1}},
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpYield:          {"OpYield", []int{}},
+	OpSpawn:          {"OpSpawn", []int{1}},    // Same operand as OpCall: the number of arguments on the stack
+	OpTailCall:       {"OpTailCall", []int{1}}, // Same operand as OpCall
+	OpConstantWide:   {"OpConstantWide", []int{4}},
+	OpGetLocalWide:   {"OpGetLocalWide", []int{2}},
+	OpSetLocalWide:   {"OpSetLocalWide", []int{2}},
+	OpGetBuiltinWide: {"OpGetBuiltinWide", []int{2}},
+	OpCallWide:       {"OpCallWide", []int{2}},
+	OpTailCallWide:   {"OpTailCallWide", []int{2}},
+	OpSpawnWide:      {"OpSpawnWide", []int{2}},
+	OpClosureWide:    {"OpClosureWide", []int{4, 2}},
+	OpGetFreeWide:    {"OpGetFreeWide", []int{2}},
+}
+
+// wideForms maps each opcode that has a wide form to it
+var wideForms = map[Opcode]Opcode{
+	OpConstant:   OpConstantWide,
+	OpGetLocal:   OpGetLocalWide,
+	OpSetLocal:   OpSetLocalWide,
+	OpGetBuiltin: OpGetBuiltinWide,
+	OpCall:       OpCallWide,
+	OpTailCall:   OpTailCallWide,
+	OpSpawn:      OpSpawnWide,
+	OpClosure:    OpClosureWide,
+	OpGetFree:    OpGetFreeWide,
+}
+
+// Fits reports whether every operand is small enough to be encoded in op's operand widths
+func Fits(op Opcode, operands ...int) bool {
+	def, ok := definitions[op]
+	if !ok {
+		return false
+	}
+
+	for i, o := range operands {
+		if i >= len(def.OperandWidths) || o < 0 || uint64(o) >= 1<<(8*def.OperandWidths[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Widen returns op if its operands fit in it and otherwise its wide form. The returned bool is
+// false when the operands fit in neither, which means a limit of the bytecode has been reached
+func Widen(op Opcode, operands ...int) (Opcode, bool) {
+	if Fits(op, operands...) {
+		return op, true
+	}
+
+	wide, ok := wideForms[op]
+	if !ok || !Fits(wide, operands...) {
+		return op, false
+	}
+
+	return wide, true
 }
 
 // Lookup finds a definition by opcode. It returns it if it is found otherwise returns an error
@@ -205,6 +286,8 @@ func Make(op Opcode, operands ...int) []byte {
 	for i, o := range operands {
 		width := def.OperandWidths[i]
 		switch width {
+		case 4:
+			binary.BigEndian.PutUint32(instruction[offset:], uint32(o))
 		case 2:
 			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
 		case 1:
@@ -223,6 +306,8 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 
 	for i, width := range def.OperandWidths {
 		switch width {
+		case 4:
+			operands[i] = int(ReadUint32(ins[offset:]))
 		case 2:
 			operands[i] = int(ReadUint16(ins[offset:]))
 		case 1:
@@ -235,6 +320,11 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 	return operands, offset
 }
 
+// ReadUint32 turns a byte sequence (Instructions) into a uint32
+func ReadUint32(ins Instructions) uint32 {
+	return binary.BigEndian.Uint32(ins)
+}
+
 // ReadUint16 turns a byte sequence (Instructions) into a uint16
 func ReadUint16(ins Instructions) uint16 {
 	return binary.BigEndian.Uint16(ins)
@@ -14,6 +14,9 @@ func TestMake(t *testing.T) {
 		{OpAdd, []int{}, []byte{byte(OpAdd)}},
 		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
 		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpConstantWide, []int{65536}, []byte{byte(OpConstantWide), 0, 1, 0, 0}},
+		{OpGetLocalWide, []int{256}, []byte{byte(OpGetLocalWide), 1, 0}},
+		{OpClosureWide, []int{65536, 256}, []byte{byte(OpClosureWide), 0, 1, 0, 0, 1, 0}},
 	}
 
 	for _, tt := range tests {
@@ -66,6 +69,9 @@ func TestReadOperands(t *testing.T) {
 		{OpConstant, []int{65535}, 2},
 		{OpGetLocal, []int{255}, 1},
 		{OpClosure, []int{65534, 255}, 3},
+		{OpConstantWide, []int{1 << 20}, 4},
+		{OpCallWide, []int{300}, 2},
+		{OpClosureWide, []int{1 << 20, 300}, 6},
 	}
 
 	for _, tt := range tests {
@@ -88,3 +94,36 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestWiden(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected Opcode
+		ok       bool
+	}{
+		{OpConstant, []int{65535}, OpConstant, true},
+		{OpConstant, []int{65536}, OpConstantWide, true},
+		{OpGetLocal, []int{255}, OpGetLocal, true},
+		{OpGetLocal, []int{256}, OpGetLocalWide, true},
+		{OpSetLocal, []int{256}, OpSetLocalWide, true},
+		{OpGetBuiltin, []int{256}, OpGetBuiltinWide, true},
+		{OpCall, []int{256}, OpCallWide, true},
+		{OpTailCall, []int{256}, OpTailCallWide, true},
+		{OpSpawn, []int{256}, OpSpawnWide, true},
+		{OpClosure, []int{1, 256}, OpClosureWide, true},
+		{OpClosure, []int{65536, 1}, OpClosureWide, true},
+		{OpGetFree, []int{256}, OpGetFreeWide, true},
+		{OpGetLocal, []int{65536}, OpGetLocal, false},
+		{OpArray, []int{65536}, OpArray, false},
+		{OpAdd, []int{}, OpAdd, true},
+	}
+
+	for _, tt := range tests {
+		op, ok := Widen(tt.op, tt.operands...)
+
+		if op != tt.expected || ok != tt.ok {
+			t.Errorf("Widen(%d, %v) wrong. Expected: %d, %t. Got: %d, %t", tt.op, tt.operands, tt.expected, tt.ok, op, ok)
+		}
+	}
+}
@@ -9,6 +9,16 @@ import (
 	"github.com/bradford-hamilton/monkey-lang/object"
 )
 
+// Limits on what the bytecode can encode, even using the wide forms of instructions
+const (
+	maxGlobals       = 1 << 16   // OpGetGlobal/OpSetGlobal indexes are 2 bytes wide
+	maxLocals        = 1 << 16   // OpGetLocalWide/OpSetLocalWide indexes are 2 bytes wide
+	maxFreeVariables = 1<<16 - 1 // OpClosureWide's free variable count is 2 bytes wide
+	maxArguments     = 1<<16 - 1 // OpCallWide's argument count is 2 bytes wide
+	maxElements      = 1<<16 - 1 // OpArray/OpHash element counts are 2 bytes wide
+	maxJumpTarget    = 1<<16 - 1 // OpJump/OpJumpNotTruthy targets are 2 bytes wide
+)
+
 // Bytecode contains the Instructions our Compiler generated and the Constants the
 // Compiler evaluated
 type Bytecode struct {
@@ -42,8 +52,15 @@ type Compiler struct {
 	scopeIndex  int
 }
 
-// New creates and returns a pointer to a Compiler with initialized instructions & constants
+// New creates and returns a pointer to a Compiler with initialized instructions & constants, for
+// code that uses the default builtins
 func New() *Compiler {
+	return NewWithBuiltins(object.DefaultRegistry())
+}
+
+// NewWithBuiltins creates a Compiler for code that uses the builtins in the given Registry. The
+// VM that runs the code must have the same Registry
+func NewWithBuiltins(builtins *object.Registry) *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
 		lastInstruction:     EmittedInstruction{},
@@ -51,9 +68,9 @@ func New() *Compiler {
 	}
 
 	symbolTable := NewSymbolTable()
-
-	for i, v := range object.Builtins {
-		symbolTable.DefineBuiltin(i, v.Name)
+	symbolTable.DefineBuiltins(builtins)
+	for i, v := range object.Modules {
+		symbolTable.DefineModule(i, v.Name)
 	}
 
 	return &Compiler{
@@ -80,6 +97,8 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpGetLocal, s.Index)
 	case BuiltinScope:
 		c.emit(code.OpGetBuiltin, s.Index)
+	case ModuleScope:
+		c.emit(code.OpGetModule, s.Index)
 	case FreeScope:
 		c.emit(code.OpGetFree, s.Index)
 	case FunctionScope:
@@ -193,6 +212,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
 
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.RegexLiteral:
+		regex := &object.Regex{Value: node.Regex}
+		c.emit(code.OpConstant, c.addConstant(regex))
+
 	case *ast.Boolean:
 		if node.Value {
 			c.emit(code.OpTrue)
@@ -261,6 +288,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		jumpPos := c.emit(code.OpJump, 9999)
 
 		afterConsequencePos := len(c.currentInstructions())
+		if err := checkLimit("bytes of instructions in one function", afterConsequencePos, maxJumpTarget); err != nil {
+			return err
+		}
 
 		// Change the jump-to position in the OpJumpNotTruthy emission earlier
 		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
@@ -279,6 +309,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		afterAlternativePos := len(c.currentInstructions())
+		if err := checkLimit("bytes of instructions in one function", afterAlternativePos, maxJumpTarget); err != nil {
+			return err
+		}
 		c.changeOperand(jumpPos, afterAlternativePos)
 
 	case *ast.BlockStatement:
@@ -291,6 +324,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.LetStatement:
 		symbol := c.symbolTable.Define(node.Name.Value)
+		if err := checkSymbolLimit(symbol); err != nil {
+			return err
+		}
 
 		err := c.Compile(node.Value)
 		if err != nil {
@@ -305,6 +341,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.ConstStatement:
 		symbol := c.symbolTable.Define(node.Name.Value)
+		if err := checkSymbolLimit(symbol); err != nil {
+			return err
+		}
 
 		err := c.Compile(node.Value)
 		if err != nil {
@@ -317,6 +356,26 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpSetLocal, symbol.Index)
 		}
 
+	case *ast.EnumStatement:
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if err := checkSymbolLimit(symbol); err != nil {
+			return err
+		}
+
+		variants := []string{}
+		for _, v := range node.Variants {
+			variants = append(variants, v.Value)
+		}
+
+		enum := object.NewEnum(node.Name.Value, variants)
+		c.emit(code.OpConstant, c.addConstant(enum))
+
+		if symbol.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
@@ -336,6 +395,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 		}
+		if err := checkLimit("array elements", len(node.Elements), maxElements); err != nil {
+			return err
+		}
 		c.emit(code.OpArray, len(node.Elements))
 
 	case *ast.HashLiteral:
@@ -358,6 +420,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+		if err := checkLimit("hash pairs", len(node.Pairs), maxElements/2); err != nil {
+			return err
+		}
 		c.emit(code.OpHash, len(node.Pairs)*2)
 
 	case *ast.IndexExpression:
@@ -398,6 +463,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
+		if err := checkLimit("free variables in one function", len(freeSymbols), maxFreeVariables); err != nil {
+			return err
+		}
+		if err := checkLimit("local bindings in one function", numLocals, maxLocals); err != nil {
+			return err
+		}
 		instructions := c.leaveScope()
 
 		for _, s := range freeSymbols {
@@ -408,6 +479,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			IsGenerator:   node.IsGenerator,
+			Name:          node.Name,
 		}
 
 		fnIndex := c.addConstant(compiledFunc)
@@ -421,7 +494,19 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpReturnValue)
 
+	case *ast.YieldStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpYield)
+
 	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			return fmt.Errorf("quote can only be used inside macros when compiling")
+		}
+
 		err := c.Compile(node.Function)
 		if err != nil {
 			return err
@@ -434,9 +519,57 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
-		c.emit(code.OpCall, len(node.Arguments))
+		if err := checkLimit("arguments in one call", len(node.Arguments), maxArguments); err != nil {
+			return err
+		}
+
+		if node.IsTailCall {
+			c.emit(code.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
+
+	case *ast.SpawnExpression:
+		err := c.Compile(node.Call.Function)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range node.Call.Arguments {
+			err := c.Compile(a)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := checkLimit("arguments in one call", len(node.Call.Arguments), maxArguments); err != nil {
+			return err
+		}
+
+		c.emit(code.OpSpawn, len(node.Call.Arguments))
+
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macros must be defined with a top-level let statement")
+	}
+
+	return nil
+}
+
+// checkLimit returns an error when there are more than limit of something the bytecode can only
+// encode limit of, even with wide operands
+func checkLimit(what string, n, limit int) error {
+	if n > limit {
+		return fmt.Errorf("too many %s: %d (the limit is %d)", what, n, limit)
 	}
+	return nil
+}
 
+// checkSymbolLimit returns an error when a global binding was given an index the bytecode can't
+// encode. Local bindings are checked once their function has been compiled
+func checkSymbolLimit(s Symbol) error {
+	if s.Scope == GlobalScope {
+		return checkLimit("global bindings", s.Index+1, maxGlobals)
+	}
 	return nil
 }
 
@@ -446,6 +579,12 @@ func (c *Compiler) addConstant(obj object.Object) int {
 }
 
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	// Operands too big for op get its wide form. Ones too big for any form are turned into
+	// compile errors (see checkLimit) before they get here
+	if wide, ok := code.Widen(op, operands...); ok {
+		op = wide
+	}
+
 	ins := code.Make(op, operands...)
 	pos := c.addInstruction(ins)
 
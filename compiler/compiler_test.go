@@ -2,6 +2,7 @@ package compiler
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/bradford-hamilton/monkey-lang/ast"
@@ -526,6 +527,47 @@ func TestHashLiterals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestEnumStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			enum Color { Red, Green };
+			Color.Red;
+			`,
+			expectedConstants: []interface{}{
+				object.NewEnum("Color", []string{"Red", "Green"}),
+				"Red",
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `func() { enum Color { Red }; Color }`,
+			expectedConstants: []interface{}{
+				object.NewEnum("Color", []string{"Red"}),
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestIndexExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -712,6 +754,45 @@ func TestFunctionCalls(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestGenerators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `func() { yield 1; yield 2; }`,
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpYield),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpYield),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	program := parse(`func() { yield 1; }; func() { 1; }`)
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	constants := compiler.Bytecode().Constants
+	if !constants[1].(*object.CompiledFunction).IsGenerator {
+		t.Errorf("function containing yield should be compiled as a generator")
+	}
+	if constants[3].(*object.CompiledFunction).IsGenerator {
+		t.Errorf("function without yield should not be compiled as a generator")
+	}
+}
+
 func TestCompilerScopes(t *testing.T) {
 	compiler := New()
 	if compiler.scopeIndex != 0 {
@@ -969,7 +1050,7 @@ func TestBuiltins(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpGetBuiltin, 0),
 					code.Make(code.OpArray, 0),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -1124,7 +1205,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 0),
 					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -1153,7 +1234,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 0),
 					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -1162,7 +1243,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 2),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -1254,6 +1335,20 @@ func testConstants(t *testing.T, expected []interface{}, actual []object.Object)
 			if err != nil {
 				return fmt.Errorf("constant %d - testStringObject failed: %s", i, err)
 			}
+		case float64:
+			float, ok := actual[i].(*object.Float)
+			if !ok || float.Value != constant {
+				return fmt.Errorf("constant %d - not Float %v. Got: %T (%+v)", i, constant, actual[i], actual[i])
+			}
+		case *object.Enum:
+			enum, ok := actual[i].(*object.Enum)
+			if !ok {
+				return fmt.Errorf("constant %d - not an enum: %T", i, actual[i])
+			}
+
+			if enum.Inspect() != constant.Inspect() {
+				return fmt.Errorf("constant %d - wrong enum. Expected: %s. Got: %s", i, constant.Inspect(), enum.Inspect())
+			}
 		case []code.Instructions:
 			fn, ok := actual[i].(*object.CompiledFunction)
 			if !ok {
@@ -1295,3 +1390,213 @@ func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 		}
 	}
 }
+
+func TestSpawnExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `let f = func(a) { a }; spawn f(1);`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSpawn, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMacroCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1 + 2)`, "quote can only be used inside macros when compiling"},
+		{`func() { macro(x) { x } }`, "macros must be defined with a top-level let statement"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		compiler := New()
+
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("Expected compiler error for %q but got none", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("Wrong compiler error. Expected: %q. Got: %q", tt.expected, err)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `func(f) { if (true) { f() } else { 1 + f() } }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpTrue),
+					code.Make(code.OpJumpNotTruthy, 11),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpJump, 19),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `func(f) { return f(); }`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestWideOperands(t *testing.T) {
+	params := make([]string, 300)
+	for i := range params {
+		params[i] = letterName(i)
+	}
+
+	program := parse(fmt.Sprintf("func(%s) { %s }", strings.Join(params, ", "), letterName(299)))
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	fn := compiler.Bytecode().Constants[0].(*object.CompiledFunction)
+	err := testInstructions([]code.Instructions{
+		code.Make(code.OpGetLocalWide, 299),
+		code.Make(code.OpReturnValue),
+	}, fn.Instructions)
+	if err != nil {
+		t.Errorf("testInstructions failed: %s", err)
+	}
+
+	constants := make([]string, 65537)
+	for i := range constants {
+		constants[i] = fmt.Sprintf("%d;", i)
+	}
+
+	program = parse(strings.Join(constants, " "))
+	compiler = New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	ins := compiler.Bytecode().Instructions
+	last := ins[len(ins)-len(code.Make(code.OpConstantWide, 65536))-1:]
+	err = testInstructions([]code.Instructions{
+		code.Make(code.OpConstantWide, 65536),
+		code.Make(code.OpPop),
+	}, last)
+	if err != nil {
+		t.Errorf("testInstructions failed: %s", err)
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	elements := strings.TrimSuffix(strings.Repeat("1, ", 65536), ", ")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[" + elements + "]", "too many array elements: 65536 (the limit is 65535)"},
+		{"len(" + elements + ")", "too many arguments in one call: 65536 (the limit is 65535)"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		compiler := New()
+
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("Expected compiler error but got none")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("Wrong compiler error. Expected: %q. Got: %q", tt.expected, err)
+		}
+	}
+}
+
+// letterName returns a distinct identifier for i made only of letters, since identifiers can't hold digits
+func letterName(i int) string {
+	name := ""
+	for {
+		name = string(rune('a'+i%26)) + name
+		i /= 26
+		if i == 0 {
+			return "v" + name
+		}
+	}
+}
+
+func TestFloatsAndModules(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1.5 + 2",
+			expectedConstants: []interface{}{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "math.PI",
+			expectedConstants: []interface{}{"PI"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetModule, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let math = 1; math",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
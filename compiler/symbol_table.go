@@ -1,5 +1,7 @@
 package compiler
 
+import "github.com/bradford-hamilton/monkey-lang/object"
+
 // A symbol table is a data structure used in interpreters & compilers to associate identifiers
 // with information. It can be used in every phase, from lexing to code generation, to store and
 // retrieve information about a given identifier (which can be called a symbol). Information such
@@ -14,6 +16,7 @@ const (
 	GlobalScope   SymbolScope = "GLOBAL"
 	LocalScope    SymbolScope = "LOCAL"
 	BuiltinScope  SymbolScope = "BUILTIN"
+	ModuleScope   SymbolScope = "MODULE"
 	FreeScope     SymbolScope = "FREE"
 	FunctionScope SymbolScope = "FUNCTION"
 )
@@ -73,6 +76,13 @@ func (s *SymbolTable) Define(name string) Symbol {
 	return symbol
 }
 
+// DefineBuiltins defines a builtin symbol for everything in builtins, at its position there
+func (s *SymbolTable) DefineBuiltins(builtins *object.Registry) {
+	for i, name := range builtins.Names() {
+		s.DefineBuiltin(i, name)
+	}
+}
+
 // DefineBuiltin creates and returns a symbol within builtin scope
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{
@@ -85,6 +95,18 @@ func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	return symbol
 }
 
+// DefineModule creates and returns a symbol within module scope
+func (s *SymbolTable) DefineModule(index int, name string) Symbol {
+	symbol := Symbol{
+		Name:  name,
+		Index: index,
+		Scope: ModuleScope,
+	}
+	s.store[name] = symbol
+
+	return symbol
+}
+
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 
@@ -121,7 +143,7 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 			return obj, ok
 		}
 
-		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope || obj.Scope == ModuleScope {
 			return obj, ok
 		}
 
@@ -1,6 +1,10 @@
 package compiler
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/monkey-lang/object"
+)
 
 func TestDefine(t *testing.T) {
 	expected := map[string]Symbol{
@@ -181,6 +185,44 @@ func TestDefineResolveBuiltins(t *testing.T) {
 	}
 }
 
+func TestDefineBuiltinsFromRegistry(t *testing.T) {
+	builtins := object.NewRegistry()
+	builtins.Register("double", &object.Builtin{})
+	builtins.Register("VERSION", &object.String{Value: "1.0"})
+
+	global := NewSymbolTable()
+	global.DefineBuiltins(builtins)
+	local := NewEnclosedSymbolTable(global)
+
+	for i, name := range []string{"double", "VERSION"} {
+		expected := Symbol{Name: name, Scope: BuiltinScope, Index: i}
+		if result, ok := local.Resolve(name); !ok || result != expected {
+			t.Errorf("Expected %s to resolve to %+v. Got: %+v", name, expected, result)
+		}
+	}
+	if _, ok := global.Resolve("len"); ok {
+		t.Errorf("only the registry's builtins should be defined")
+	}
+}
+
+func TestDefineResolveModules(t *testing.T) {
+	global := NewSymbolTable()
+	local := NewEnclosedSymbolTable(NewEnclosedSymbolTable(global))
+
+	expected := global.DefineModule(0, "math")
+
+	result, ok := local.Resolve("math")
+	if !ok {
+		t.Fatalf("name math not resolvable")
+	}
+	if result != expected {
+		t.Errorf("Expected math to resolve to %+v. Got: %+v", expected, result)
+	}
+	if len(local.FreeSymbols) != 0 {
+		t.Errorf("modules should not be captured as free variables. Got: %+v", local.FreeSymbols)
+	}
+}
+
 func TestResolveFree(t *testing.T) {
 	global := NewSymbolTable()
 	global.Define("a")
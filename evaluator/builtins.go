@@ -4,14 +4,18 @@ import (
 	"github.com/bradford-hamilton/monkey-lang/object"
 )
 
-var builtinFunctions = map[string]*object.Builtin{
-	"len":   object.GetBuiltinByName("len"),
-	"print": object.GetBuiltinByName("print"),
-	"first": object.GetBuiltinByName("first"),
-	"last":  object.GetBuiltinByName("last"),
-	"rest":  object.GetBuiltinByName("rest"),
-	"push":  object.GetBuiltinByName("push"),
-	"pop":   object.GetBuiltinByName("pop"),
-	"split": object.GetBuiltinByName("split"),
-	"join":  object.GetBuiltinByName("join"),
+// host lets builtins call back into Monkey functions while evaluating
+type host struct {
+	env  *object.Environment
+	line int
+}
+
+// Call implements object.Host
+func (h host) Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, h.env, h.line)
+}
+
+// Runtime implements object.Host
+func (h host) Runtime() *object.Runtime {
+	return h.env.Runtime()
 }
@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/bradford-hamilton/monkey-lang/ast"
 	"github.com/bradford-hamilton/monkey-lang/object"
@@ -10,9 +11,9 @@ import (
 // No need to create new true/false or null objects every time we encounter one, they will
 // be the same. Let's reference them instead
 var (
-	True  = &object.Boolean{Value: true}
-	False = &object.Boolean{Value: false}
-	Null  = &object.Null{}
+	True  = object.TrueValue
+	False = object.FalseValue
+	Null  = object.NullValue
 )
 
 // Eval takes an ast.Node (starting with the RootNode) and traverses the AST.
@@ -51,13 +52,29 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.EnumStatement:
+		env.Set(node.Name.Value, evalEnumStatement(node))
+
+	case *ast.YieldStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return evalYield(val, env, node.Token.Line)
+
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.RegexLiteral:
+		return &object.Regex{Value: node.Regex}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObj(node.Value)
 
@@ -77,7 +94,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpr(node.Operator, left, right, node.Token.Line)
+		return allocate(evalInfixExpr(node.Operator, left, right, node.Token.Line), env, node.Token.Line)
 
 	case *ast.PostfixExpression:
 		return evalPostfixExpr(env, node.Operator, node)
@@ -92,12 +109,20 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		params := node.Parameters
 		body := node.Body
 		return &object.Function{
-			Parameters: params,
-			Body:       body,
-			Env:        env,
+			Parameters:  params,
+			Body:        body,
+			Env:         env,
+			IsGenerator: node.IsGenerator,
 		}
 
 	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			if len(node.Arguments) != 1 {
+				return newError("Line %d: quote takes exactly one argument. Got: %d", node.Token.Line, len(node.Arguments))
+			}
+			return quote(node.Arguments[0], env, node.Token.Line)
+		}
+
 		fn := Eval(node.Function, env)
 		if isError(fn) {
 			return fn
@@ -106,14 +131,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(fn, args, node.Token.Line)
+		if fn, ok := fn.(*object.Function); ok && node.IsTailCall {
+			return &tailCall{fn: fn, args: args, line: node.Token.Line}
+		}
+		return applyFunction(fn, args, env, node.Token.Line)
 
 	case *ast.ArrayLiteral:
 		elements := evalExprs(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocate(&object.Array{Elements: elements}, env, node.Token.Line)
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
@@ -127,7 +155,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIndexExpr(left, index, node.Token.Line)
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return allocate(evalHashLiteral(node, env), env, node.Token.Line)
+
+	case *ast.SpawnExpression:
+		return evalSpawn(node, env)
+
+	case *ast.MacroLiteral:
+		return newError("Line %d: Macros must be defined with a top-level let statement", node.Token.Line)
 	}
 
 	return nil
@@ -224,6 +258,9 @@ func evalBangOperatorExpr(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpr(right object.Object, line int) object.Object {
+	if f, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
 	if right.Type() != object.IntegerObj {
 		return newError("Line %d: Unknown operator: -%s", line, right.Type())
 	}
@@ -274,8 +311,12 @@ func evalInfixExpr(operator string, left, right object.Object, line int) object.
 	switch {
 	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
 		return evalIntegerInfixExpr(operator, left, right, line)
+	case isNumber(left) && isNumber(right) && (left.Type() == object.FloatObj || right.Type() == object.FloatObj):
+		return evalFloatInfixExpr(operator, left, right, line)
 	case left.Type() == object.StringObj && right.Type() == object.StringObj:
 		return evalStringInfixExpr(operator, left, right, line)
+	case left.Type() == object.EnumValueObj && right.Type() == object.EnumValueObj:
+		return evalEnumValueInfixExpr(operator, left, right, line)
 	case operator == "==":
 		return nativeBoolToBooleanObj(left == right)
 	case operator == "!=":
@@ -287,7 +328,6 @@ func evalInfixExpr(operator string, left, right object.Object, line int) object.
 	case left.Type() != right.Type():
 		return newError("Line %d: Type mismatch: %s %s %s", line, left.Type(), operator, right.Type())
 	default:
-		fmt.Printf("%s %s %s", left.Type(), operator, right.Type())
 		return newError("Line %d: Unknown operator: %s %s %s", line, left.Type(), operator, right.Type())
 	}
 }
@@ -320,6 +360,44 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntegerObj || obj.Type() == object.FloatObj
+}
+
+// evalFloatInfixExpr evaluates arithmetic and comparisons where at least one side is a Float,
+// converting an Integer on the other side
+func evalFloatInfixExpr(operator string, left, right object.Object, line int) object.Object {
+	leftVal, _ := object.ToFloat(left)
+	rightVal, _ := object.ToFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObj(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObj(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObj(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObj(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObj(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObj(leftVal != rightVal)
+	default:
+		return newError("Line %d: Unknown operator: %s %s %s", line, left.Type(), operator, right.Type())
+	}
+}
+
 func evalIntegerInfixExpr(operator string, left, right object.Object, line int) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
@@ -369,13 +447,40 @@ func evalStringInfixExpr(operator string, left, right object.Object, line int) o
 
 }
 
+func evalEnumValueInfixExpr(operator string, left, right object.Object, line int) object.Object {
+	leftVal := left.(*object.EnumValue)
+	rightVal := right.(*object.EnumValue)
+
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObj(leftVal.Equals(rightVal))
+	case "!=":
+		return nativeBoolToBooleanObj(!leftVal.Equals(rightVal))
+	default:
+		return newError("Line %d: Unknown operator: %s %s %s", line, left.Type(), operator, right.Type())
+	}
+}
+
+func evalEnumStatement(node *ast.EnumStatement) object.Object {
+	variants := []string{}
+	for _, v := range node.Variants {
+		variants = append(variants, v.Value)
+	}
+
+	return object.NewEnum(node.Name.Value, variants)
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtinFn, ok := builtinFunctions[node.Value]; ok {
-		return builtinFn
+	if builtin, ok := env.Runtime().Builtins().Lookup(node.Value); ok {
+		return builtin
+	}
+
+	if module := object.GetModuleByName(node.Value); module != nil {
+		return module
 	}
 
 	return newError("Line %d: Identifier not found: %s", node.Token.Line, node.Value)
@@ -387,6 +492,8 @@ func evalIndexExpr(left, index object.Object, line int) object.Object {
 		return evalArrayIndexExpr(left, index)
 	case left.Type() == object.HashObj:
 		return evalHashIndexExpr(left, index, line)
+	case left.Type() == object.EnumObj:
+		return evalEnumIndexExpr(left, index, line)
 	default:
 		return newError("Line %d: Index operator not supported: %s", line, left.Type())
 	}
@@ -420,6 +527,22 @@ func evalHashIndexExpr(hash, index object.Object, line int) object.Object {
 	return pair.Value
 }
 
+func evalEnumIndexExpr(enum, index object.Object, line int) object.Object {
+	enumObj := enum.(*object.Enum)
+
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("Line %d: Enum variant must be accessed by name. Got: %s", line, index.Type())
+	}
+
+	variant, ok := enumObj.Variant(name.Value)
+	if !ok {
+		return newError("Line %d: Enum %s has no variant %s", line, enumObj.Name, name.Value)
+	}
+
+	return variant
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
@@ -460,17 +583,50 @@ func evalExprs(exprs []ast.Expression, env *object.Environment) []object.Object
 	return result
 }
 
-func applyFunction(function object.Object, args []object.Object, line int) object.Object {
+// CallFunction calls fn, a function or builtin, with args from outside of any Monkey code, such as
+// from a Go program embedding the evaluator. Builtins get env's Runtime
+func CallFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return applyFunction(fn, args, env, 0)
+}
+
+// applyFunction calls function with args. env is the caller's environment, whose Runtime is
+// handed to builtins and counts every call as a step
+func applyFunction(function object.Object, args []object.Object, env *object.Environment, line int) object.Object {
 	switch fn := function.(type) {
 	case *object.Function:
-		if len(args) != len(fn.Parameters) {
-			return newError("Line %d: Wrong number of arguments: expected %d, got %d", line, len(fn.Parameters), len(args))
+		// Calls in tail position hand back a tailCall instead of recursing, and we make them
+		// here so that tail recursion doesn't grow the Go stack
+		for {
+			if err := env.Runtime().Step(1); err != nil {
+				return newError("Line %d: %s", line, err)
+			}
+			if len(args) != len(fn.Parameters) {
+				return newError("Line %d: Wrong number of arguments: expected %d, got %d", line, len(fn.Parameters), len(args))
+			}
+			if fn.IsGenerator {
+				return newGenerator(fn, args)
+			}
+			extendedEnv := extendFunctionEnv(fn, args)
+			evaluated := unwrapReturnValue(Eval(fn.Body, extendedEnv))
+
+			tc, ok := evaluated.(*tailCall)
+			if !ok {
+				return evaluated
+			}
+			fn, args, line = tc.fn, tc.args, tc.line
 		}
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		if result := fn.Fn(args...); result != nil {
+		if err := env.Runtime().Step(1); err != nil {
+			return newError("Line %d: %s", line, err)
+		}
+		result := fn.Call(host{env: env, line: line}, args...)
+		if errObj, ok := result.(*object.Error); ok && !errObj.Exit {
+			// A builtin that was blocked when the script was stopped gives up with the reason
+			if err := env.Runtime().Stopped(); err != nil {
+				return newError("Line %d: %s", line, err)
+			}
+		}
+		if result != nil {
 			return result
 		}
 		return Null
@@ -479,6 +635,19 @@ func applyFunction(function object.Object, args []object.Object, line int) objec
 	}
 }
 
+// allocate counts the memory obj, which was just created, takes up against env's Runtime's limit,
+// returning an error in its place once the script has gone over it
+func allocate(obj object.Object, env *object.Environment, line int) object.Object {
+	size := object.Size(obj)
+	if size == 0 {
+		return obj
+	}
+	if err := env.Runtime().Allocate(size); err != nil {
+		return newError("Line %d: %s", line, err)
+	}
+	return obj
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for i, param := range fn.Parameters {
@@ -1,8 +1,14 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bradford-hamilton/monkey-lang/ast"
 	"github.com/bradford-hamilton/monkey-lang/lexer"
 	"github.com/bradford-hamilton/monkey-lang/object"
 	"github.com/bradford-hamilton/monkey-lang/parser"
@@ -641,6 +647,77 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestEnums(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`enum Color { Red, Green }; Color.Red == Color.Red`, true},
+		{`enum Color { Red, Green }; Color.Red == Color.Green`, false},
+		{`enum Color { Red, Green }; Color.Red != Color.Green`, true},
+		{`enum Color { Red }; enum Fruit { Red }; Color.Red == Fruit.Red`, false},
+		{`enum Color { Red, Green }; let c = Color.Green; {Color.Red: 1, Color.Green: 2}[c]`, 2},
+		{`enum Color { Red, Green }; Color["Green"] == Color.Green`, true},
+		{`enum Color { Red }; Color.Purple`, "Line 0: Enum Color has no variant Purple"},
+		{`enum Color { Red }; Color[0]`, "Line 0: Enum variant must be accessed by name. Got: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+
+	evaluated := testEval(`enum Color { Red, Green }; Color.Green`)
+	if evaluated.Inspect() != "Color.Green" {
+		t.Errorf("Enum value has wrong string representation. Expected: Color.Green. Got: %s", evaluated.Inspect())
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let g = func() { yield 1; yield 2; }(); next(g)`, 1},
+		{`let g = func() { yield 1; yield 2; }(); next(g); next(g)`, 2},
+		{`let g = func() { yield 1; }(); next(g); next(g)`, nil},
+		{`let g = func() { yield 1; }(); next(g); next(g); next(g)`, nil},
+		{`let g = func(a, b) { let c = a + b; yield c; yield c * 2; }(1, 2); next(g) + next(g)`, 9},
+		{`let g = func(n) { if (n > 1) { yield "big"; } yield "done"; }(5); next(g)`, "big"},
+		{`let gen = func() { yield 1; }; let a = gen(); let b = gen(); next(a); next(b)`, 1},
+		{`let outer = 10; let g = func() { let f = func(x) { x + outer }; yield f(1); }(); next(g)`, 11},
+		{`let g = func() { yield 1; return 99; }(); next(g); next(g)`, nil},
+		{`let g = func() { yield 1 + true; }(); next(g)`, "Line 0: Type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("Wrong error message. Expected: %q, Got: %q", expected, errObj.Message)
+				}
+			} else if str, ok := evaluated.(*object.String); !ok || str.Value != expected {
+				t.Errorf("object is not %q. Got: %T (%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -698,4 +775,875 @@ func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
 	}
 
 	return true
-}
\ No newline at end of file
+}
+
+func TestAbandonedGeneratorsStop(t *testing.T) {
+	input := `let started = map(range(20), func(i) { let g = func() { yield i; yield i + 1; }(); next(g); g }); len(started)`
+
+	// countGenerators returns how many generators env's Runtime is still running
+	countGenerators := func(env *object.Environment) int {
+		count := 0
+		env.Runtime().Generators().Range(func(_, _ any) bool {
+			count++
+			return true
+		})
+		return count
+	}
+	// waitForGoroutines waits for the number of goroutines to drop back to at most want
+	waitForGoroutines := func(want int) bool {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if runtime.NumGoroutine() <= want {
+				return true
+			}
+		}
+		return false
+	}
+
+	stops := []struct {
+		name string
+		stop func(env *object.Environment, cancel context.CancelFunc)
+	}{
+		{"cancelling the context", func(env *object.Environment, cancel context.CancelFunc) { cancel() }},
+		{"closing the runtime", func(env *object.Environment, cancel context.CancelFunc) { env.Runtime().Close() }},
+	}
+
+	for _, tt := range stops {
+		before := runtime.NumGoroutine()
+		env := object.NewEnvironment()
+		ctx, cancel := context.WithCancel(context.Background())
+		env.Runtime().SetContext(ctx)
+
+		testIntegerObject(t, Eval(testParseProgram(input), env), 20)
+		if count := countGenerators(env); count != 20 {
+			t.Errorf("expected 20 parked generators, got %d", count)
+		}
+
+		tt.stop(env, cancel)
+		if !waitForGoroutines(before) {
+			t.Errorf("%s: generators left %d goroutines behind", tt.name, runtime.NumGoroutine()-before)
+		}
+		if count := countGenerators(env); count != 0 {
+			t.Errorf("%s: expected no generators left, got %d", tt.name, count)
+		}
+		cancel()
+	}
+
+	// Generators of separate runtimes don't see each other
+	if count := countGenerators(object.NewEnvironment()); count != 0 {
+		t.Errorf("expected a new runtime to have no generators, got %d", count)
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let add = func(a, b) { a + b }; recv(spawn add(1, 2))`, 3},
+		{`let c = channel(); spawn func(ch) { send(ch, 42); }(c); recv(c)`, 42},
+		{`let c = channel(2); send(c, 1); send(c, 2); recv(c) + recv(c)`, 3},
+		{`let c = channel(1); close(c); recv(c)`, nil},
+		{`let c = channel(1); close(c); close(c)`, "Cannot close: close of closed channel"},
+		{`let c = channel(1); close(c); send(c, 1)`, "Cannot send: send on closed channel"},
+		{`let c = channel(1); let d = channel(1); send(d, 7); select([c, d])[0]`, 1},
+		{`let c = channel(1); let d = channel(1); send(d, 7); select([c, d])[1]`, 7},
+		{`recv(spawn len("four"))`, 4},
+		{`recv(spawn func() { 1 + true }())`, "Line 0: Type mismatch: INTEGER + BOOLEAN"},
+		{`spawn func(a) { a }()`, "Line 0: Wrong number of arguments: expected 1, got 0"},
+		{`let x = 1; spawn x()`, "Line 0: Not a function: INTEGER"},
+		{`let n = 0; let done = channel(); spawn func() { send(done, n + 1); }(); recv(done)`, 1},
+		// Spawned code shares the globals, so it sees assignments made after it was spawned
+		{`let n = 0; let go = channel(); let r = spawn func() { recv(go); n }(); n++; send(go, 1); recv(r)`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. Got: %T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("Wrong error message. Expected: %q, Got: %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Fatalf("Expected *object.Quote. Got: %T (%+v)", evaluated, evaluated)
+		}
+
+		if quote.Node == nil {
+			t.Fatalf("quote.Node is nil")
+		}
+
+		if quote.Node.String() != tt.expected {
+			t.Errorf("Not equal. Got: %q. Expected: %q", quote.Node.String(), tt.expected)
+		}
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote("monkey"))`, `monkey`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Fatalf("Expected *object.Quote. Got: %T (%+v)", evaluated, evaluated)
+		}
+
+		if quote.Node.String() != tt.expected {
+			t.Errorf("Not equal. Got: %q. Expected: %q", quote.Node.String(), tt.expected)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote([1]))`, "Line 0: Cannot unquote a value of type ARRAY"},
+		{`quote(spawn unquote(1))`, "Line 0: Cannot unquote: cannot replace unquote(1) with 1: expected a call"},
+		{`quote(unquote(1 + true))`, "Line 0: Type mismatch: INTEGER + BOOLEAN"},
+		{`quote(1, 2)`, "Line 0: quote takes exactly one argument. Got: 2"},
+		{`macro(x) { x }`, "Line 0: Macros must be defined with a top-level let statement"},
+	}
+
+	for _, tt := range errorTests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = func(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. Got: %d", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. Got: %T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. Got: %d", len(macro.Parameters))
+	}
+
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("Wrong macro parameters. Got: %s, %s", macro.Parameters[0], macro.Parameters[1])
+	}
+
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("Body is not %q. Got: %q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, print("not greater"), print("greater"));
+			`,
+			`if (!(10 > 5)) { print("not greater") } else { print("greater") }`,
+		},
+		{
+			`let double = macro(x) { quote(unquote(x) * 2) }; let f = func() { double(3) };`,
+			`let f = func() { (3 * 2) };`,
+		},
+		{
+			`let double = macro(x) { quote(unquote(x) * 2) }; double(3); double(5); double(3 + 4)`,
+			`(3 * 2); (5 * 2); ((3 + 4) * 2)`,
+		},
+		{
+			`let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(true, 1, 2); unless(false, 3, 4)`,
+			`if (!(true)) { 1 } else { 2 }; if (!(false)) { 3 } else { 4 }`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("ExpandMacros returned an error: %s", err)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("Not equal. Want: %q. Got: %q", expected.String(), expanded.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro(x) { 1 }; m(2)`, "Line 0: Macro m must return a quote"},
+		{`let m = macro(x) { quote(x) }; m()`, "Line 0: Wrong number of arguments to macro m: expected 1, got 0"},
+		{`let m = macro() { 1 + true }; m()`, "Line 0: Type mismatch: INTEGER + BOOLEAN"},
+		{"let m = macro() { quote(1) };\nspawn m()", "Line 1: Macro expansion failed: cannot replace m() with 1: expected a call"},
+	}
+
+	for _, tt := range errorTests {
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Fatalf("Expected macro expansion error for %q but got none", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("Wrong error message. Expected: %q, Got: %q", tt.expected, err)
+		}
+	}
+}
+
+func testParseProgram(input string) *ast.RootNode {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let sum = func(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) }; sum(100000, 0)`, 5000050000},
+		{`let even = func(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = func(n) { if (n == 0) { false } else { even(n - 1) } }; even(10000)`, true},
+		{`let f = func(x) { len(x) }; f("four")`, 4},
+		{`let f = func() { func() { yield 1; }() }; next(f())`, 1},
+		{`let out = channel(1); let g = func() { yield 1; send(out, 2) }(); next(g); next(g); recv(out)`, 2},
+		{`let f = func(a) { a }; let g = func() { f() }; g()`, "Line 0: Wrong number of arguments: expected 1, got 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`map([1, 2, 3], func(x) { x * 2 })`, []int{2, 4, 6}},
+		{`map(["a", "bc"], len)`, []int{1, 2}},
+		{`let n = 10; map([1, 2], func(x) { x + n })`, []int{11, 12}},
+		{`filter([1, 2, 3, 4], func(x) { x % 2 == 0 })`, []int{2, 4}},
+		{`reduce([1, 2, 3], 10, func(acc, x) { acc + x })`, 16},
+		{`let seen = channel(3); each([1, 2, 3], func(x) { send(seen, x) }); recv(seen) + recv(seen) + recv(seen)`, 6},
+		{`any([1, 2, 3], func(x) { x > 2 })`, true},
+		{`all([1, 2, 3], func(x) { x > 1 })`, false},
+		{`find([1, 2, 3], func(x) { x > 1 })`, 2},
+		{`find([1, 2, 3], func(x) { x > 3 })`, nil},
+		{`map(sort_by([[2, 1], [1, 2], [2, 3]], first), last)`, []int{2, 1, 3}},
+		{`let f = func() { map([1, 2], func(x) { return x + 1; }) }; f()`, []int{2, 3}},
+		{`recv(spawn map([1, 2], func(x) { x * 3 }))`, []int{3, 6}},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; map(g(), func(x) { if (x) { x * 2 } else { 0 } })`, []int{2, 0, 6}},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; filter(g(), func(x) { x })`, []int{1, 3}},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; reduce(g(), 10, func(acc, x) { if (x) { acc + x } else { acc } })`, 14},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; let seen = channel(3); each(g(), func(x) { send(seen, x) }); recv(seen); recv(seen)`, Null},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; any(g(), func(x) { !x })`, true},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; all(g(), func(x) { x })`, false},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; find(g(), func(x) { if (x) { x > 1 } else { false } })`, 3},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; sort_by(filter(g(), func(x) { x }), func(x) { -x })`, []int{3, 1}},
+		{`let g = func() { yield 1; yield 2; yield 3; }(); find(g, func(x) { x == 1 }); next(g)`, 2},
+		{`let g = func() { yield 1; yield 2; }(); map(g, func(x) { x }); map(g, func(x) { x })`, []int{}},
+		{`map(func() { yield 1; yield "a"; }(), func(x) { x + 1 })`, "Line 0: Type mismatch: STRING + INTEGER"},
+		{`map(func() { yield 1; -"a"; }(), func(x) { x })`, "Line 0: Unknown operator: -STRING"},
+		{`map(1, len)`, "First argument to `map` must be an Array or a Generator. Got: INTEGER"},
+		{`sort_by([1, "a"], func(x) { x })`, "Keys for `sort_by` must all be the same type. Got: INTEGER and STRING"},
+		{`map([1], func(x) { x + "a" })`, "Line 0: Type mismatch: INTEGER + STRING"},
+		{`map([1], func(x, y) { x })`, "Line 0: Wrong number of arguments: expected 2, got 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("héllo")`, 5},
+		{`upper("héllo")`, object.String{Value: "HÉLLO"}},
+		{`trim("  a b  ")`, object.String{Value: "a b"}},
+		{`contains("monkey", "key")`, true},
+		{`starts_with("monkey", "key")`, false},
+		{`index_of("héllo", "llo")`, 2},
+		{`replace("a-b-c", "-", "+")`, object.String{Value: "a+b+c"}},
+		{`pad_left("7", 3, "0")`, object.String{Value: "007"}},
+		{`join(chars("héy"), "-")`, object.String{Value: "h-é-y"}},
+		{`ord(chr(233))`, 233},
+		{`lower(1)`, "Argument to `lower` must be a String. Got: INTEGER"},
+		{`repeat("a")`, "Wrong number of arguments. Got: 1, Expected: 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case object.String:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected.Value {
+				t.Errorf("object is not String %q. Got: %T (%+v)", expected.Value, evaluated, evaluated)
+			}
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`keys({3: 1, 1: 2, 2: 3})`, []int{1, 2, 3}},
+		{`values({3: 1, 1: 2, 2: 3})`, []int{2, 3, 1}},
+		{`has_key(delete({1: 1, 2: 2}, 1), 1)`, false},
+		{`merge({1: 1, 2: 2}, {2: 3})[2]`, 3},
+		{`sort([3, 1, 2, 1])`, []int{1, 1, 2, 3}},
+		{`slice([1, 2, 3, 4], -3, -1)`, []int{2, 3}},
+		{`contains([1, [2]], [2])`, true},
+		{`index_of([4, 5], 5)`, 1},
+		{`flatten([[1, 2], 3, []])`, []int{1, 2, 3}},
+		{`uniq(concat([1, 2], [1, 3]))`, []int{1, 2, 3}},
+		{`map(zip(range(3), reverse(range(3))), last)`, []int{2, 1, 0}},
+		{`len(range(9223372036854775800, 9223372036854775807, 5))`, 2},
+		{`slice([1], "a")`, "Second argument to `slice` must be an Integer. Got: STRING"},
+		{`range(1, 2, 3, 4)`, "Wrong number of arguments. Got: 4, Expected: 1, 2 or 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`type(1) == "INTEGER"`, true},
+		{`type(func() {}) == "FUNCTION"`, true},
+		{`type(len) == "BUILTIN"`, true},
+		{`int("42")`, 42},
+		{`int(false)`, 0},
+		{`int("4x")`, "Could not convert \"4x\" to an Integer"},
+		{`str(12) == "12"`, true},
+		{`bool(first([]))`, false},
+		{`bool("")`, true},
+		{`is_hash({})`, true},
+		{`is_function(func(x) { x })`, true},
+		{`is_int("1")`, false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1.5 + 2", 3.5},
+		{"3 / 2.0", 1.5},
+		{"7.5 % 2", 1.5},
+		{"-2.5", -2.5},
+		{"2.5 > 2", true},
+		{"1.0 == 1", true},
+		{"2.0 <= 1", false},
+		{`"a" + 1.5`, "Line 0: Type mismatch: STRING + FLOAT"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"math.PI", 3.141592653589793},
+		{"math.abs(-3)", 3},
+		{"math.min(3, 1.5, 2)", 1.5},
+		{"math.pow(2, 10)", 1024},
+		{"math.sqrt(2.25)", 1.5},
+		{"math.round(2.5)", 3},
+		{"math.gcd(12, 18)", 6},
+		{"math.clamp(15, 0, 10)", 10},
+		{"let area = func(r) { math.PI * math.pow(r, 2) }; math.round(area(2))", 13},
+		{"let math = 2; math", 2},
+		{"math.clamp(1, 2, 0)", "Lower bound to `math.clamp` must not be greater than the upper bound. Got: 2 and 0"},
+		{"math.min()", "`math.min` needs at least one number"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. Got: %T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. Got: %v, Expected: %v", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestRandomBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let draw = func() { str([random(), random_int(1, 100), choice([1, 2, 3]), shuffle(range(5))]) }; seed(7); let a = draw(); seed(7); a == draw()`, true},
+		{`seed(7); let a = random(); let b = random(); a == b`, false},
+		{`seed(1); recv(spawn random_int(5, 5))`, 5},
+		{`random_int(1)`, "Wrong number of arguments. Got: 1, Expected: 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+
+	// Each environment tree has a generator of its own, so drawing from one doesn't advance another
+	first, second := object.NewEnvironment(), object.NewEnvironment()
+	Eval(testParseProgram("seed(3)"), first)
+	Eval(testParseProgram("seed(3)"), second)
+	a := Eval(testParseProgram("random()"), first).Inspect()
+	Eval(testParseProgram("random()"), first)
+	if b := Eval(testParseProgram("random()"), second).Inspect(); a != b {
+		t.Errorf("environments seeded the same should draw the same numbers. Got: %s and %s", a, b)
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_encode({"b": [1, 2.5], "a": first([])}) == "{" + json_encode("a") + ":null," + json_encode("b") + ":[1,2.5]}"`, true},
+		{`let v = {"a": [1, {"b": "c"}]}; json_encode(json_decode(json_encode(v))) == json_encode(v)`, true},
+		{`json_decode("[1, 2, 3]")[2]`, 3},
+		{`json_decode("[1, 2] x")`, "Invalid JSON at line 1, column 8: unexpected data after the top-level value"},
+		{`json_encode(func() {})`, "Could not encode JSON: FUNCTION has no JSON representation"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestFileBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	input := `let p = "` + dir + `/notes.txt"; write_file(p, "a"); append_file(p, "b"); let s = read_file(p); remove(p); [s, exists(p), len(list_dir("` + dir + `"))]`
+
+	env := object.NewEnvironment()
+	env.Runtime().SetFilePolicy(object.FilePolicy{Read: []string{dir}, Write: []string{dir}})
+	if res := Eval(testParseProgram(input), env); res.Inspect() != "[ab, false, 0]" {
+		t.Errorf("file builtins returned wrong result. Got: %s", res.Inspect())
+	}
+
+	// Environments start with no file system access
+	testErrorObject(t, testEval(`exists("`+dir+`")`), "`exists` is not allowed to read "+dir)
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`exit(); 1`, 0},
+		{`let x = 5; if (x > 1) { exit(3) }; x`, 3},
+		{`map([1, 2, 3], func(x) { if (x == 2) { exit(4) }; x }); 1`, 4},
+		{`let g = func() { yield 1; exit(5) }(); next(g); next(g); 1`, 5},
+		{`recv(spawn exit(6)); 1`, 6},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok || !err.Exit {
+			t.Errorf("expected evaluation to exit, got: %+v", err)
+			continue
+		}
+		if err.Code != tt.expected {
+			t.Errorf("wrong exit status. want=%d, got=%d", tt.expected, err.Code)
+		}
+	}
+
+	env := object.NewEnvironment()
+	env.Runtime().SetStdin(strings.NewReader("a\nb\n"))
+	res := Eval(testParseProgram(`let first = read_line(); [first, stdin_lines(), env("MONKEY_TEST_UNSET")]`), env)
+	if res.Inspect() != "[a, [b], null]" {
+		t.Errorf("input builtins returned wrong result. Got: %s", res.Inspect())
+	}
+}
+
+func TestTimeBuiltins(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Runtime().SetClock(object.NewManualClock(time.UnixMilli(1000)))
+	res := Eval(testParseProgram(`let start = now(); sleep(60000); [start, now(), clock(), format_time(now(), "15:04:05")]`), env)
+	if res.Inspect() != "[1000, 61000, 60000.0, 00:01:01]" {
+		t.Errorf("time builtins returned wrong result. Got: %s", res.Inspect())
+	}
+
+	testErrorObject(t, testEval(`parse_time("x", "2006")`), `Could not parse time: parsing time "x" as "2006": cannot parse "x" as "2006"`)
+}
+
+func TestRegex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match(re"(\w+)@(\w+)", "mail bob@example now")[2] == "example"`, true},
+		{`captures(re"(?P<user>\w+)@(?P<host>\w+)", "bob@example")["user"] == "bob"`, true},
+		{`len(find_all(re"\d+", "a1b22c333"))`, 3},
+		{`replace_all(re"\d+", "a1b22", func(m) { str(int(m) * 2) }) == "a2b44"`, true},
+		{`replace_all(re"\d+", "a1", func(m) { undefined })`, "Line 0: Identifier not found: undefined"},
+		{`is_null(match(regex("^[a-z]+$"), "ab1"))`, true},
+		{`regex("(")`, "Invalid regular expression \"(\": error parsing regexp: missing closing ): `(`"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	testErrorObject(t, testEval(`sprintf("%t", 1)`), "Verb %t in the format string passed to `sprintf` needs a Boolean. Got: INTEGER")
+
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.Runtime().SetOutput(&out)
+	input := `print("a", 1); write("b", 2); printf("<%3d>", 3); each([4, 5], func(x) { write(x) }); recv(spawn print(sprintf("%v", [6])))`
+	Eval(testParseProgram(input), env)
+	if out.String() != "a\n1\nb2<  3>45[6]\n" {
+		t.Errorf("output builtins wrote wrong output. Got: %q", out.String())
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Runtime().Builtins().Register("double", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Integer{Value: 2 * args[0].(*object.Integer).Value}
+		},
+		Params: []object.ObjectType{object.IntegerObj},
+	})
+	env.Runtime().Builtins().Register("VERSION", &object.String{Value: "1.0"})
+
+	res := Eval(testParseProgram(`[double(21), VERSION, map([1, 2], double), double("a")]`), env)
+	testErrorObject(t, res, "Argument to `double` must be an Integer. Got: STRING")
+	res = Eval(testParseProgram(`[double(21), VERSION, map([1, 2], double)]`), env)
+	if res.Inspect() != "[42, 1.0, [2, 4]]" {
+		t.Errorf("registered builtins returned wrong result. Got: %s", res.Inspect())
+	}
+
+	// Other environments have registries of their own
+	testErrorObject(t, testEval(`double(1)`), "Line 0: Identifier not found: double")
+}
+
+func TestExecutionLimits(t *testing.T) {
+	loop := `let loop = func(n) { loop(n + 1) };`
+	runaway := []string{
+		loop + `loop(0)`,
+		loop + `map([1], func(x) { loop(x) })`,
+		loop + `let g = func() { loop(0); yield 1 }(); next(g)`,
+		loop + `recv(spawn loop(0))`,
+	}
+	blocked := []string{
+		`sleep(5000); 1`,
+		`let c = channel(); recv(c)`,
+		`select([channel()])`,
+	}
+
+	for _, input := range runaway {
+		env := object.NewEnvironment()
+		env.Runtime().SetLimits(object.Limits{Steps: 10000})
+		testErrorObject(t, Eval(testParseProgram(input), env), "Line 0: step limit exceeded")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		env = object.NewEnvironment()
+		env.Runtime().SetContext(ctx)
+		testErrorObject(t, Eval(testParseProgram(input), env), "Line 0: context deadline exceeded")
+		cancel()
+	}
+
+	// Builtins that block give up once the script is stopped
+	for _, input := range blocked {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		env := object.NewEnvironment()
+		env.Runtime().SetContext(ctx)
+		testErrorObject(t, Eval(testParseProgram(input), env), "Line 0: context deadline exceeded")
+		cancel()
+	}
+
+	// Scripts within their limits finish
+	env := object.NewEnvironment()
+	env.Runtime().SetLimits(object.Limits{Steps: 1000})
+	res := Eval(testParseProgram(`let sum = func(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(100, 0)`), env)
+	testIntegerObject(t, res, 5050)
+}
+
+func TestMemoryLimits(t *testing.T) {
+	hungry := []string{
+		`let grow = func(arr, n) { if (n == 0) { arr } else { grow(push(arr, n), n - 1) } }; grow([], 100000)`,
+		`let double = func(s, n) { if (n == 0) { len(s) } else { double(s + s, n - 1) } }; double("ab", 30)`,
+		`let wrap = func(x, n) { if (n == 0) { x } else { wrap([x, x, x, x], n - 1) } }; wrap(1, 100000)`,
+		`let nest = func(x, n) { if (n == 0) { x } else { nest({"a": x, "b": x}, n - 1) } }; nest(1, 100000)`,
+		`range(1000000000)`,
+		`map([1], func(x) { repeat("x", 1000000000) })`,
+	}
+
+	for _, input := range hungry {
+		env := object.NewEnvironment()
+		env.Runtime().SetLimits(object.Limits{Memory: 1 << 20})
+		res := Eval(testParseProgram(input), env)
+		if !isError(res) || env.Runtime().Stopped() != object.ErrMemoryLimit {
+			t.Errorf("expected %q to run out of memory, got: %s", input, res.Inspect())
+		}
+	}
+
+	env := object.NewEnvironment()
+	env.Runtime().SetLimits(object.Limits{Memory: 1 << 20})
+	res := Eval(testParseProgram(`let grow = func(arr, n) { if (n == 0) { arr } else { grow(push(arr, n), n - 1) } }; len(grow([], 100))`), env)
+	testIntegerObject(t, res, 100)
+}
+
+func TestSandbox(t *testing.T) {
+	builtins, err := object.Sandbox{Profile: object.Pure, Deny: []string{"len"}}.Registry()
+	if err != nil {
+		t.Fatalf("sandbox error: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`print("hi")`, "Line 0: Identifier not found: print"},
+		{`len([1])`, "Line 0: Identifier not found: len"},
+		{`map([1], func(x) { read_file("x") })`, "Line 0: Identifier not found: read_file"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Runtime().SetBuiltins(builtins)
+		testErrorObject(t, Eval(testParseProgram(tt.input), env), tt.expected)
+	}
+
+	// What the sandbox leaves out is free to be bound, and what it allows works as usual
+	env := object.NewEnvironment()
+	env.Runtime().SetBuiltins(builtins)
+	res := Eval(testParseProgram(`let print = func(x) { x * 2 }; [print(21), math.abs(-1), first(map([1], func(x) { x + 1 }))]`), env)
+	if res.Inspect() != "[42, 1, 2]" {
+		t.Errorf("sandboxed program returned wrong result. Got: %s", res.Inspect())
+	}
+}
@@ -0,0 +1,90 @@
+package evaluator
+
+import (
+	"errors"
+
+	"github.com/bradford-hamilton/monkey-lang/object"
+)
+
+// errRuntimeClosed stops generators left parked when their Runtime is closed
+var errRuntimeClosed = errors.New("runtime closed")
+
+// generator runs a generator function's body on its own goroutine. Every yield hands a value
+// to whoever called Next and parks the goroutine until it is resumed. A generator that is
+// abandoned before it finishes stays parked until the context it parked under is done or its
+// Runtime is closed, when the yield fails and the body unwinds
+type generator struct {
+	yields chan object.Object
+	// Buffered, so that resuming a generator whose goroutine has stopped doesn't block
+	resume chan struct{}
+	// Whether a yield was stopped while parked. Only the generator's goroutine uses it
+	stopped bool
+}
+
+// newGenerator creates the generator for a call of fn with args. While it runs, the environment
+// its body runs in maps to it in the Runtime's Generators, so that a yield can find who to hand
+// its value to. Blocks share their function's environment, so every yield in the body sees the
+// same one
+func newGenerator(fn *object.Function, args []object.Object) *object.Generator {
+	env := extendFunctionEnv(fn, args)
+	g := &generator{
+		yields: make(chan object.Object),
+		resume: make(chan struct{}, 1),
+	}
+	started := false
+
+	return object.NewGenerator(func() (object.Object, bool) {
+		if !started {
+			started = true
+			env.Runtime().Generators().Store(env, g)
+			go g.run(fn, env)
+		} else {
+			g.resume <- struct{}{}
+		}
+
+		val, ok := <-g.yields
+		return val, ok
+	})
+}
+
+func (g *generator) run(fn *object.Function, env *object.Environment) {
+	defer close(g.yields)
+	defer env.Runtime().Generators().Delete(env)
+
+	result := resolveTailCall(unwrapReturnValue(Eval(fn.Body, env)))
+	// Nobody is waiting for the error when the generator was stopped while parked
+	if isError(result) && !g.stopped {
+		g.yields <- result
+	}
+}
+
+// yield hands val to the caller of Next and waits to be resumed, returning the error that
+// stopped the generator instead if it is abandoned
+func (g *generator) yield(val object.Object, rt *object.Runtime) error {
+	g.yields <- val
+
+	ctx := rt.Context()
+	select {
+	case <-g.resume:
+		return nil
+	case <-ctx.Done():
+		g.stopped = true
+		return ctx.Err()
+	case <-rt.Closed():
+		g.stopped = true
+		return errRuntimeClosed
+	}
+}
+
+func evalYield(val object.Object, env *object.Environment, line int) object.Object {
+	g, ok := env.Runtime().Generators().Load(env)
+	if !ok {
+		return newError("Line %d: yield outside of generator", line)
+	}
+
+	if err := g.(*generator).yield(val, env.Runtime()); err != nil {
+		return newError("Line %d: %s", line, err)
+	}
+
+	return nil
+}
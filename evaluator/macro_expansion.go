@@ -0,0 +1,163 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/bradford-hamilton/monkey-lang/ast"
+	"github.com/bradford-hamilton/monkey-lang/object"
+)
+
+// DefineMacros finds the top-level `let name = macro(...) { ... }` statements in program, binds
+// them in env, and removes them from the program so that neither engine ever sees them
+func DefineMacros(program *ast.RootNode, env *object.Environment) {
+	definitions := []int{}
+
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+
+	for i := len(definitions) - 1; i >= 0; i-- {
+		definitionIndex := definitions[i]
+		program.Statements = append(
+			program.Statements[:definitionIndex],
+			program.Statements[definitionIndex+1:]...,
+		)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStatement, ok := node.(*ast.LetStatement)
+	if !ok {
+		return false
+	}
+
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+
+	return ok
+}
+
+func addMacro(stmt ast.Statement, env *object.Environment) {
+	letStatement, _ := stmt.(*ast.LetStatement)
+	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// ExpandMacros replaces every call to a macro bound in env (see DefineMacros) with the node the
+// macro returns. Macros are called with their arguments quoted rather than evaluated, and must
+// return a quote. Expansion happens once, before the program is evaluated or compiled
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var err error
+	// line is where the last macro call expanded, the one to blame if Modify finds its
+	// expansion doesn't fit where the call was
+	var line int
+
+	expanded, modifyErr := ast.Modify(program, func(node ast.Node) ast.Node {
+		if err != nil {
+			return node
+		}
+
+		callExpression, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		macro, ok := isMacroCall(callExpression, env)
+		if !ok {
+			return node
+		}
+
+		if len(callExpression.Arguments) != len(macro.Parameters) {
+			err = fmt.Errorf(
+				"Line %d: Wrong number of arguments to macro %s: expected %d, got %d",
+				callExpression.Token.Line,
+				callExpression.Function.String(),
+				len(macro.Parameters),
+				len(callExpression.Arguments),
+			)
+			return node
+		}
+
+		line = callExpression.Token.Line
+		args := quoteArgs(callExpression)
+		evalEnv := extendMacroEnv(macro, args)
+
+		evaluated := Eval(macro.Body, evalEnv)
+		if returnValue, ok := evaluated.(*object.ReturnValue); ok {
+			evaluated = returnValue.Value
+		}
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			err = fmt.Errorf("%s", errObj.Message)
+			return node
+		}
+
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			err = fmt.Errorf("Line %d: Macro %s must return a quote", callExpression.Token.Line, callExpression.Function.String())
+			return node
+		}
+
+		return quote.Node
+	})
+
+	if err == nil && modifyErr != nil {
+		err = fmt.Errorf("Line %d: Macro expansion failed: %s", line, modifyErr)
+	}
+	if err != nil {
+		return expanded, err
+	}
+
+	// Expansion can move calls in and out of tail position
+	ast.MarkTailCalls(expanded)
+
+	return expanded, nil
+}
+
+func isMacroCall(exp *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	identifier, ok := exp.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		return nil, false
+	}
+
+	return macro, true
+}
+
+func quoteArgs(exp *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+
+	for _, a := range exp.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+
+	return args
+}
+
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+
+	for paramIdx, param := range macro.Parameters {
+		extended.Set(param.Value, args[paramIdx])
+	}
+
+	return extended
+}
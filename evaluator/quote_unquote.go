@@ -0,0 +1,101 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/bradford-hamilton/monkey-lang/ast"
+	"github.com/bradford-hamilton/monkey-lang/object"
+	"github.com/bradford-hamilton/monkey-lang/token"
+)
+
+// quote returns node unevaluated, wrapped in an object.Quote. Any unquote(...) calls inside it
+// are evaluated first and replaced with the node their result converts to
+func quote(node ast.Node, env *object.Environment, line int) object.Object {
+	node, err := evalUnquoteCalls(node, env, line)
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment, line int) (ast.Node, *object.Error) {
+	var err *object.Error
+
+	// Modify changes the tree it walks, and quoted belongs to the code calling quote, which can
+	// run again with different values to unquote, so the values go into a copy
+	node, modifyErr := ast.Modify(ast.Copy(quoted), func(node ast.Node) ast.Node {
+		if err != nil || !isUnquoteCall(node) {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			err = newError("Line %d: unquote takes exactly one argument. Got: %d", line, len(call.Arguments))
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted.(*object.Error)
+			return node
+		}
+
+		converted, ok := convertObjectToASTNode(unquoted)
+		if !ok {
+			err = newError("Line %d: Cannot unquote a value of type %s", line, unquoted.Type())
+			return node
+		}
+
+		return converted
+	})
+	if err == nil && modifyErr != nil {
+		err = newError("Line %d: Cannot unquote: %s", line, modifyErr)
+	}
+
+	return node, err
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	return call.Function.TokenLiteral() == "unquote"
+}
+
+func convertObjectToASTNode(obj object.Object) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.Integer, Literal: fmt.Sprintf("%d", obj.Value)}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Float:
+		t := token.Token{Type: token.Float, Literal: obj.Inspect()}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Regex:
+		t := token.Token{Type: token.Regex, Literal: obj.Value.String()}
+		return &ast.RegexLiteral{Token: t, Value: obj.Value.String(), Regex: obj.Value}, true
+
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.True, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.False, Literal: "false"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, true
+
+	case *object.String:
+		t := token.Token{Type: token.String, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Quote:
+		return obj.Node, true
+
+	default:
+		return nil, false
+	}
+}
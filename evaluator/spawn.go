@@ -0,0 +1,42 @@
+package evaluator
+
+import (
+	"github.com/bradford-hamilton/monkey-lang/ast"
+	"github.com/bradford-hamilton/monkey-lang/object"
+)
+
+// evalSpawn evaluates the callee and its arguments on the current goroutine, then applies the
+// function on a new one. The returned channel receives the call's result (or its error) once it
+// finishes. Spawned code shares its closure's environment, which is safe because Environments
+// guard their stores and every other value is never mutated after it is created
+func evalSpawn(node *ast.SpawnExpression, env *object.Environment) object.Object {
+	fn := Eval(node.Call.Function, env)
+	if isError(fn) {
+		return fn
+	}
+	args := evalExprs(node.Call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	switch fn := fn.(type) {
+	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("Line %d: Wrong number of arguments: expected %d, got %d", node.Token.Line, len(fn.Parameters), len(args))
+		}
+	case *object.Builtin:
+	default:
+		return newError("Line %d: Not a function: %s", node.Token.Line, fn.Type())
+	}
+
+	result := object.NewChannel(1)
+	go func() {
+		val := applyFunction(fn, args, env, node.Token.Line)
+		if val == nil {
+			val = Null
+		}
+		result.Send(val)
+	}()
+
+	return result
+}
@@ -0,0 +1,34 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/bradford-hamilton/monkey-lang/object"
+)
+
+// tailCallObj is the ObjectType of a tailCall. It never escapes the evaluator
+const tailCallObj = "TAIL_CALL"
+
+// tailCall is what a call in tail position (see ast.MarkTailCalls) evaluates to. Rather than
+// calling fn, the evaluator passes it back up to the applyFunction that is running the enclosing
+// function, which then calls fn itself once the enclosing function's frame is gone
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	line int
+}
+
+// Type returns our tailCall's ObjectType (tailCallObj)
+func (tc *tailCall) Type() object.ObjectType { return tailCallObj }
+
+// Inspect returns a string representation of the tailCall
+func (tc *tailCall) Inspect() string { return fmt.Sprintf("tail call: %s", tc.fn.Inspect()) }
+
+// resolveTailCall makes obj's call if it is a tailCall, so that bodies run outside of
+// applyFunction don't leak one
+func resolveTailCall(obj object.Object) object.Object {
+	if tc, ok := obj.(*tailCall); ok {
+		return applyFunction(tc.fn, tc.args, tc.fn.Env, tc.line)
+	}
+	return obj
+}
@@ -81,6 +81,21 @@ func (l *Lexer) readInteger() string {
 	return string(l.input[position:l.position])
 }
 
+// readNumber reads an integer, or a float if the digits are followed by a decimal point and more
+// digits (1.5). A dot followed by anything else is left alone
+func (l *Lexer) readNumber() (string, token.Type) {
+	position := l.position
+	l.readInteger()
+
+	if l.char != '.' || !isInteger(l.peek()) {
+		return string(l.input[position:l.position]), token.Integer
+	}
+	l.readChar()
+	l.readInteger()
+
+	return string(l.input[position:l.position]), token.Float
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.char == ' ' || l.char == '\t' || l.char == '\n' || l.char == '\r' {
 		if l.char == '\n' {
@@ -227,6 +242,8 @@ func (l *Lexer) NextToken() token.Token {
 		t = newToken(token.Comma, l.line, l.char)
 	case ':':
 		t = newToken(token.Colon, l.line, l.char)
+	case '.':
+		t = newToken(token.Dot, l.line, l.char)
 	case ';':
 		t = newToken(token.Semicolon, l.line, l.char)
 	case '(':
@@ -254,10 +271,15 @@ func (l *Lexer) NextToken() token.Token {
 			t.Literal = l.readIdentifier()
 			t.Type = token.LookupIdentifier(t.Literal)
 			t.Line = l.line
+			// A string straight after "re" is a regular expression literal: re"[a-z]+"
+			if t.Literal == "re" && l.char == '"' {
+				t.Type = token.Regex
+				t.Literal = l.readString()
+				l.readChar()
+			}
 			return t
 		} else if isInteger(l.char) {
-			t.Literal = l.readInteger()
-			t.Type = token.Integer
+			t.Literal, t.Type = l.readNumber()
 			t.Line = l.line
 			return t
 		} else {
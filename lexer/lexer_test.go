@@ -60,6 +60,9 @@ five--
 */
 
 let snake_case_with_question_mark? = true;
+
+enum Color { Red, Green }
+Color.Red
 `
 
 	tests := []struct {
@@ -189,7 +192,17 @@ let snake_case_with_question_mark? = true;
 		{token.Equal, "=", 49},
 		{token.True, "true", 49},
 		{token.Semicolon, ";", 49},
-		{token.EOF, "", 50},
+		{token.Enum, "enum", 51},
+		{token.Identifier, "Color", 51},
+		{token.LeftBrace, "{", 51},
+		{token.Identifier, "Red", 51},
+		{token.Comma, ",", 51},
+		{token.Identifier, "Green", 51},
+		{token.RightBrace, "}", 51},
+		{token.Identifier, "Color", 52},
+		{token.Dot, ".", 52},
+		{token.Identifier, "Red", 52},
+		{token.EOF, "", 53},
 	}
 
 	l := New(input)
@@ -210,3 +223,60 @@ let snake_case_with_question_mark? = true;
 		}
 	}
 }
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.Float, "3.14"},
+		{token.Integer, "10"},
+		{token.Float, "0.5"},
+		{token.Integer, "7"},
+		{token.Dot, "."},
+		{token.Identifier, "x"},
+		{token.Integer, "1"},
+		{token.Dot, "."},
+		{token.EOF, ""},
+	}
+
+	l := New("3.14 10 0.5 7.x 1.")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. Expected: %q, Got: %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. Expected: %q, Got: %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestRegexLiterals(t *testing.T) {
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.Regex, `(\d+)-(?P<word>\w*)`},
+		{token.Identifier, "re"},
+		{token.Identifier, "re"},
+		{token.String, "x"},
+		{token.Identifier, "are"},
+		{token.String, "y"},
+		{token.Regex, ""},
+		{token.EOF, ""},
+	}
+
+	l := New(`re"(\d+)-(?P<word>\w*)" re re "x" are"y" re""`)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. Expected: %q, Got: %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. Expected: %q, Got: %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
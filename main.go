@@ -1,83 +1,122 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
-	"github.com/bradford-hamilton/monkey-lang/ast"
-	"github.com/bradford-hamilton/monkey-lang/compiler"
-	"github.com/bradford-hamilton/monkey-lang/evaluator"
-	"github.com/bradford-hamilton/monkey-lang/lexer"
+	"github.com/bradford-hamilton/monkey-lang/monkey"
 	"github.com/bradford-hamilton/monkey-lang/object"
-	"github.com/bradford-hamilton/monkey-lang/parser"
 	"github.com/bradford-hamilton/monkey-lang/repl"
-	"github.com/bradford-hamilton/monkey-lang/vm"
 )
 
 func main() {
 	// Define and parse flag options
 	engine := flag.String("engine", "vm", "Engine options are \"vm\" or \"eval\"")
 	console := flag.Bool("console", false, "Provide console flag to enter interactive repl")
+	allowRead := flag.String("allow-read", "", "Comma separated directories scripts may read from")
+	allowWrite := flag.String("allow-write", "", "Comma separated directories scripts may write to")
+	timeout := flag.Duration("timeout", 0, "Stop scripts that run for longer than this, e.g. 5s (no limit by default)")
+	maxSteps := flag.Int64("max-steps", 0, "Stop scripts that take more steps than this (no limit by default)")
+	sandbox := flag.String("sandbox", "full", "Builtins scripts may use: \"pure\", \"standard\" or \"full\"")
+	allowBuiltins := flag.String("allow-builtins", "", "Comma separated builtins to allow on top of the sandbox")
+	denyBuiltins := flag.String("deny-builtins", "", "Comma separated builtins to take away from the sandbox")
+	maxMemory := flag.Int64("max-memory", 0, "Stop scripts that create more than this many bytes of arrays, hashes and strings (no limit by default)")
 	flag.Parse()
 
 	if *engine != "vm" && *engine != "eval" {
-		fmt.Printf("Engine must be either 'vm' or 'eval', got %s\n", *engine)
-		return
+		fmt.Fprintf(os.Stderr, "Engine must be either 'vm' or 'eval', got %s\n", *engine)
+		os.Exit(2)
 	}
 
-	// If console flag is provided, run interactive console - otherwise read file and execute
-	if *console {
-		repl.Start(os.Stdin, os.Stdout, engine)
-	} else {
-		if len(flag.Args()) != 1 {
-			fmt.Println("Incorrect usage. Usage: `monkey [option...] filePath`")
-			return
-		}
-
-		var result object.Object
-
-		filePath := flag.Args()[0]
-		contents, err := os.ReadFile(filePath)
-		if err != nil {
-			fmt.Printf("Failure to read file '%s'. Err: %s", string(contents), err)
-		}
+	builtins, err := object.Sandbox{
+		Profile: object.Profile(*sandbox),
+		Allow:   splitList(*allowBuiltins),
+		Deny:    splitList(*denyBuiltins),
+	}.Registry()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-		l := lexer.New(string(contents))
-		p := parser.New(l)
-		program := p.ParseProgram()
+	opts := []monkey.Option{
+		// Scripts get no file system access unless it's granted with the allow flags
+		monkey.WithFilePolicy(object.FilePolicy{
+			Read:  splitList(*allowRead),
+			Write: splitList(*allowWrite),
+		}),
+		monkey.WithTimeout(*timeout),
+		monkey.WithLimits(object.Limits{Steps: *maxSteps, Memory: *maxMemory}),
+		monkey.WithBuiltins(builtins),
+	}
+	if *engine == "eval" {
+		opts = append(opts, monkey.WithEngine(monkey.Evaluator))
+	}
 
-		if *engine == "vm" {
-			result = compileBytecodeAndRun(program)
-		} else {
-			result = evaluateAst(program)
-		}
+	// If console flag is provided, run interactive console - otherwise read file and execute.
+	// Either way the code runs with the sandbox, file access and limits the flags ask for
+	if *console {
+		os.Exit(repl.Start(os.Stdin, os.Stdout, monkey.NewInterpreter(opts...)))
+	}
 
-		fmt.Println(result.Inspect())
+	if len(flag.Args()) < 1 {
+		fmt.Fprintln(os.Stderr, "Incorrect usage. Usage: `monkey [option...] filePath [arg...]`")
+		os.Exit(2)
 	}
-}
 
-// Evaluate the AST with evaluator
-func evaluateAst(program *ast.RootNode) object.Object {
-	env := object.NewEnvironment()
-	return evaluator.Eval(program, env)
+	os.Exit(runFile(flag.Args()[0], flag.Args()[1:], monkey.NewInterpreter(opts...)))
 }
 
-// Compile program to bytecode, pass to VM, and run. Returns the last popped stack element (result)
-func compileBytecodeAndRun(program *ast.RootNode) object.Object {
-	comp := compiler.New()
-
-	err := comp.Compile(program)
+// Run the script at filePath, with args bound to `args`, and return the exit status for the
+// process: the one the script gave to `exit`, otherwise 1 if it failed and 0 if it didn't
+func runFile(filePath string, args []string, in *monkey.Interpreter) int {
+	contents, err := os.ReadFile(filePath)
 	if err != nil {
-		fmt.Printf("compiler error: %s", err)
+		fmt.Fprintf(os.Stderr, "Failure to read file '%s'. Err: %s\n", filePath, err)
+		return 1
 	}
 
-	vm := vm.New(comp.Bytecode())
-
-	err = vm.Run()
-	if err != nil {
-		fmt.Printf("vm error: %s", err)
+	scriptArgs := &object.Array{Elements: make([]object.Object, len(args))}
+	for i, arg := range args {
+		scriptArgs.Elements[i] = &object.String{Value: arg}
 	}
+	in.SetGlobal("args", scriptArgs)
+
+	result, err := in.Eval(context.Background(), string(contents))
+
+	var parseErr *monkey.ParseError
+	var runtimeErr *monkey.RuntimeError
+	var exit *monkey.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return exit.Code
+	case errors.As(err, &parseErr):
+		for _, msg := range parseErr.Messages {
+			fmt.Fprintf(os.Stderr, "parser error: %s\n", msg)
+		}
+		return 1
+	case errors.As(err, &runtimeErr):
+		fmt.Fprintf(os.Stderr, "Error: %s\n", runtimeErr.Message)
+		return 1
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Println(result.Inspect())
+	return 0
+}
 
-	return vm.LastPoppedStackElement()
+// Split a comma separated flag value into its entries, ignoring empty ones
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
 }
@@ -0,0 +1,34 @@
+package monkey
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError is returned for source that doesn't parse. It holds the parser's message for each problem
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string {
+	return "parser error: " + strings.Join(e.Messages, "; ")
+}
+
+// RuntimeError is returned when a script fails while running. Message is the error message the
+// engine gave, the same one a script sees for errors returned to it as values
+type RuntimeError struct {
+	Message string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+// ExitError is returned when a script stops itself with `exit`. Code is the exit status it gave
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
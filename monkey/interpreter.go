@@ -0,0 +1,314 @@
+// Package monkey runs Monkey code from Go programs. An Interpreter wraps the lexer, parser, macro
+// expansion and either engine behind a few calls, and reports problems as Go errors:
+//
+//	in := monkey.NewInterpreter(monkey.WithEngine(monkey.Evaluator))
+//	in.SetGlobal("name", &object.String{Value: "world"})
+//	if _, err := in.Eval(ctx, `let greet = func(greeting) { greeting + ", " + name }`); err != nil {
+//		return err
+//	}
+//	greeting, err := in.Call(ctx, "greet", &object.String{Value: "Hello"})
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bradford-hamilton/monkey-lang/ast"
+	"github.com/bradford-hamilton/monkey-lang/compiler"
+	"github.com/bradford-hamilton/monkey-lang/evaluator"
+	"github.com/bradford-hamilton/monkey-lang/lexer"
+	"github.com/bradford-hamilton/monkey-lang/object"
+	"github.com/bradford-hamilton/monkey-lang/parser"
+	"github.com/bradford-hamilton/monkey-lang/vm"
+)
+
+// Engine selects how an Interpreter runs code
+type Engine int
+
+const (
+	// VM compiles code to bytecode and runs it on the virtual machine. It is the default
+	VM Engine = iota
+	// Evaluator walks the AST with the tree-walking evaluator
+	Evaluator
+)
+
+// Interpreter runs Monkey code, keeping its globals, macros and builtin state from one call of Eval
+// to the next the way the REPL does. An Interpreter is not safe for concurrent use
+type Interpreter struct {
+	engine  Engine
+	runtime *object.Runtime
+
+	limits  object.Limits
+	timeout time.Duration
+
+	macroEnv *object.Environment
+
+	// Evaluator state
+	env *object.Environment
+
+	// VM state
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+// Option configures an Interpreter created by NewInterpreter
+type Option func(*Interpreter)
+
+// WithEngine sets the engine the Interpreter runs code with (VM by default)
+func WithEngine(engine Engine) Option {
+	return func(in *Interpreter) { in.engine = engine }
+}
+
+// WithOutput sets where print, write and printf write to (os.Stdout by default)
+func WithOutput(w io.Writer) Option {
+	return func(in *Interpreter) { in.runtime.SetOutput(w) }
+}
+
+// WithInput sets where read_line and stdin_lines read from (os.Stdin by default)
+func WithInput(r io.Reader) Option {
+	return func(in *Interpreter) { in.runtime.SetStdin(r) }
+}
+
+// WithClock sets where the time builtins get the time from (the system clock by default)
+func WithClock(clock object.Clock) Option {
+	return func(in *Interpreter) { in.runtime.SetClock(clock) }
+}
+
+// WithFilePolicy sets which files the file builtins may use (none by default)
+func WithFilePolicy(policy object.FilePolicy) Option {
+	return func(in *Interpreter) { in.runtime.SetFilePolicy(policy) }
+}
+
+// WithBuiltins sets the builtins the Interpreter's scripts can use (object.DefaultRegistry() by
+// default). Sandboxed scripts get the Registry of an object.Sandbox. Interpreters may share a
+// Registry as long as none of them adds to it while another runs
+func WithBuiltins(builtins *object.Registry) Option {
+	return func(in *Interpreter) { in.runtime.SetBuiltins(builtins) }
+}
+
+// WithLimits bounds how much work each call of Eval or Call may do (no limits by default). Code
+// that goes over them stops with object.ErrStepLimit or object.ErrMemoryLimit, and the
+// Interpreter can go on running code afterwards
+func WithLimits(limits object.Limits) Option {
+	return func(in *Interpreter) { in.limits = limits }
+}
+
+// WithTimeout stops each call of Eval or Call that runs for longer than timeout with
+// context.DeadlineExceeded (no timeout by default)
+func WithTimeout(timeout time.Duration) Option {
+	return func(in *Interpreter) { in.timeout = timeout }
+}
+
+// NewInterpreter creates an Interpreter configured by opts
+func NewInterpreter(opts ...Option) *Interpreter {
+	env := object.NewEnvironment()
+	in := &Interpreter{
+		engine:   VM,
+		runtime:  env.Runtime(),
+		macroEnv: object.NewEnvironmentWithRuntime(env.Runtime()),
+		env:      env,
+	}
+	for _, opt := range opts {
+		opt(in)
+	}
+
+	if in.engine == VM {
+		in.symbolTable = compiler.NewSymbolTable()
+		in.symbolTable.DefineBuiltins(in.runtime.Builtins())
+		for i, v := range object.Modules {
+			in.symbolTable.DefineModule(i, v.Name)
+		}
+		in.constants = []object.Object{}
+		in.globals = make([]object.Object, vm.GlobalsSize)
+	}
+
+	return in
+}
+
+// Engine returns the engine the Interpreter runs code with
+func (in *Interpreter) Engine() Engine {
+	return in.engine
+}
+
+// Runtime returns the state the Interpreter's builtins keep, such as its random number generator
+func (in *Interpreter) Runtime() *object.Runtime {
+	return in.runtime
+}
+
+// Eval runs source and returns the value of its last expression, or null if it doesn't end in
+// one. Failures are returned as a *ParseError, a *RuntimeError or, when the script calls `exit`,
+// an *ExitError. Once ctx is done the script stops with ctx's error, and a script that goes over
+// the Interpreter's limits stops with their error, such as object.ErrStepLimit
+func (in *Interpreter) Eval(ctx context.Context, source string) (object.Object, error) {
+	ctx, cancel := in.start(ctx)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Messages: p.Errors()}
+	}
+
+	evaluator.DefineMacros(program, in.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, in.macroEnv)
+	if err != nil {
+		if stopped := in.runtime.Stopped(); stopped != nil {
+			return nil, stopped
+		}
+		return nil, fmt.Errorf("macro expansion error: %w", err)
+	}
+	program = expanded.(*ast.RootNode)
+
+	if in.engine == Evaluator {
+		return in.result(evaluator.Eval(program, in.env), nil)
+	}
+
+	comp := compiler.NewWithState(in.symbolTable, in.constants)
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("compiler error: %w", err)
+	}
+	bytecode := comp.Bytecode()
+	in.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsState(bytecode, in.globals, vm.WithRuntime(in.runtime))
+	if err := machine.Run(); err != nil {
+		return in.result(nil, err)
+	}
+
+	return in.result(machine.LastPoppedStackElement(), nil)
+}
+
+// Call calls the function bound to the global name with args and returns its result. It fails
+// and stops the way Eval does
+func (in *Interpreter) Call(ctx context.Context, name string, args ...object.Object) (object.Object, error) {
+	ctx, cancel := in.start(ctx)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fn, ok := in.GetGlobal(name)
+	if !ok {
+		return nil, fmt.Errorf("undefined function %s", name)
+	}
+	switch fn.Type() {
+	case object.FunctionObj, object.ClosureObj, object.BuiltinObj:
+	default:
+		return nil, fmt.Errorf("%s is not a function. Got: %s", name, fn.Type())
+	}
+
+	if in.engine == Evaluator {
+		return in.result(evaluator.CallFunction(fn, args, in.env), nil)
+	}
+
+	machine := vm.NewWithGlobalsState(&compiler.Bytecode{Constants: in.constants}, in.globals, vm.WithRuntime(in.runtime))
+	return in.result(machine.CallFunction(fn, args...))
+}
+
+// start gets the runtime ready for a call of Eval or Call: it stops code once ctx, bounded by the
+// Interpreter's timeout, is done, and counts steps afresh
+func (in *Interpreter) start(ctx context.Context) (context.Context, context.CancelFunc) {
+	var cancel context.CancelFunc = func() {}
+	if in.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, in.timeout)
+	}
+	in.runtime.SetContext(ctx)
+	in.runtime.SetLimits(in.limits)
+	return ctx, cancel
+}
+
+// Close stops what the Interpreter's code left running in the background, such as generators
+// that were never run to the end. The Interpreter shouldn't be used afterwards
+func (in *Interpreter) Close() {
+	in.runtime.Close()
+}
+
+// Register makes value available to the code the Interpreter runs afterwards as the builtin name,
+// in the Interpreter's Registry. Register a Go function as an *object.Builtin, whose Params can
+// check its arguments:
+//
+//	in.Register("shout", &object.Builtin{
+//		Fn: func(args ...object.Object) object.Object {
+//			return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value) + "!"}
+//		},
+//		Params: []object.ObjectType{object.StringObj},
+//	})
+func (in *Interpreter) Register(name string, value object.Object) {
+	i := in.runtime.Builtins().Register(name, value)
+	if in.engine == VM {
+		in.symbolTable.DefineBuiltin(i, name)
+	}
+}
+
+// SetGlobal binds value to the global name, as `let` would at the top level of a script
+func (in *Interpreter) SetGlobal(name string, value object.Object) {
+	if in.engine == Evaluator {
+		in.env.Set(name, value)
+		return
+	}
+
+	symbol, ok := in.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		symbol = in.symbolTable.Define(name)
+	}
+	if lock := in.runtime.GlobalsLock(); lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	in.globals[symbol.Index] = value
+}
+
+// GetGlobal returns the value bound to the global name, reporting false if there isn't one
+func (in *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	if in.engine == Evaluator {
+		return in.env.Get(name)
+	}
+
+	symbol, ok := in.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		return nil, false
+	}
+	if lock := in.runtime.GlobalsLock(); lock != nil {
+		lock.RLock()
+		defer lock.RUnlock()
+	}
+	if in.globals[symbol.Index] == nil {
+		return nil, false
+	}
+	return in.globals[symbol.Index], true
+}
+
+// result turns what an engine produced into what Eval and Call return: error objects become
+// errors, failures caused by the runtime stopping the code become the error it stopped with, and
+// no value becomes null
+func (in *Interpreter) result(obj object.Object, err error) (object.Object, error) {
+	if exit, ok := err.(*vm.ExitError); ok {
+		return nil, &ExitError{Code: exit.Code}
+	}
+	if e, ok := obj.(*object.Error); (ok && !e.Exit) || err != nil {
+		if stopped := in.runtime.Stopped(); stopped != nil {
+			return nil, stopped
+		}
+	}
+	if err != nil {
+		return nil, &RuntimeError{Message: err.Error()}
+	}
+
+	switch obj := obj.(type) {
+	case nil:
+		return object.NullValue, nil
+	case *object.Error:
+		if obj.Exit {
+			return nil, &ExitError{Code: obj.Code}
+		}
+		return nil, &RuntimeError{Message: obj.Message}
+	default:
+		return obj, nil
+	}
+}
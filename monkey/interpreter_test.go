@@ -0,0 +1,393 @@
+package monkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradford-hamilton/monkey-lang/object"
+)
+
+var engines = []struct {
+	name   string
+	engine Engine
+}{
+	{"vm", VM},
+	{"eval", Evaluator},
+}
+
+func TestEval(t *testing.T) {
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine))
+		ctx := context.Background()
+
+		if _, err := in.Eval(ctx, `let add = func(a, b) { a + b }; let total = 1;`); err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+		// Globals, functions and macros are kept between calls
+		if _, err := in.Eval(ctx, `let unless = macro(cond, a, b) { quote(if (!(unquote(cond))) { unquote(a) } else { unquote(b) }) };`); err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+		res, err := in.Eval(ctx, `unless(false, add(total, 41), 0)`)
+		if err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+		if res.Inspect() != "42" {
+			t.Errorf("%s: Eval returned wrong result. Expected: 42. Got: %s", e.name, res.Inspect())
+		}
+		// Every call of a macro expands with its own arguments
+		res, err = in.Eval(ctx, `[unless(true, 1, 2), unless(false, 3, 4)]`)
+		if err != nil || res.Inspect() != "[2, 3]" {
+			t.Errorf("%s: Eval returned wrong result. Expected: [2, 3]. Got: %v, %v", e.name, res, err)
+		}
+
+		if res, err := in.Eval(ctx, ``); err != nil || res != object.NullValue {
+			t.Errorf("%s: Eval of nothing should return null. Got: %v, %v", e.name, res, err)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine))
+		ctx := context.Background()
+
+		var parseErr *ParseError
+		if _, err := in.Eval(ctx, `let = 1;`); !errors.As(err, &parseErr) || len(parseErr.Messages) == 0 {
+			t.Errorf("%s: expected a *ParseError. Got: %v", e.name, err)
+		}
+
+		var runtimeErr *RuntimeError
+		if _, err := in.Eval(ctx, `len(1)`); !errors.As(err, &runtimeErr) || runtimeErr.Message != "Argument to `len` not supported. Got: INTEGER" {
+			t.Errorf("%s: expected a *RuntimeError. Got: %v", e.name, err)
+		}
+
+		var exitErr *ExitError
+		if _, err := in.Eval(ctx, `map([1], func(x) { exit(x + 2) })`); !errors.As(err, &exitErr) || exitErr.Code != 3 {
+			t.Errorf("%s: expected an *ExitError with code 3. Got: %v", e.name, err)
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := in.Eval(cancelled, `1`); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled. Got: %v", e.name, err)
+		}
+	}
+
+	if _, err := NewInterpreter().Eval(context.Background(), `x`); err == nil || err.Error() != "compiler error: undefined variable x" {
+		t.Errorf("expected a compiler error. Got: %v", err)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine))
+		ctx := context.Background()
+
+		if _, ok := in.GetGlobal("name"); ok {
+			t.Errorf("%s: GetGlobal should not find an undefined global", e.name)
+		}
+
+		in.SetGlobal("name", &object.String{Value: "world"})
+		if _, err := in.Eval(ctx, `let greet = func(greeting) { greeting + ", " + name }; let count = 2;`); err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+
+		// Setting a global again replaces it, for code run afterwards
+		in.SetGlobal("name", &object.String{Value: "monkey"})
+		res, err := in.Call(ctx, "greet", &object.String{Value: "Hello"})
+		if err != nil {
+			t.Fatalf("%s: Call returned error: %s", e.name, err)
+		}
+		if res.Inspect() != "Hello, monkey" {
+			t.Errorf("%s: Call returned wrong result. Expected: Hello, monkey. Got: %s", e.name, res.Inspect())
+		}
+
+		if count, ok := in.GetGlobal("count"); !ok || count.Inspect() != "2" {
+			t.Errorf("%s: GetGlobal returned wrong result. Got: %v, %v", e.name, count, ok)
+		}
+
+		var runtimeErr *RuntimeError
+		if _, err := in.Call(ctx, "greet"); !errors.As(err, &runtimeErr) {
+			t.Errorf("%s: expected a *RuntimeError for a call with too few arguments. Got: %v", e.name, err)
+		}
+		if _, err := in.Call(ctx, "count"); err == nil || err.Error() != "count is not a function. Got: INTEGER" {
+			t.Errorf("%s: expected an error calling a non-function. Got: %v", e.name, err)
+		}
+		if _, err := in.Call(ctx, "missing"); err == nil || err.Error() != "undefined function missing" {
+			t.Errorf("%s: expected an error calling an undefined function. Got: %v", e.name, err)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	for _, e := range engines {
+		var out bytes.Buffer
+		in := NewInterpreter(
+			WithEngine(e.engine),
+			WithOutput(&out),
+			WithInput(bytes.NewBufferString("line\n")),
+			WithClock(object.NewManualClock(time.UnixMilli(0))),
+		)
+		if in.Engine() != e.engine {
+			t.Errorf("%s: Engine returned wrong engine. Got: %d", e.name, in.Engine())
+		}
+
+		if _, err := in.Eval(context.Background(), `sleep(5); printf("%s at %d", read_line(), now())`); err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+		if out.String() != "line at 5" {
+			t.Errorf("%s: wrong output. Got: %q", e.name, out.String())
+		}
+
+		var runtimeErr *RuntimeError
+		if _, err := in.Eval(context.Background(), `read_file("go.mod")`); !errors.As(err, &runtimeErr) {
+			t.Errorf("%s: file builtins should be denied by default. Got: %v", e.name, err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	shared := object.DefaultRegistry()
+	shared.Register("VERSION", &object.String{Value: "1.0"})
+
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine), WithBuiltins(shared))
+		ctx := context.Background()
+
+		in.Register("shout", &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				return &object.String{Value: args[0].(*object.String).Value + "!"}
+			},
+			Params: []object.ObjectType{object.StringObj},
+		})
+
+		res, err := in.Eval(ctx, `shout("hi " + VERSION)`)
+		if err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+		if res.Inspect() != "hi 1.0!" {
+			t.Errorf("%s: wrong result. Expected: hi 1.0!. Got: %s", e.name, res.Inspect())
+		}
+
+		var runtimeErr *RuntimeError
+		if _, err := in.Eval(ctx, `shout(1)`); !errors.As(err, &runtimeErr) || runtimeErr.Message != "Argument to `shout` must be a String. Got: INTEGER" {
+			t.Errorf("%s: expected a *RuntimeError. Got: %v", e.name, err)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	type point struct {
+		X int `monkey:"x"`
+		Y int `monkey:"y"`
+	}
+
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine))
+		ctx := context.Background()
+
+		origin, err := object.FromGo(point{X: 1, Y: 2})
+		if err != nil {
+			t.Fatalf("%s: FromGo returned error: %s", e.name, err)
+		}
+		in.SetGlobal("origin", origin)
+
+		move, err := object.FromGo(func(p point, dx, dy int) point { return point{X: p.X + dx, Y: p.Y + dy} })
+		if err != nil {
+			t.Fatalf("%s: FromGo returned error: %s", e.name, err)
+		}
+		in.Register("move", move)
+
+		res, err := in.Eval(ctx, `move(origin, 10, 20)`)
+		if err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+		var p point
+		if err := object.ToGo(res, &p); err != nil {
+			t.Fatalf("%s: ToGo returned error: %s", e.name, err)
+		}
+		if p != (point{X: 11, Y: 22}) {
+			t.Errorf("%s: wrong result. Expected: {11 22}. Got: %+v", e.name, p)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	loop := `let loop = func(n) { loop(n + 1) };`
+
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine), WithLimits(object.Limits{Steps: 10000}))
+		ctx := context.Background()
+
+		if _, err := in.Eval(ctx, loop+`loop(0)`); !errors.Is(err, object.ErrStepLimit) {
+			t.Errorf("%s: expected Eval to run out of steps. Got: %v", e.name, err)
+		}
+		if _, err := in.Call(ctx, "loop", &object.Integer{Value: 0}); !errors.Is(err, object.ErrStepLimit) {
+			t.Errorf("%s: expected Call to run out of steps. Got: %v", e.name, err)
+		}
+		if _, err := in.Eval(ctx, `let m = macro() { let spin = func(n) { spin(n + 1) }; spin(0); quote(1) }; m()`); !errors.Is(err, object.ErrStepLimit) {
+			t.Errorf("%s: expected macro expansion to run out of steps. Got: %v", e.name, err)
+		}
+
+		// Every call gets a fresh budget, and a script's own failures aren't mistaken for it running out
+		res, err := in.Eval(ctx, `let sum = func(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(100, 0)`)
+		if err != nil || res.Inspect() != "5050" {
+			t.Errorf("%s: expected Eval within the limits to finish. Got: %v, %v", e.name, res, err)
+		}
+		var runtimeErr *RuntimeError
+		if _, err := in.Eval(ctx, `1 + true`); !errors.As(err, &runtimeErr) {
+			t.Errorf("%s: expected a *RuntimeError. Got: %v", e.name, err)
+		}
+
+		in = NewInterpreter(WithEngine(e.engine), WithTimeout(20*time.Millisecond))
+		if _, err := in.Eval(ctx, loop+`loop(0)`); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected Eval to time out. Got: %v", e.name, err)
+		}
+
+		// Builtins that block give up when the time is up too
+		for _, src := range []string{`sleep(5000); 1`, `let c = channel(); recv(c)`, `send(channel(), 1)`, `select([channel()])`} {
+			start := time.Now()
+			if _, err := in.Eval(ctx, src); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%s: expected %q to time out. Got: %v", e.name, src, err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("%s: %q took %s to time out", e.name, src, elapsed)
+			}
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := in.Eval(cancelled, `1`); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected Eval to be cancelled. Got: %v", e.name, err)
+		}
+		if _, err := in.Call(cancelled, "loop", &object.Integer{Value: 0}); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected Call to be cancelled. Got: %v", e.name, err)
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine), WithLimits(object.Limits{Memory: 1 << 20}))
+		ctx := context.Background()
+
+		_, err := in.Eval(ctx, `let grow = func(arr, n) { if (n == 0) { arr } else { grow(push(arr, n), n - 1) } }; grow([], 100000)`)
+		if !errors.Is(err, object.ErrMemoryLimit) {
+			t.Errorf("%s: expected Eval to run out of memory. Got: %v", e.name, err)
+		}
+
+		// Running out of memory doesn't break the Interpreter, and every call gets a fresh budget
+		res, err := in.Eval(ctx, `len(grow([], 100))`)
+		if err != nil || res.Inspect() != "100" {
+			t.Errorf("%s: expected Eval within the limit to finish. Got: %v, %v", e.name, res, err)
+		}
+
+		// Only what builtins build counts, not values they hand back that already existed
+		res, err = in.Eval(ctx, `let big = repeat("x", 100000); let f = func(i) { if (i == 0) { 0 } else { first([big]); f(i - 1) } }; f(100); len(str(big))`)
+		if err != nil || res.Inspect() != "100000" {
+			t.Errorf("%s: expected returning existing values not to count. Got: %v, %v", e.name, res, err)
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	builtins, err := object.Sandbox{Profile: object.Standard}.Registry()
+	if err != nil {
+		t.Fatalf("sandbox error: %s", err)
+	}
+
+	for _, e := range engines {
+		var out bytes.Buffer
+		in := NewInterpreter(WithEngine(e.engine), WithBuiltins(builtins), WithOutput(&out))
+		ctx := context.Background()
+
+		if _, err := in.Eval(ctx, `print("hi")`); err != nil || out.String() != "hi\n" {
+			t.Errorf("%s: expected print to work in the standard profile. Got: %q, %v", e.name, out.String(), err)
+		}
+		if _, err := in.Eval(ctx, `exit(1)`); err == nil || !strings.Contains(err.Error(), "exit") {
+			t.Errorf("%s: expected exit to be undefined in the standard profile. Got: %v", e.name, err)
+		}
+
+	}
+
+	pure, err := object.Sandbox{Profile: object.Pure}.Registry()
+	if err != nil {
+		t.Fatalf("sandbox error: %s", err)
+	}
+
+	// Macros expand with the Interpreter's builtins, output and limits too
+	for _, e := range engines {
+		var out bytes.Buffer
+		in := NewInterpreter(WithEngine(e.engine), WithBuiltins(pure), WithOutput(&out))
+		ctx := context.Background()
+
+		for _, name := range []string{"env", "print"} {
+			_, err := in.Eval(ctx, `let m = macro() { let h = `+name+`("HOME"); quote(1) }; m()`)
+			if err == nil || !strings.Contains(err.Error(), "Identifier not found: "+name) {
+				t.Errorf("%s: expected %s to be undefined in a pure macro. Got: %v", e.name, name, err)
+			}
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s: a pure macro should not write output. Got: %q", e.name, out.String())
+		}
+	}
+}
+
+func TestLimitsWithSpawnedCode(t *testing.T) {
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine))
+		ctx := context.Background()
+
+		// Code spawned by one Eval keeps running while the next ones start
+		if _, err := in.Eval(ctx, `let loop = func(n) { loop(n + 1) }; let c = spawn loop(0);`); err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+		for i := 0; i < 20; i++ {
+			if _, err := in.Eval(ctx, `1`); err != nil {
+				t.Fatalf("%s: Eval returned error: %s", e.name, err)
+			}
+		}
+
+		// and is stopped by the context of the latest while it is in place
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		in.Eval(cancelled, `1`)
+		time.Sleep(100 * time.Millisecond)
+		if _, err := in.Eval(ctx, `recv(c)`); err == nil || !strings.Contains(err.Error(), "context canceled") {
+			t.Errorf("%s: expected the spawned call to be cancelled. Got: %v", e.name, err)
+		}
+	}
+}
+
+func TestSpawnSharesGlobals(t *testing.T) {
+	for _, e := range engines {
+		in := NewInterpreter(WithEngine(e.engine))
+		ctx := context.Background()
+
+		// The spawned reader and the host and script writing the same global don't race
+		src := `let n = 0; let read = func(i, last) { if (i == 0) { last } else { read(i - 1, n) } }; let r = spawn read(2000, 0);`
+		if _, err := in.Eval(ctx, src); err != nil {
+			t.Fatalf("%s: Eval returned error: %s", e.name, err)
+		}
+		for i := 0; i < 50; i++ {
+			in.SetGlobal("n", &object.Integer{Value: int64(i)})
+			if _, err := in.Eval(ctx, `n++`); err != nil {
+				t.Fatalf("%s: Eval returned error: %s", e.name, err)
+			}
+			in.GetGlobal("n")
+		}
+		if _, err := in.Eval(ctx, `recv(r)`); err != nil {
+			t.Errorf("%s: expected the spawned reader to finish. Got: %v", e.name, err)
+		}
+
+		// and both see the latest value
+		res, err := in.Eval(ctx, `let g = channel(); let s = spawn func() { recv(g); n }(); n++; send(g, 1); recv(s) == n`)
+		if err != nil || res.Inspect() != "true" {
+			t.Errorf("%s: expected spawned code to see the latest global. Got: %v, %v", e.name, res, err)
+		}
+	}
+}
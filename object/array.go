@@ -5,7 +5,8 @@ import (
 	"strings"
 )
 
-// Array type wraps the array's elements in a slice of Objects
+// Array type wraps the array's elements in a slice of Objects. Like Hash, an Array is never modified
+// once it is built (push returns a new one), so spawned functions can share them without locking
 type Array struct {
 	Elements []Object
 }
@@ -12,3 +12,30 @@ func (b *Boolean) Type() ObjectType { return BooleanObj }
 
 // Inspect returns a string representation of the Boolean's Value
 func (b *Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value) }
+
+// TrueValue and FalseValue are the Booleans both engines use. Builtins that return Booleans
+// must return one of these so that identity checks keep working
+var (
+	TrueValue  = &Boolean{Value: true}
+	FalseValue = &Boolean{Value: false}
+)
+
+// NativeBoolToBoolean returns TrueValue or FalseValue for the given Go bool
+func NativeBoolToBoolean(input bool) *Boolean {
+	if input {
+		return TrueValue
+	}
+	return FalseValue
+}
+
+// IsTruthy reports whether obj counts as true in a condition. Only false and null don't
+func IsTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	default:
+		return true
+	}
+}
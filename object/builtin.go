@@ -2,16 +2,97 @@ package object
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
+	"unicode/utf8"
 )
 
 // BuiltinFunction is a type representing functions we write in Go and
 // expose to our users inside monkey-lang
 type BuiltinFunction func(args ...Object) Object
 
-// Builtin is our object wrapper holding a builtin function
+// HostFunction is a BuiltinFunction that needs the engine running it, such as one that calls
+// back into a Monkey function it was passed
+type HostFunction func(h Host, args ...Object) Object
+
+// Host is implemented by each engine and handed to HostFunctions
+type Host interface {
+	// Call calls fn (a function, closure or builtin) with args and returns its result. Errors,
+	// including fn not being callable, are returned as *Error
+	Call(fn Object, args ...Object) Object
+	// Runtime returns the state kept for the interpreter that is running the builtin
+	Runtime() *Runtime
+}
+
+// Builtin is our object wrapper holding a builtin function. Exactly one of Fn and HostFn is set
 type Builtin struct {
-	Fn BuiltinFunction
+	Name   string
+	Fn     BuiltinFunction
+	HostFn HostFunction
+
+	// Optional checks made before calling Fn or HostFn, for builtins that would rather not check
+	// their own arguments. Params holds the type of each argument: AnyObj for any type, and
+	// FunctionObj for anything callable. When Variadic is set, the last type may repeat any number
+	// of times, including none
+	Params   []ObjectType
+	Variadic bool
+
+	// Builds is what the builtin creates, which Call charges against the script's memory limit.
+	// A result that is one of the arguments was not created and isn't charged
+	Builds Builds
+}
+
+// Builds says which parts of a builtin's result it creates rather than takes from its arguments
+type Builds int
+
+const (
+	// BuildsNothing is for builtins that create no Arrays, Hashes or Strings, or only return ones
+	// that already existed
+	BuildsNothing Builds = iota
+	// BuildsResult is for builtins that return a new value holding objects that already existed
+	BuildsResult
+	// BuildsElements is for builtins that return a new value whose elements, or keys and values,
+	// are new as well
+	BuildsElements
+	// BuildsAll is for builtins whose result is new all the way down
+	BuildsAll
+)
+
+// Call calls the builtin with args, handing h to it if it is a HostFunction
+func (n *Builtin) Call(h Host, args ...Object) Object {
+	if n.Params != nil {
+		if err := n.checkArgs(args); err != nil {
+			return err
+		}
+	}
+	var result Object
+	if n.HostFn != nil {
+		result = n.HostFn(h, args...)
+	} else {
+		result = n.Fn(args...)
+	}
+	if err := n.charge(h, result, args); err != nil {
+		return err
+	}
+	return result
+}
+
+// charge counts what the builtin built for result against the memory limit
+func (n *Builtin) charge(h Host, result Object, args []Object) *Error {
+	if n.Builds == BuildsNothing || result == nil || isError(result) {
+		return nil
+	}
+	for _, arg := range args {
+		if arg == result {
+			return nil
+		}
+	}
+
+	depth := int(n.Builds)
+	if n.Builds == BuildsAll {
+		depth = -1
+	}
+	return allocate(h, builtSize(result, depth))
 }
 
 // Type returns our Builtin's ObjectType
@@ -20,6 +101,52 @@ func (n *Builtin) Type() ObjectType { return BuiltinObj }
 // Inspect simply returns "builtin function"
 func (n *Builtin) Inspect() string { return "builtin function" }
 
+// checkArgs checks args against the builtin's Params
+func (n *Builtin) checkArgs(args []Object) *Error {
+	required := len(n.Params)
+	if n.Variadic {
+		required--
+		if len(args) < required {
+			return newError("Wrong number of arguments. Got: %d, Expected: at least %d", len(args), required)
+		}
+	} else if len(args) != required {
+		return newError("Wrong number of arguments. Got: %d, Expected: %d", len(args), required)
+	}
+
+	for i, arg := range args {
+		expected := n.Params[min(i, len(n.Params)-1)]
+		switch {
+		case expected == AnyObj:
+			continue
+		case expected == FunctionObj && isCallable(arg):
+			continue
+		case arg.Type() == expected:
+			continue
+		}
+
+		position := "Argument"
+		switch {
+		case len(n.Params) == 1 && !n.Variadic:
+		case i < len(ordinals):
+			position = ordinals[i] + " argument"
+		default:
+			position = fmt.Sprintf("Argument %d", i+1)
+		}
+		return newError("%s to `%s` must be %s. Got: %s", position, n.Name, describeType(expected), typeName(arg))
+	}
+
+	return nil
+}
+
+// describeType names an ObjectType the way argument errors do: "an Integer", "a Hash"
+func describeType(t ObjectType) string {
+	name := strings.ToUpper(string(t[:1])) + strings.ToLower(string(t[1:]))
+	if strings.ContainsAny(name[:1], "AEIOU") {
+		return "an " + name
+	}
+	return "a " + name
+}
+
 // GetBuiltinByName takes a name, iterates over our builtins slice and returns
 // the appropriate builtin
 func GetBuiltinByName(name string) *Builtin {
@@ -42,14 +169,107 @@ var Builtins = []struct {
 	Builtin *Builtin
 }{
 	{"len", &Builtin{Fn: bLen}},
-	{"print", &Builtin{Fn: bPrint}},
+	{"print", &Builtin{HostFn: bPrint}},
 	{"first", &Builtin{Fn: bFirst}},
 	{"last", &Builtin{Fn: bLast}},
-	{"rest", &Builtin{Fn: bRest}},
-	{"push", &Builtin{Fn: bPush}},
-	{"pop", &Builtin{Fn: bPop}},
-	{"split", &Builtin{Fn: bSplit}},
-	{"join", &Builtin{Fn: bJoin}},
+	{"rest", &Builtin{Fn: bRest, Builds: BuildsResult}},
+	{"push", &Builtin{Fn: bPush, Builds: BuildsResult}},
+	{"pop", &Builtin{Fn: bPop, Builds: BuildsResult}},
+	{"split", &Builtin{Fn: bSplit, Builds: BuildsElements}},
+	{"join", &Builtin{Fn: bJoin, Builds: BuildsResult}},
+	{"next", &Builtin{Fn: bNext}},
+	{"channel", &Builtin{Fn: bChannel}},
+	{"send", &Builtin{HostFn: bSend}},
+	{"recv", &Builtin{HostFn: bRecv}},
+	{"close", &Builtin{Fn: bClose}},
+	{"select", &Builtin{HostFn: bSelect, Builds: BuildsResult}},
+	{"map", &Builtin{HostFn: bMap, Builds: BuildsResult}},
+	{"filter", &Builtin{HostFn: bFilter, Builds: BuildsResult}},
+	{"reduce", &Builtin{HostFn: bReduce}},
+	{"each", &Builtin{HostFn: bEach}},
+	{"any", &Builtin{HostFn: bAny}},
+	{"all", &Builtin{HostFn: bAll}},
+	{"find", &Builtin{HostFn: bFind}},
+	{"sort_by", &Builtin{HostFn: bSortBy, Builds: BuildsResult}},
+	{"upper", &Builtin{Fn: bUpper, Builds: BuildsResult}},
+	{"lower", &Builtin{Fn: bLower, Builds: BuildsResult}},
+	{"trim", &Builtin{Fn: bTrim, Builds: BuildsResult}},
+	{"trim_left", &Builtin{Fn: bTrimLeft, Builds: BuildsResult}},
+	{"trim_right", &Builtin{Fn: bTrimRight, Builds: BuildsResult}},
+	{"contains", &Builtin{Fn: bContains}},
+	{"starts_with", &Builtin{Fn: bStartsWith}},
+	{"ends_with", &Builtin{Fn: bEndsWith}},
+	{"index_of", &Builtin{Fn: bIndexOf}},
+	{"replace", &Builtin{Fn: bReplace, Builds: BuildsResult}},
+	{"repeat", &Builtin{HostFn: bRepeat, Builds: BuildsResult}},
+	{"pad_left", &Builtin{HostFn: bPadLeft, Builds: BuildsResult}},
+	{"pad_right", &Builtin{HostFn: bPadRight, Builds: BuildsResult}},
+	{"chars", &Builtin{Fn: bChars, Builds: BuildsElements}},
+	{"ord", &Builtin{Fn: bOrd}},
+	{"chr", &Builtin{Fn: bChr, Builds: BuildsResult}},
+	{"keys", &Builtin{Fn: bKeys, Builds: BuildsResult}},
+	{"values", &Builtin{Fn: bValues, Builds: BuildsResult}},
+	{"items", &Builtin{Fn: bItems, Builds: BuildsElements}},
+	{"has_key", &Builtin{Fn: bHasKey}},
+	{"delete", &Builtin{Fn: bDelete, Builds: BuildsResult}},
+	{"merge", &Builtin{Fn: bMerge, Builds: BuildsResult}},
+	{"reverse", &Builtin{Fn: bReverse, Builds: BuildsResult}},
+	{"sort", &Builtin{Fn: bSort, Builds: BuildsResult}},
+	{"slice", &Builtin{Fn: bSlice, Builds: BuildsResult}},
+	{"concat", &Builtin{Fn: bConcat, Builds: BuildsResult}},
+	{"flatten", &Builtin{Fn: bFlatten, Builds: BuildsResult}},
+	{"zip", &Builtin{Fn: bZip, Builds: BuildsElements}},
+	{"uniq", &Builtin{Fn: bUniq, Builds: BuildsResult}},
+	{"range", &Builtin{HostFn: bRange, Builds: BuildsResult}},
+	{"type", &Builtin{Fn: bType, Builds: BuildsResult}},
+	{"int", &Builtin{Fn: bInt}},
+	{"str", &Builtin{Fn: bStr, Builds: BuildsResult}},
+	{"bool", &Builtin{Fn: bBool}},
+	{"is_int", &Builtin{Fn: isType(IntegerObj)}},
+	{"is_string", &Builtin{Fn: isType(StringObj)}},
+	{"is_bool", &Builtin{Fn: isType(BooleanObj)}},
+	{"is_null", &Builtin{Fn: isType(NullObj)}},
+	{"is_array", &Builtin{Fn: isType(ArrayObj)}},
+	{"is_hash", &Builtin{Fn: isType(HashObj)}},
+	{"is_function", &Builtin{Fn: isType(FunctionObj, ClosureObj, BuiltinObj)}},
+	{"random", &Builtin{HostFn: bRandom}},
+	{"random_int", &Builtin{HostFn: bRandomInt}},
+	{"choice", &Builtin{HostFn: bChoice}},
+	{"shuffle", &Builtin{HostFn: bShuffle, Builds: BuildsResult}},
+	{"seed", &Builtin{HostFn: bSeed}},
+	{"json_encode", &Builtin{Fn: bJSONEncode, Builds: BuildsResult}},
+	{"json_decode", &Builtin{Fn: bJSONDecode, Builds: BuildsAll}},
+	{"read_file", &Builtin{HostFn: bReadFile, Builds: BuildsResult}},
+	{"write_file", &Builtin{HostFn: bWriteFile}},
+	{"append_file", &Builtin{HostFn: bAppendFile}},
+	{"list_dir", &Builtin{HostFn: bListDir, Builds: BuildsElements}},
+	{"exists", &Builtin{HostFn: bExists}},
+	{"remove", &Builtin{HostFn: bRemove}},
+	{"env", &Builtin{Fn: bEnv, Builds: BuildsResult}},
+	{"exit", &Builtin{Fn: bExit}},
+	{"read_line", &Builtin{HostFn: bReadLine, Builds: BuildsResult}},
+	{"stdin_lines", &Builtin{HostFn: bStdinLines, Builds: BuildsElements}},
+	{"now", &Builtin{HostFn: bNow}},
+	{"clock", &Builtin{HostFn: bClock}},
+	{"sleep", &Builtin{HostFn: bSleep}},
+	{"format_time", &Builtin{Fn: bFormatTime, Builds: BuildsResult}},
+	{"parse_time", &Builtin{Fn: bParseTime}},
+	{"regex", &Builtin{Fn: bRegex}},
+	{"match", &Builtin{Fn: bMatch}},
+	{"captures", &Builtin{Fn: bCaptures, Builds: BuildsElements}},
+	{"find_all", &Builtin{Fn: bFindAll, Builds: BuildsAll}},
+	{"replace_all", &Builtin{HostFn: bReplaceAll, Builds: BuildsResult}},
+	{"write", &Builtin{HostFn: bWrite}},
+	{"sprintf", &Builtin{Fn: bSprintf, Builds: BuildsResult}},
+	{"printf", &Builtin{HostFn: bPrintf}},
+}
+
+// Builtins are named here once, rather than by the registries they are added to, which may be
+// created concurrently
+func init() {
+	for _, def := range Builtins {
+		def.Builtin.Name = def.Name
+	}
 }
 
 func bLen(args ...Object) Object {
@@ -60,19 +280,12 @@ func bLen(args ...Object) Object {
 	case *Array:
 		return &Integer{Value: int64(len(arg.Elements))}
 	case *String:
-		return &Integer{Value: int64(len(arg.Value))}
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 	default:
 		return newError("Argument to `len` not supported. Got: %s", args[0].Type())
 	}
 }
 
-func bPrint(args ...Object) Object {
-	for _, arg := range args {
-		fmt.Println(arg.Inspect())
-	}
-	return nil
-}
-
 func bFirst(args ...Object) Object {
 	if len(args) != 1 {
 		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
@@ -219,3 +432,134 @@ func bJoin(args ...Object) Object {
 		Value: strings.Join(goSlice, goString),
 	}
 }
+
+func bNext(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	if args[0].Type() != GeneratorObj {
+		return newError("Argument to `next` must be a Generator. Got: %s", args[0].Type())
+	}
+
+	generator := args[0].(*Generator)
+	if val, ok := generator.Next(); ok {
+		return val
+	}
+
+	return nil
+}
+
+func bChannel(args ...Object) Object {
+	if len(args) > 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 0 or 1", len(args))
+	}
+	if len(args) == 0 {
+		return NewChannel(0)
+	}
+	if args[0].Type() != IntegerObj {
+		return newError("Argument to `channel` must be an Integer. Got: %s", args[0].Type())
+	}
+
+	size := args[0].(*Integer).Value
+	if size < 0 {
+		return newError("Channel size must not be negative. Got: %d", size)
+	}
+
+	return NewChannel(int(size))
+}
+
+// The channel builtins block until the channel is ready or the script is stopped, see
+// Runtime.Context
+
+func bSend(h Host, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2", len(args))
+	}
+	if args[0].Type() != ChannelObj {
+		return newError("First argument to `send` must be a Channel. Got: %s", args[0].Type())
+	}
+
+	ctx := h.Runtime().Context()
+	if err := args[0].(*Channel).SendContext(ctx, args[1]); err != nil {
+		if ctx.Err() != nil {
+			return newError("%s", err)
+		}
+		return newError("Cannot send: %s", err)
+	}
+
+	return nil
+}
+
+func bRecv(h Host, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	if args[0].Type() != ChannelObj {
+		return newError("Argument to `recv` must be a Channel. Got: %s", args[0].Type())
+	}
+
+	val, ok, err := args[0].(*Channel).RecvContext(h.Runtime().Context())
+	if err != nil {
+		return newError("%s", err)
+	}
+	if ok {
+		return val
+	}
+
+	return nil
+}
+
+func bClose(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	if args[0].Type() != ChannelObj {
+		return newError("Argument to `close` must be a Channel. Got: %s", args[0].Type())
+	}
+
+	if err := args[0].(*Channel).Close(); err != nil {
+		return newError("Cannot close: %s", err)
+	}
+
+	return nil
+}
+
+// bSelect waits on an array of channels and returns [index, value] for the first one that is
+// ready. A closed channel is ready immediately and produces [index, null]
+func bSelect(h Host, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	if args[0].Type() != ArrayObj {
+		return newError("Argument to `select` must be an Array of Channels. Got: %s", args[0].Type())
+	}
+
+	channels := args[0].(*Array).Elements
+	if len(channels) == 0 {
+		return newError("Argument to `select` must not be empty")
+	}
+
+	// The last case is the script being stopped
+	ctx := h.Runtime().Context()
+	cases := make([]reflect.SelectCase, len(channels)+1)
+	cases[len(channels)] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+	for i, el := range channels {
+		ch, ok := el.(*Channel)
+		if !ok {
+			return newError("Argument to `select` must be an Array of Channels. Found: %s", el.Type())
+		}
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.ch)}
+	}
+
+	chosen, val, ok := reflect.Select(cases)
+	if chosen == len(channels) {
+		return newError("%s", ctx.Err())
+	}
+
+	var received Object = NullValue
+	if ok && !val.IsNil() {
+		received = val.Interface().(Object)
+	}
+
+	return &Array{Elements: []Object{&Integer{Value: int64(chosen)}, received}}
+}
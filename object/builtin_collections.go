@@ -0,0 +1,338 @@
+package object
+
+import "sort"
+
+// Like push and pop, the builtins in this file return new arrays and hashes rather than
+// modifying the ones they are passed
+
+func bKeys(args ...Object) Object {
+	hash, err := checkHash("keys", args, 1)
+	if err != nil {
+		return err
+	}
+
+	keys := []Object{}
+	for _, pair := range sortedHashPairs(hash) {
+		keys = append(keys, pair.Key)
+	}
+
+	return &Array{Elements: keys}
+}
+
+func bValues(args ...Object) Object {
+	hash, err := checkHash("values", args, 1)
+	if err != nil {
+		return err
+	}
+
+	values := []Object{}
+	for _, pair := range sortedHashPairs(hash) {
+		values = append(values, pair.Value)
+	}
+
+	return &Array{Elements: values}
+}
+
+// bItems returns a hash's pairs as an array of [key, value] arrays
+func bItems(args ...Object) Object {
+	hash, err := checkHash("items", args, 1)
+	if err != nil {
+		return err
+	}
+
+	items := []Object{}
+	for _, pair := range sortedHashPairs(hash) {
+		items = append(items, &Array{Elements: []Object{pair.Key, pair.Value}})
+	}
+
+	return &Array{Elements: items}
+}
+
+func bHasKey(args ...Object) Object {
+	hash, err := checkHash("has_key", args, 2)
+	if err != nil {
+		return err
+	}
+	key, ok := args[1].(Hashable)
+	if !ok {
+		return newError("Unusable as hash key: %s", args[1].Type())
+	}
+
+	_, ok = hash.Pairs[key.HashKey()]
+
+	return NativeBoolToBoolean(ok)
+}
+
+func bDelete(args ...Object) Object {
+	hash, err := checkHash("delete", args, 2)
+	if err != nil {
+		return err
+	}
+	key, ok := args[1].(Hashable)
+	if !ok {
+		return newError("Unusable as hash key: %s", args[1].Type())
+	}
+
+	pairs := make(map[HashKey]HashPair, len(hash.Pairs))
+	for k, pair := range hash.Pairs {
+		pairs[k] = pair
+	}
+	delete(pairs, key.HashKey())
+
+	return &Hash{Pairs: pairs}
+}
+
+// bMerge returns a hash with the pairs of both its arguments, preferring the second's values
+func bMerge(args ...Object) Object {
+	hash, err := checkHash("merge", args, 2)
+	if err != nil {
+		return err
+	}
+	other, ok := args[1].(*Hash)
+	if !ok {
+		return newError("Second argument to `merge` must be a Hash. Got: %s", args[1].Type())
+	}
+
+	pairs := make(map[HashKey]HashPair, len(hash.Pairs)+len(other.Pairs))
+	for k, pair := range hash.Pairs {
+		pairs[k] = pair
+	}
+	for k, pair := range other.Pairs {
+		pairs[k] = pair
+	}
+
+	return &Hash{Pairs: pairs}
+}
+
+func bReverse(args ...Object) Object {
+	array, err := checkArray("reverse", args, 1)
+	if err != nil {
+		return err
+	}
+
+	length := len(array.Elements)
+	reversed := make([]Object, length)
+	for i, el := range array.Elements {
+		reversed[length-1-i] = el
+	}
+
+	return &Array{Elements: reversed}
+}
+
+// bSort sorts any array, using the ordering described on compareObjects. Equal elements keep
+// their order
+func bSort(args ...Object) Object {
+	array, err := checkArray("sort", args, 1)
+	if err != nil {
+		return err
+	}
+
+	sorted := make([]Object, len(array.Elements))
+	copy(sorted, array.Elements)
+	sortStable(sorted)
+
+	return &Array{Elements: sorted}
+}
+
+// bSlice returns the elements from start up to (not including) end, which defaults to the end of
+// the array. Negative indexes count back from the end, and indexes out of range are clamped
+func bSlice(args ...Object) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2 or 3", len(args))
+	}
+	array, ok := args[0].(*Array)
+	if !ok {
+		return newError("First argument to `slice` must be an Array. Got: %s", args[0].Type())
+	}
+	length := int64(len(array.Elements))
+
+	start, ok := args[1].(*Integer)
+	if !ok {
+		return newError("Second argument to `slice` must be an Integer. Got: %s", args[1].Type())
+	}
+	end := &Integer{Value: length}
+	if len(args) == 3 {
+		if end, ok = args[2].(*Integer); !ok {
+			return newError("Third argument to `slice` must be an Integer. Got: %s", args[2].Type())
+		}
+	}
+
+	from, to := clampIndex(start.Value, length), clampIndex(end.Value, length)
+	if from >= to {
+		return &Array{Elements: []Object{}}
+	}
+	elements := make([]Object, to-from)
+	copy(elements, array.Elements[from:to])
+
+	return &Array{Elements: elements}
+}
+
+func clampIndex(i, length int64) int64 {
+	if i < 0 {
+		i += length
+	}
+	return max(0, min(i, length))
+}
+
+// bConcat joins any number of arrays together
+func bConcat(args ...Object) Object {
+	elements := []Object{}
+	for i, arg := range args {
+		array, ok := arg.(*Array)
+		if !ok {
+			return newError("Arguments to `concat` must be Arrays. Got: %s at position %d", arg.Type(), i)
+		}
+		elements = append(elements, array.Elements...)
+	}
+
+	return &Array{Elements: elements}
+}
+
+// bFlatten flattens one level of nesting, so [[1, 2], 3, [[4]]] becomes [1, 2, 3, [4]]
+func bFlatten(args ...Object) Object {
+	array, err := checkArray("flatten", args, 1)
+	if err != nil {
+		return err
+	}
+
+	flattened := []Object{}
+	for _, el := range array.Elements {
+		if inner, ok := el.(*Array); ok {
+			flattened = append(flattened, inner.Elements...)
+		} else {
+			flattened = append(flattened, el)
+		}
+	}
+
+	return &Array{Elements: flattened}
+}
+
+// bZip pairs up the elements of two arrays, stopping at the end of the shorter one
+func bZip(args ...Object) Object {
+	left, err := checkArray("zip", args, 2)
+	if err != nil {
+		return err
+	}
+	right, ok := args[1].(*Array)
+	if !ok {
+		return newError("Second argument to `zip` must be an Array. Got: %s", args[1].Type())
+	}
+
+	zipped := []Object{}
+	for i := 0; i < len(left.Elements) && i < len(right.Elements); i++ {
+		zipped = append(zipped, &Array{Elements: []Object{left.Elements[i], right.Elements[i]}})
+	}
+
+	return &Array{Elements: zipped}
+}
+
+// bUniq removes repeated elements, keeping the first of each
+func bUniq(args ...Object) Object {
+	array, err := checkArray("uniq", args, 1)
+	if err != nil {
+		return err
+	}
+
+	unique := []Object{}
+	for _, el := range array.Elements {
+		if arrayIndexOf(&Array{Elements: unique}, el) == -1 {
+			unique = append(unique, el)
+		}
+	}
+
+	return &Array{Elements: unique}
+}
+
+// bRange returns the integers from start (0 unless given) up to but not including end, counting
+// by step (1 unless given), which may be negative
+func bRange(h Host, args ...Object) Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1, 2 or 3", len(args))
+	}
+	bounds := []int64{0, 0, 1}
+	for i, arg := range args {
+		integer, ok := arg.(*Integer)
+		if !ok {
+			return newError("Arguments to `range` must be Integers. Got: %s", arg.Type())
+		}
+		bounds[i] = integer.Value
+	}
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return newError("Step argument to `range` must not be 0")
+	}
+	count := rangeCount(start, end, step)
+	if err := checkAllocation(h, float64(ArraySize(0))+float64(count)*elementSize); err != nil {
+		return err
+	}
+
+	// Counting elements rather than comparing against end means the last one can sit right
+	// next to the limits of an Integer without stepping past them
+	elements := []Object{}
+	for i := uint64(0); i < count; i++ {
+		elements = append(elements, &Integer{Value: start + int64(i)*step})
+	}
+
+	return &Array{Elements: elements}
+}
+
+// rangeCount returns how many elements range(start, end, step) has. It works in unsigned
+// arithmetic, where the distance between any two Integers fits
+func rangeCount(start, end, step int64) uint64 {
+	if step > 0 && start < end {
+		return (uint64(end)-uint64(start)-1)/uint64(step) + 1
+	}
+	if step < 0 && start > end {
+		return (uint64(start)-uint64(end)-1)/(-uint64(step)) + 1
+	}
+	return 0
+}
+
+// arrayIndexOf returns the index of the first element equal to obj, or -1
+func arrayIndexOf(array *Array, obj Object) int {
+	for i, el := range array.Elements {
+		if objectsEqual(el, obj) {
+			return i
+		}
+	}
+	return -1
+}
+
+func sortStable(elements []Object) {
+	sort.SliceStable(elements, func(i, j int) bool {
+		return compareObjects(elements[i], elements[j]) < 0
+	})
+}
+
+// checkArray checks the arguments of builtins like name(Array, ...)
+func checkArray(name string, args []Object, expected int) (*Array, *Error) {
+	if len(args) != expected {
+		return nil, newError("Wrong number of arguments. Got: %d, Expected: %d", len(args), expected)
+	}
+	array, ok := args[0].(*Array)
+	if !ok {
+		if expected == 1 {
+			return nil, newError("Argument to `%s` must be an Array. Got: %s", name, args[0].Type())
+		}
+		return nil, newError("First argument to `%s` must be an Array. Got: %s", name, args[0].Type())
+	}
+	return array, nil
+}
+
+// checkHash checks the arguments of builtins like name(Hash, ...)
+func checkHash(name string, args []Object, expected int) (*Hash, *Error) {
+	if len(args) != expected {
+		return nil, newError("Wrong number of arguments. Got: %d, Expected: %d", len(args), expected)
+	}
+	hash, ok := args[0].(*Hash)
+	if !ok {
+		if expected == 1 {
+			return nil, newError("Argument to `%s` must be a Hash. Got: %s", name, args[0].Type())
+		}
+		return nil, newError("First argument to `%s` must be a Hash. Got: %s", name, args[0].Type())
+	}
+	return hash, nil
+}
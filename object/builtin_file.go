@@ -0,0 +1,146 @@
+package object
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// The file builtins only use the paths their Runtime's FilePolicy allows. Failures, including
+// paths the policy doesn't allow, are returned as errors
+
+// checkPath checks the path argument of the file builtin name against the interpreter's policy
+func checkPath(h Host, name string, args []Object, expected int, write bool) (string, *Error) {
+	if len(args) != expected {
+		return "", newError("Wrong number of arguments. Got: %d, Expected: %d", len(args), expected)
+	}
+	path, ok := args[0].(*String)
+	if !ok {
+		if expected == 1 {
+			return "", newError("Argument to `%s` must be a String. Got: %s", name, args[0].Type())
+		}
+		return "", newError("First argument to `%s` must be a String. Got: %s", name, args[0].Type())
+	}
+
+	policy := h.Runtime().FilePolicy()
+	if write && !policy.CanWrite(path.Value) {
+		return "", newError("`%s` is not allowed to write %s", name, path.Value)
+	}
+	if !write && !policy.CanRead(path.Value) {
+		return "", newError("`%s` is not allowed to read %s", name, path.Value)
+	}
+
+	return path.Value, nil
+}
+
+// fileError turns an error from the os package into an error object, without the Go function
+// names os puts in its messages
+func fileError(name string, err error) *Error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return newError("`%s` failed: %s: %s", name, pathErr.Path, pathErr.Err)
+	}
+	return newError("`%s` failed: %s", name, err)
+}
+
+func bReadFile(h Host, args ...Object) Object {
+	path, err := checkPath(h, "read_file", args, 1, false)
+	if err != nil {
+		return err
+	}
+
+	contents, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return fileError("read_file", readErr)
+	}
+
+	return &String{Value: string(contents)}
+}
+
+func bWriteFile(h Host, args ...Object) Object {
+	return writeFile(h, "write_file", args, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+}
+
+func bAppendFile(h Host, args ...Object) Object {
+	return writeFile(h, "append_file", args, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+}
+
+func writeFile(h Host, name string, args []Object, flag int) Object {
+	path, err := checkPath(h, name, args, 2, true)
+	if err != nil {
+		return err
+	}
+	contents, ok := args[1].(*String)
+	if !ok {
+		return newError("Second argument to `%s` must be a String. Got: %s", name, args[1].Type())
+	}
+
+	file, openErr := os.OpenFile(path, flag, 0644)
+	if openErr != nil {
+		return fileError(name, openErr)
+	}
+	_, writeErr := file.WriteString(contents.Value)
+	if closeErr := file.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		return fileError(name, writeErr)
+	}
+
+	return nil
+}
+
+// bListDir returns the sorted names of the entries in a directory
+func bListDir(h Host, args ...Object) Object {
+	path, err := checkPath(h, "list_dir", args, 1, false)
+	if err != nil {
+		return err
+	}
+
+	entries, readErr := os.ReadDir(path)
+	if readErr != nil {
+		return fileError("list_dir", readErr)
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	sort.Strings(names)
+
+	elements := make([]Object, len(names))
+	for i, name := range names {
+		elements[i] = &String{Value: name}
+	}
+
+	return &Array{Elements: elements}
+}
+
+func bExists(h Host, args ...Object) Object {
+	path, err := checkPath(h, "exists", args, 1, false)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(path)
+	if statErr != nil && !errors.Is(statErr, fs.ErrNotExist) {
+		return fileError("exists", statErr)
+	}
+
+	return NativeBoolToBoolean(statErr == nil)
+}
+
+// bRemove removes a file or an empty directory
+func bRemove(h Host, args ...Object) Object {
+	path, err := checkPath(h, "remove", args, 1, true)
+	if err != nil {
+		return err
+	}
+
+	if removeErr := os.Remove(path); removeErr != nil {
+		return fileError("remove", removeErr)
+	}
+
+	return nil
+}
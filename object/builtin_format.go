@@ -0,0 +1,194 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The output builtins write to their Runtime's output, os.Stdout unless the host sets another
+
+// output writes s to the Runtime's output in one piece
+func output(h Host, s string) Object {
+	var err error
+	h.Runtime().Output(func(w io.Writer) {
+		_, err = io.WriteString(w, s)
+	})
+	if err != nil {
+		return newError("Could not write output: %s", err)
+	}
+	return nil
+}
+
+// bPrint writes each argument on a line of its own
+func bPrint(h Host, args ...Object) Object {
+	var out strings.Builder
+	for _, arg := range args {
+		out.WriteString(arg.Inspect())
+		out.WriteByte('\n')
+	}
+	return output(h, out.String())
+}
+
+// bWrite writes its arguments one after another, without separating them or ending the line
+func bWrite(h Host, args ...Object) Object {
+	var out strings.Builder
+	for _, arg := range args {
+		out.WriteString(arg.Inspect())
+	}
+	return output(h, out.String())
+}
+
+func bSprintf(args ...Object) Object {
+	str, err := format("sprintf", args)
+	if err != nil {
+		return err
+	}
+	return &String{Value: str}
+}
+
+func bPrintf(h Host, args ...Object) Object {
+	str, err := format("printf", args)
+	if err != nil {
+		return err
+	}
+	return output(h, str)
+}
+
+// maxFormatWidth is the largest width or precision a format string can give, the largest Go
+// formats with
+const maxFormatWidth = 1000000
+
+// format formats args[1:] by the format string args[0]. Its verbs follow Go's, with the flags,
+// width and precision Go allows, but check that they're given a Monkey value they suit:
+//
+//	%v %s  any value as print writes it
+//	%q     any value as print writes it, double quoted
+//	%d %b %o %x %X  an Integer (%x and %X also take a String)
+//	%f %e %g %E %G  an Integer or Float
+//	%t     a Boolean
+//	%T     the type of any value
+//	%%     a percent sign
+func format(name string, args []Object) (string, *Error) {
+	if len(args) == 0 {
+		return "", newError("Wrong number of arguments. Got: 0, Expected: at least 1")
+	}
+	f, ok := args[0].(*String)
+	if !ok {
+		return "", newError("First argument to `%s` must be a String. Got: %s", name, args[0].Type())
+	}
+
+	var out strings.Builder
+	values := args[1:]
+	next := 0
+	spec := []rune(f.Value)
+
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			out.WriteRune(spec[i])
+			continue
+		}
+
+		// Flags are passed on to Go as they are. Go writes an error into the output in place of
+		// a width or precision it can't use, so those are checked first
+		start := i
+		i++
+		for i < len(spec) && strings.ContainsRune("+- #0", spec[i]) {
+			i++
+		}
+		width := i
+		for i < len(spec) && '0' <= spec[i] && spec[i] <= '9' {
+			i++
+		}
+		if err := checkFormatWidth(name, "Width", spec[width:i]); err != nil {
+			return "", err
+		}
+		if i < len(spec) && spec[i] == '.' {
+			i++
+			precision := i
+			for i < len(spec) && '0' <= spec[i] && spec[i] <= '9' {
+				i++
+			}
+			if err := checkFormatWidth(name, "Precision", spec[precision:i]); err != nil {
+				return "", err
+			}
+		}
+		if i == len(spec) {
+			return "", newError("Format string passed to `%s` ends in an unfinished verb: %s", name, string(spec[start:]))
+		}
+
+		verb := spec[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(values) {
+			return "", newError("Not enough arguments for the format string passed to `%s`. Got: %d", name, len(values))
+		}
+
+		value, err := formatValue(name, verb, values[next])
+		if err != nil {
+			return "", err
+		}
+		if verb == 'T' {
+			verb = 's'
+		}
+		fmt.Fprintf(&out, string(spec[start:i])+string(verb), value)
+		next++
+	}
+
+	if next != len(values) {
+		return "", newError("Too many arguments for the format string passed to `%s`. Got: %d, Expected: %d", name, len(values), next)
+	}
+
+	return out.String(), nil
+}
+
+// checkFormatWidth checks the digits of a width or precision, which is what says which, in the
+// format string passed to the builtin name
+func checkFormatWidth(name, what string, digits []rune) *Error {
+	if len(digits) == 0 {
+		return nil
+	}
+	if n, err := strconv.Atoi(string(digits)); err != nil || n > maxFormatWidth {
+		return newError("%s in the format string passed to `%s` must be at most %d. Got: %s", what, name, maxFormatWidth, string(digits))
+	}
+	return nil
+}
+
+// formatValue converts arg to the Go value the verb formats, if the verb suits it
+func formatValue(name string, verb rune, arg Object) (interface{}, *Error) {
+	switch verb {
+	case 'v', 's', 'q':
+		if str, ok := arg.(*String); ok {
+			return str.Value, nil
+		}
+		return arg.Inspect(), nil
+	case 'T':
+		return string(typeName(arg)), nil
+	case 'd', 'b', 'o', 'x', 'X':
+		if i, ok := arg.(*Integer); ok {
+			return i.Value, nil
+		}
+		if verb != 'x' && verb != 'X' {
+			return nil, newError("Verb %%%c in the format string passed to `%s` needs an Integer. Got: %s", verb, name, typeName(arg))
+		}
+		if str, ok := arg.(*String); ok {
+			return str.Value, nil
+		}
+		return nil, newError("Verb %%%c in the format string passed to `%s` needs an Integer or String. Got: %s", verb, name, typeName(arg))
+	case 'f', 'e', 'g', 'E', 'G':
+		if f, ok := ToFloat(arg); ok {
+			return f, nil
+		}
+		return nil, newError("Verb %%%c in the format string passed to `%s` needs a number. Got: %s", verb, name, typeName(arg))
+	case 't':
+		if b, ok := arg.(*Boolean); ok {
+			return b.Value, nil
+		}
+		return nil, newError("Verb %%t in the format string passed to `%s` needs a Boolean. Got: %s", name, typeName(arg))
+	default:
+		return nil, newError("Unknown verb %%%c in the format string passed to `%s`", verb, name)
+	}
+}
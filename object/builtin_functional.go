@@ -0,0 +1,251 @@
+package object
+
+import "sort"
+
+// The builtins in this file take a Monkey function and call it through the Host with each element
+// of an Array or each value of a Generator. Any error the function returns stops them and is
+// returned as is
+
+func isError(obj Object) bool {
+	return obj != nil && obj.Type() == ErrorObj
+}
+
+func isCallable(obj Object) bool {
+	switch obj.Type() {
+	case FunctionObj, ClosureObj, BuiltinObj:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkIterableAndFunction checks the arguments of builtins called like name(iterable, ..., fn),
+// where iterable is an Array or a Generator
+func checkIterableAndFunction(name string, args []Object, expected int) *Error {
+	if len(args) != expected {
+		return newError("Wrong number of arguments. Got: %d, Expected: %d", len(args), expected)
+	}
+	if t := args[0].Type(); t != ArrayObj && t != GeneratorObj {
+		return newError("First argument to `%s` must be an Array or a Generator. Got: %s", name, t)
+	}
+	if fn := args[len(args)-1]; !isCallable(fn) {
+		return newError("Last argument to `%s` must be a function. Got: %s", name, fn.Type())
+	}
+	return nil
+}
+
+// nextBuiltin is `next`, which iterate calls through the Host. It is its own Builtin, since
+// looking `next` up in Builtins from here would make Builtins refer to itself
+var nextBuiltin = &Builtin{Name: "next", Fn: bNext}
+
+// iterate calls visit with each element of iterable, an Array or a Generator, until visit returns
+// something other than nil, which iterate returns. A Generator is drained by calling `next` on it
+// through h, and an error it fails with is returned as well
+func iterate(h Host, iterable Object, visit func(el Object) Object) Object {
+	if array, ok := iterable.(*Array); ok {
+		for _, el := range array.Elements {
+			if result := visit(el); result != nil {
+				return result
+			}
+		}
+		return nil
+	}
+
+	generator := iterable.(*Generator)
+	for {
+		el := h.Call(nextBuiltin, generator)
+		if isError(el) {
+			return el
+		}
+		// A finished generator gives null, which it may also have yielded
+		if generator.done {
+			return nil
+		}
+		if result := visit(el); result != nil {
+			return result
+		}
+	}
+}
+
+func bMap(h Host, args ...Object) Object {
+	if err := checkIterableAndFunction("map", args, 2); err != nil {
+		return err
+	}
+
+	mapped := []Object{}
+	if err := iterate(h, args[0], func(el Object) Object {
+		result := h.Call(args[1], el)
+		if isError(result) {
+			return result
+		}
+		mapped = append(mapped, result)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return &Array{Elements: mapped}
+}
+
+func bFilter(h Host, args ...Object) Object {
+	if err := checkIterableAndFunction("filter", args, 2); err != nil {
+		return err
+	}
+
+	filtered := []Object{}
+	if err := iterate(h, args[0], func(el Object) Object {
+		result := h.Call(args[1], el)
+		if isError(result) {
+			return result
+		}
+		if IsTruthy(result) {
+			filtered = append(filtered, el)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return &Array{Elements: filtered}
+}
+
+// bReduce calls fn(accumulator, element) for each element, starting with the initial value
+func bReduce(h Host, args ...Object) Object {
+	if err := checkIterableAndFunction("reduce", args, 3); err != nil {
+		return err
+	}
+
+	accumulator := args[1]
+	if err := iterate(h, args[0], func(el Object) Object {
+		accumulator = h.Call(args[2], accumulator, el)
+		if isError(accumulator) {
+			return accumulator
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return accumulator
+}
+
+func bEach(h Host, args ...Object) Object {
+	if err := checkIterableAndFunction("each", args, 2); err != nil {
+		return err
+	}
+
+	return iterate(h, args[0], func(el Object) Object {
+		if result := h.Call(args[1], el); isError(result) {
+			return result
+		}
+		return nil
+	})
+}
+
+func bAny(h Host, args ...Object) Object {
+	if err := checkIterableAndFunction("any", args, 2); err != nil {
+		return err
+	}
+
+	found := iterate(h, args[0], func(el Object) Object {
+		result := h.Call(args[1], el)
+		if isError(result) {
+			return result
+		}
+		if IsTruthy(result) {
+			return TrueValue
+		}
+		return nil
+	})
+	if found != nil {
+		return found
+	}
+
+	return FalseValue
+}
+
+func bAll(h Host, args ...Object) Object {
+	if err := checkIterableAndFunction("all", args, 2); err != nil {
+		return err
+	}
+
+	missed := iterate(h, args[0], func(el Object) Object {
+		result := h.Call(args[1], el)
+		if isError(result) {
+			return result
+		}
+		if !IsTruthy(result) {
+			return FalseValue
+		}
+		return nil
+	})
+	if missed != nil {
+		return missed
+	}
+
+	return TrueValue
+}
+
+func bFind(h Host, args ...Object) Object {
+	if err := checkIterableAndFunction("find", args, 2); err != nil {
+		return err
+	}
+
+	return iterate(h, args[0], func(el Object) Object {
+		result := h.Call(args[1], el)
+		if isError(result) {
+			return result
+		}
+		if IsTruthy(result) {
+			return el
+		}
+		return nil
+	})
+}
+
+// bSortBy returns a new array sorted by the key fn returns for each element. Keys must be all
+// Integers or all Strings, and elements with equal keys keep their order
+func bSortBy(h Host, args ...Object) Object {
+	if err := checkIterableAndFunction("sort_by", args, 2); err != nil {
+		return err
+	}
+
+	var elements, keys []Object
+	if err := iterate(h, args[0], func(el Object) Object {
+		key := h.Call(args[1], el)
+		if isError(key) {
+			return key
+		}
+		if key.Type() != IntegerObj && key.Type() != StringObj {
+			return newError("Keys for `sort_by` must be Integers or Strings. Got: %s", key.Type())
+		}
+		if len(keys) > 0 && key.Type() != keys[0].Type() {
+			return newError("Keys for `sort_by` must all be the same type. Got: %s and %s", keys[0].Type(), key.Type())
+		}
+		elements = append(elements, el)
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	indexes := make([]int, len(elements))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		switch left := keys[indexes[a]].(type) {
+		case *Integer:
+			return left.Value < keys[indexes[b]].(*Integer).Value
+		default:
+			return left.(*String).Value < keys[indexes[b]].(*String).Value
+		}
+	})
+
+	sorted := make([]Object, len(elements))
+	for i, index := range indexes {
+		sorted[i] = elements[index]
+	}
+
+	return &Array{Elements: sorted}
+}
@@ -0,0 +1,179 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxJSONIndent is the most spaces json_encode indents by, the same as JavaScript's JSON.stringify
+const maxJSONIndent = 10
+
+// bJSONEncode encodes a value as JSON. Hash keys must be Strings and are written in sorted order,
+// so the same value always encodes the same way. An optional second argument indents the output
+// by that many spaces, up to maxJSONIndent, or by that string
+func bJSONEncode(args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1 or 2", len(args))
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *Integer:
+			if arg.Value > maxJSONIndent {
+				return newError("Second argument to `json_encode` must be at most %d. Got: %d", maxJSONIndent, arg.Value)
+			}
+			indent = strings.Repeat(" ", int(max(arg.Value, 0)))
+		case *String:
+			indent = arg.Value
+		default:
+			return newError("Second argument to `json_encode` must be an Integer or a String. Got: %s", args[1].Type())
+		}
+	}
+
+	value, err := toJSONValue(args[0])
+	if err != nil {
+		return newError("Could not encode JSON: %s", err)
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(value); err != nil {
+		return newError("Could not encode JSON: %s", err)
+	}
+
+	return &String{Value: strings.TrimSuffix(out.String(), "\n")}
+}
+
+// toJSONValue converts obj to a value encoding/json encodes as the JSON for obj. Maps are
+// encoded with their keys sorted
+func toJSONValue(obj Object) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *Null:
+		return nil, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return nil, fmt.Errorf("%s has no JSON representation", obj.Inspect())
+		}
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			value, err := toJSONValue(el)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case *Hash:
+		values := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := pair.Key.(*String)
+			if !ok {
+				return nil, fmt.Errorf("hash keys must be Strings. Got: %s", pair.Key.Type())
+			}
+			value, err := toJSONValue(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			values[key.Value] = value
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%s has no JSON representation", typeName(obj))
+	}
+}
+
+// bJSONDecode decodes a JSON document. Whole numbers decode to Integers and other numbers to
+// Floats. Errors in the document are reported with the line and column they were found at
+func bJSONDecode(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("Argument to `json_decode` must be a String. Got: %s", args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return jsonError(str.Value, dec.InputOffset(), err)
+	}
+	rest := str.Value[dec.InputOffset():]
+	if trimmed := strings.TrimLeft(rest, " \t\r\n"); trimmed != "" {
+		offset := dec.InputOffset() + int64(len(rest)-len(trimmed))
+		return jsonError(str.Value, offset, errors.New("unexpected data after the top-level value"))
+	}
+
+	return fromJSONValue(value)
+}
+
+// jsonError reports a decoding error at the line and column of offset, or of the character a
+// syntax error was found at
+func jsonError(input string, offset int64, err error) *Error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = max(syntaxErr.Offset-1, 0)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		offset = int64(len(input))
+		err = errors.New("unexpected end of JSON input")
+	}
+
+	before := input[:min(int(offset), len(input))]
+	line := strings.Count(before, "\n") + 1
+	column := len([]rune(before[strings.LastIndex(before, "\n")+1:])) + 1
+
+	return newError("Invalid JSON at line %d, column %d: %s", line, column, err)
+}
+
+// fromJSONValue converts a value decoded by encoding/json, with UseNumber, to an Object
+func fromJSONValue(value interface{}) Object {
+	switch value := value.(type) {
+	case nil:
+		return NullValue
+	case bool:
+		return NativeBoolToBoolean(value)
+	case json.Number:
+		if i, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			return &Integer{Value: i}
+		}
+		f, _ := strconv.ParseFloat(string(value), 64)
+		return &Float{Value: f}
+	case string:
+		return &String{Value: value}
+	case []interface{}:
+		elements := make([]Object, len(value))
+		for i, el := range value {
+			elements[i] = fromJSONValue(el)
+		}
+		return &Array{Elements: elements}
+	case map[string]interface{}:
+		pairs := make(map[HashKey]HashPair, len(value))
+		for k, v := range value {
+			key := &String{Value: k}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: fromJSONValue(v)}
+		}
+		return &Hash{Pairs: pairs}
+	default:
+		return newError("Unexpected JSON value: %v", value)
+	}
+}
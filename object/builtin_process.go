@@ -0,0 +1,131 @@
+package object
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// bEnv returns the value of an environment variable, or null when it isn't set
+func bEnv(args ...Object) Object {
+	if err := checkStrings("env", args, 1); err != nil {
+		return err
+	}
+
+	value, ok := os.LookupEnv(args[0].(*String).Value)
+	if !ok {
+		return nil
+	}
+
+	return &String{Value: value}
+}
+
+// bExit stops the script with an exit status from 0 to 255, 0 when none is given. Both engines stop at the
+// error it returns, see Error.Exit
+func bExit(args ...Object) Object {
+	if len(args) > 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 0 or 1", len(args))
+	}
+
+	code := int64(0)
+	if len(args) == 1 {
+		arg, ok := args[0].(*Integer)
+		if !ok {
+			return newError("Argument to `exit` must be an Integer. Got: %s", args[0].Type())
+		}
+		code = arg.Value
+	}
+	// Processes only get to see the low byte of their status, so 256 would pass for success
+	if code < 0 || code > 255 {
+		return newError("Argument to `exit` must be between 0 and 255. Got: %d", code)
+	}
+
+	return &Error{Message: fmt.Sprintf("exit status %d", code), Exit: true, Code: int(code)}
+}
+
+// bReadLine returns the next line of input without its line ending, or null once the input is
+// used up
+func bReadLine(h Host, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 0", len(args))
+	}
+
+	var line Object
+	var err error
+	if stopped := readInput(h, func(r *bufio.Reader) {
+		var s string
+		if s, err = readLine(r); err == nil {
+			line = &String{Value: s}
+		}
+	}); stopped != nil {
+		return newError("%s", stopped)
+	}
+	if err != nil && err != io.EOF {
+		return newError("Could not read input: %s", err)
+	}
+
+	return line
+}
+
+// bStdinLines returns the rest of the input as an array of lines
+func bStdinLines(h Host, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 0", len(args))
+	}
+
+	lines := []Object{}
+	var err error
+	if stopped := readInput(h, func(r *bufio.Reader) {
+		var s string
+		for {
+			if s, err = readLine(r); err != nil {
+				return
+			}
+			lines = append(lines, &String{Value: s})
+		}
+	}); stopped != nil {
+		return newError("%s", stopped)
+	}
+	if err != io.EOF {
+		return newError("Could not read input: %s", err)
+	}
+
+	return &Array{Elements: lines}
+}
+
+// readInput calls read with the runtime's input on another goroutine and waits for it to return,
+// or for the script to be stopped, when it returns the context's error. A read can't be
+// interrupted, so a stopped script leaves it to finish in the background, and whatever it reads
+// is lost
+func readInput(h Host, read func(r *bufio.Reader)) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Runtime().Stdin(read)
+	}()
+
+	ctx := h.Runtime().Context()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readLine reads a line ending in "\n" or "\r\n", or the text after the last line ending. It
+// returns io.EOF only when there is nothing left to read
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
@@ -0,0 +1,99 @@
+package object
+
+import "math/rand"
+
+// The random builtins draw from their interpreter's Runtime, so seeding one interpreter doesn't
+// affect another
+
+func bRandom(h Host, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 0", len(args))
+	}
+
+	var value float64
+	h.Runtime().Rand(func(r *rand.Rand) { value = r.Float64() })
+
+	return &Float{Value: value}
+}
+
+// bRandomInt returns an Integer between its arguments, including both
+func bRandomInt(h Host, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2", len(args))
+	}
+	low, ok := args[0].(*Integer)
+	if !ok {
+		return newError("First argument to `random_int` must be an Integer. Got: %s", args[0].Type())
+	}
+	high, ok := args[1].(*Integer)
+	if !ok {
+		return newError("Second argument to `random_int` must be an Integer. Got: %s", args[1].Type())
+	}
+	if low.Value > high.Value {
+		return newError("First argument to `random_int` must not be greater than the second. Got: %d and %d", low.Value, high.Value)
+	}
+
+	var value int64
+	h.Runtime().Rand(func(r *rand.Rand) {
+		span := uint64(high.Value-low.Value) + 1
+		if span == 0 {
+			// The range covers every int64
+			value = int64(r.Uint64())
+			return
+		}
+		value = low.Value + int64(r.Uint64()%span)
+	})
+
+	return &Integer{Value: value}
+}
+
+func bChoice(h Host, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	array, ok := args[0].(*Array)
+	if !ok {
+		return newError("Argument to `choice` must be an Array. Got: %s", args[0].Type())
+	}
+	if len(array.Elements) == 0 {
+		return nil
+	}
+
+	var i int
+	h.Runtime().Rand(func(r *rand.Rand) { i = r.Intn(len(array.Elements)) })
+
+	return array.Elements[i]
+}
+
+// bShuffle returns a new array with the elements of its argument in a random order
+func bShuffle(h Host, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	array, ok := args[0].(*Array)
+	if !ok {
+		return newError("Argument to `shuffle` must be an Array. Got: %s", args[0].Type())
+	}
+
+	shuffled := make([]Object, len(array.Elements))
+	copy(shuffled, array.Elements)
+	h.Runtime().Rand(func(r *rand.Rand) {
+		r.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	})
+
+	return &Array{Elements: shuffled}
+}
+
+func bSeed(h Host, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	seed, ok := args[0].(*Integer)
+	if !ok {
+		return newError("Argument to `seed` must be an Integer. Got: %s", args[0].Type())
+	}
+
+	h.Runtime().Seed(seed.Value)
+
+	return nil
+}
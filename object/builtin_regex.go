@@ -0,0 +1,153 @@
+package object
+
+import (
+	"regexp"
+)
+
+// A match is handed to scripts as the matched String when the regex has no capture groups.
+// Otherwise it is an Array of the whole match followed by each group, with null for groups that
+// took no part in the match
+
+func bRegex(args ...Object) Object {
+	if err := checkStrings("regex", args, 1); err != nil {
+		return err
+	}
+
+	pattern := args[0].(*String).Value
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return newError("Invalid regular expression %q: %s", pattern, err)
+	}
+
+	return &Regex{Value: regex}
+}
+
+// checkRegexAndString checks the leading regex and string arguments the regex builtins share
+func checkRegexAndString(name string, args []Object, expected int) (*regexp.Regexp, string, *Error) {
+	if len(args) != expected {
+		return nil, "", newError("Wrong number of arguments. Got: %d, Expected: %d", len(args), expected)
+	}
+	regex, ok := args[0].(*Regex)
+	if !ok {
+		return nil, "", newError("First argument to `%s` must be a Regex. Got: %s", name, args[0].Type())
+	}
+	str, ok := args[1].(*String)
+	if !ok {
+		return nil, "", newError("Second argument to `%s` must be a String. Got: %s", name, args[1].Type())
+	}
+	return regex.Value, str.Value, nil
+}
+
+// matchObject builds the object for the match of regex in s whose submatch indexes are loc
+func matchObject(regex *regexp.Regexp, s string, loc []int) Object {
+	if regex.NumSubexp() == 0 {
+		return &String{Value: s[loc[0]:loc[1]]}
+	}
+
+	elements := make([]Object, len(loc)/2)
+	for i := range elements {
+		if start := loc[2*i]; start >= 0 {
+			elements[i] = &String{Value: s[start:loc[2*i+1]]}
+		} else {
+			elements[i] = NullValue
+		}
+	}
+
+	return &Array{Elements: elements}
+}
+
+// bMatch returns the first match of a regex in a string, or null if there isn't one
+func bMatch(args ...Object) Object {
+	regex, s, err := checkRegexAndString("match", args, 2)
+	if err != nil {
+		return err
+	}
+
+	loc := regex.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil
+	}
+
+	return matchObject(regex, s, loc)
+}
+
+// bCaptures returns a Hash of the named groups in the first match of a regex in a string, or null
+// if there isn't a match
+func bCaptures(args ...Object) Object {
+	regex, s, err := checkRegexAndString("captures", args, 2)
+	if err != nil {
+		return err
+	}
+
+	loc := regex.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil
+	}
+
+	pairs := make(map[HashKey]HashPair)
+	for i, name := range regex.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		key := &String{Value: name}
+		var value Object = NullValue
+		if start := loc[2*i]; start >= 0 {
+			value = &String{Value: s[start:loc[2*i+1]]}
+		}
+		pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+	}
+
+	return &Hash{Pairs: pairs}
+}
+
+// bFindAll returns every match of a regex in a string
+func bFindAll(args ...Object) Object {
+	regex, s, err := checkRegexAndString("find_all", args, 2)
+	if err != nil {
+		return err
+	}
+
+	locs := regex.FindAllStringSubmatchIndex(s, -1)
+	elements := make([]Object, len(locs))
+	for i, loc := range locs {
+		elements[i] = matchObject(regex, s, loc)
+	}
+
+	return &Array{Elements: elements}
+}
+
+// bReplaceAll replaces every match of a regex in a string. The replacement is either a String,
+// in which $1 or ${name} stand for the groups of the match, or a function called with each match
+// that returns the String to put in its place
+func bReplaceAll(h Host, args ...Object) Object {
+	regex, s, err := checkRegexAndString("replace_all", args, 3)
+	if err != nil {
+		return err
+	}
+
+	if replacement, ok := args[2].(*String); ok {
+		return &String{Value: regex.ReplaceAllString(s, replacement.Value)}
+	}
+	if !isCallable(args[2]) {
+		return newError("Third argument to `replace_all` must be a String or a function. Got: %s", args[2].Type())
+	}
+
+	var result []byte
+	last := 0
+	for _, loc := range regex.FindAllStringSubmatchIndex(s, -1) {
+		replaced := h.Call(args[2], matchObject(regex, s, loc))
+		if isError(replaced) {
+			return replaced
+		}
+		str, ok := replaced.(*String)
+		if !ok {
+			return newError("Function passed to `replace_all` must return a String. Got: %s", replaced.Type())
+		}
+		result = append(result, s[last:loc[0]]...)
+		result = append(result, str.Value...)
+		last = loc[1]
+	}
+	result = append(result, s[last:]...)
+
+	return &String{Value: string(result)}
+}
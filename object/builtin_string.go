@@ -0,0 +1,260 @@
+package object
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// String builtins count and index in characters (runes) rather than bytes, same as `len`
+
+// maxStringLength is the most bytes a builtin builds a string of, so that a script asking for an
+// absurd length gets an error rather than crashing the interpreter
+const maxStringLength = 1 << 30
+
+// checkStrings checks the arguments of builtins that take only Strings
+func checkStrings(name string, args []Object, expected int) *Error {
+	if len(args) != expected {
+		return newError("Wrong number of arguments. Got: %d, Expected: %d", len(args), expected)
+	}
+	for i, arg := range args {
+		if arg.Type() == StringObj {
+			continue
+		}
+		if expected == 1 {
+			return newError("Argument to `%s` must be a String. Got: %s", name, arg.Type())
+		}
+		return newError("%s argument to `%s` must be a String. Got: %s", ordinals[i], name, arg.Type())
+	}
+	return nil
+}
+
+var ordinals = []string{"First", "Second", "Third"}
+
+func bUpper(args ...Object) Object {
+	if err := checkStrings("upper", args, 1); err != nil {
+		return err
+	}
+	return &String{Value: strings.ToUpper(args[0].(*String).Value)}
+}
+
+func bLower(args ...Object) Object {
+	if err := checkStrings("lower", args, 1); err != nil {
+		return err
+	}
+	return &String{Value: strings.ToLower(args[0].(*String).Value)}
+}
+
+func bTrim(args ...Object) Object {
+	if err := checkStrings("trim", args, 1); err != nil {
+		return err
+	}
+	return &String{Value: strings.TrimSpace(args[0].(*String).Value)}
+}
+
+func bTrimLeft(args ...Object) Object {
+	if err := checkStrings("trim_left", args, 1); err != nil {
+		return err
+	}
+	return &String{Value: strings.TrimLeft(args[0].(*String).Value, " \t\n\r\v\f")}
+}
+
+func bTrimRight(args ...Object) Object {
+	if err := checkStrings("trim_right", args, 1); err != nil {
+		return err
+	}
+	return &String{Value: strings.TrimRight(args[0].(*String).Value, " \t\n\r\v\f")}
+}
+
+// bContains reports whether a string contains a substring, or an array an element
+func bContains(args ...Object) Object {
+	if len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2", len(args))
+	}
+	if array, ok := args[0].(*Array); ok {
+		return NativeBoolToBoolean(arrayIndexOf(array, args[1]) != -1)
+	}
+	if err := checkStringOrArray("contains", args); err != nil {
+		return err
+	}
+	return NativeBoolToBoolean(strings.Contains(args[0].(*String).Value, args[1].(*String).Value))
+}
+
+func bStartsWith(args ...Object) Object {
+	if err := checkStrings("starts_with", args, 2); err != nil {
+		return err
+	}
+	return NativeBoolToBoolean(strings.HasPrefix(args[0].(*String).Value, args[1].(*String).Value))
+}
+
+func bEndsWith(args ...Object) Object {
+	if err := checkStrings("ends_with", args, 2); err != nil {
+		return err
+	}
+	return NativeBoolToBoolean(strings.HasSuffix(args[0].(*String).Value, args[1].(*String).Value))
+}
+
+// bIndexOf returns the index of the first character of the first match of a substring, or of
+// the first matching element of an array, or -1 if there isn't one
+func bIndexOf(args ...Object) Object {
+	if len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2", len(args))
+	}
+	if array, ok := args[0].(*Array); ok {
+		return &Integer{Value: int64(arrayIndexOf(array, args[1]))}
+	}
+	if err := checkStringOrArray("index_of", args); err != nil {
+		return err
+	}
+
+	str := args[0].(*String).Value
+	i := strings.Index(str, args[1].(*String).Value)
+	if i == -1 {
+		return &Integer{Value: -1}
+	}
+
+	return &Integer{Value: int64(utf8.RuneCountInString(str[:i]))}
+}
+
+// checkStringOrArray checks the arguments of builtins like name(String, String) that also
+// accept an Array as their first argument
+func checkStringOrArray(name string, args []Object) *Error {
+	if args[0].Type() != StringObj {
+		return newError("First argument to `%s` must be a String or an Array. Got: %s", name, args[0].Type())
+	}
+	if args[1].Type() != StringObj {
+		return newError("Second argument to `%s` must be a String. Got: %s", name, args[1].Type())
+	}
+	return nil
+}
+
+// bReplace replaces every occurrence of its second argument with its third
+func bReplace(args ...Object) Object {
+	if err := checkStrings("replace", args, 3); err != nil {
+		return err
+	}
+
+	str := args[0].(*String).Value
+	old := args[1].(*String).Value
+	replacement := args[2].(*String).Value
+
+	return &String{Value: strings.ReplaceAll(str, old, replacement)}
+}
+
+func bRepeat(h Host, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2", len(args))
+	}
+	if args[0].Type() != StringObj {
+		return newError("First argument to `repeat` must be a String. Got: %s", args[0].Type())
+	}
+	count, ok := args[1].(*Integer)
+	if !ok {
+		return newError("Second argument to `repeat` must be an Integer. Got: %s", args[1].Type())
+	}
+	if count.Value < 0 {
+		return newError("Second argument to `repeat` must not be negative. Got: %d", count.Value)
+	}
+	length := float64(len(args[0].(*String).Value)) * float64(count.Value)
+	if length > maxStringLength {
+		return newError("Result of `repeat` would be too long. Got: %.0f bytes, Max: %d", length, maxStringLength)
+	}
+	if err := checkAllocation(h, float64(StringSize(0))+length); err != nil {
+		return err
+	}
+
+	return &String{Value: strings.Repeat(args[0].(*String).Value, int(count.Value))}
+}
+
+func bPadLeft(h Host, args ...Object) Object {
+	return pad(h, "pad_left", args, true)
+}
+
+func bPadRight(h Host, args ...Object) Object {
+	return pad(h, "pad_right", args, false)
+}
+
+// pad pads a string with spaces, or a single character passed as the third argument, until it
+// is at least as many characters long as the second argument
+func pad(h Host, name string, args []Object, left bool) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2 or 3", len(args))
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("First argument to `%s` must be a String. Got: %s", name, args[0].Type())
+	}
+	width, ok := args[1].(*Integer)
+	if !ok {
+		return newError("Second argument to `%s` must be an Integer. Got: %s", name, args[1].Type())
+	}
+	padding := " "
+	if len(args) == 3 {
+		char, ok := args[2].(*String)
+		if !ok || utf8.RuneCountInString(char.Value) != 1 {
+			return newError("Third argument to `%s` must be a single character String. Got: %s", name, args[2].Inspect())
+		}
+		padding = char.Value
+	}
+
+	missing := width.Value - int64(utf8.RuneCountInString(str.Value))
+	if missing <= 0 {
+		return str
+	}
+	length := float64(len(str.Value)) + float64(missing)*float64(len(padding))
+	if length > maxStringLength {
+		return newError("Result of `%s` would be too long. Got: %.0f bytes, Max: %d", name, length, maxStringLength)
+	}
+	if err := checkAllocation(h, float64(StringSize(0))+length); err != nil {
+		return err
+	}
+	if left {
+		return &String{Value: strings.Repeat(padding, int(missing)) + str.Value}
+	}
+
+	return &String{Value: str.Value + strings.Repeat(padding, int(missing))}
+}
+
+// bChars splits a string into an array of its characters
+func bChars(args ...Object) Object {
+	if err := checkStrings("chars", args, 1); err != nil {
+		return err
+	}
+
+	chars := []Object{}
+	for _, r := range args[0].(*String).Value {
+		chars = append(chars, &String{Value: string(r)})
+	}
+
+	return &Array{Elements: chars}
+}
+
+// bOrd returns the Unicode code point of a single character string
+func bOrd(args ...Object) Object {
+	if err := checkStrings("ord", args, 1); err != nil {
+		return err
+	}
+
+	str := args[0].(*String).Value
+	if utf8.RuneCountInString(str) != 1 {
+		return newError("Argument to `ord` must be a single character. Got: %q", str)
+	}
+	r, _ := utf8.DecodeRuneInString(str)
+
+	return &Integer{Value: int64(r)}
+}
+
+// bChr returns the single character string for a Unicode code point
+func bChr(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	code, ok := args[0].(*Integer)
+	if !ok {
+		return newError("Argument to `chr` must be an Integer. Got: %s", args[0].Type())
+	}
+	if code.Value < 0 || code.Value > utf8.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+		return newError("Argument to `chr` is not a valid code point. Got: %d", code.Value)
+	}
+
+	return &String{Value: string(rune(code.Value))}
+}
@@ -0,0 +1,75 @@
+package object
+
+import (
+	"time"
+)
+
+// Times are passed around scripts as Integer milliseconds since the Unix epoch. format_time and
+// parse_time work in UTC and take layouts written the way the time package writes them, such as
+// "2006-01-02 15:04:05"
+
+func bNow(h Host, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 0", len(args))
+	}
+
+	clock, _ := h.Runtime().Clock()
+	return &Integer{Value: clock.Now().UnixMilli()}
+}
+
+// bClock returns the Float milliseconds since the interpreter started. It reads the monotonic
+// clock, so unlike now() it never jumps when the system time is changed
+func bClock(h Host, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 0", len(args))
+	}
+
+	clock, start := h.Runtime().Clock()
+	return &Float{Value: float64(clock.Now().Sub(start)) / float64(time.Millisecond)}
+}
+
+func bSleep(h Host, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	ms, ok := args[0].(*Integer)
+	if !ok {
+		return newError("Argument to `sleep` must be an Integer. Got: %s", args[0].Type())
+	}
+
+	clock, _ := h.Runtime().Clock()
+	if err := clock.Sleep(h.Runtime().Context(), time.Duration(ms.Value)*time.Millisecond); err != nil {
+		return newError("%s", err)
+	}
+
+	return nil
+}
+
+func bFormatTime(args ...Object) Object {
+	if len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2", len(args))
+	}
+	ts, ok := args[0].(*Integer)
+	if !ok {
+		return newError("First argument to `format_time` must be an Integer. Got: %s", args[0].Type())
+	}
+	layout, ok := args[1].(*String)
+	if !ok {
+		return newError("Second argument to `format_time` must be a String. Got: %s", args[1].Type())
+	}
+
+	return &String{Value: time.UnixMilli(ts.Value).UTC().Format(layout.Value)}
+}
+
+func bParseTime(args ...Object) Object {
+	if err := checkStrings("parse_time", args, 2); err != nil {
+		return err
+	}
+
+	t, err := time.Parse(args[1].(*String).Value, args[0].(*String).Value)
+	if err != nil {
+		return newError("Could not parse time: %s", err)
+	}
+
+	return &Integer{Value: t.UnixMilli()}
+}
@@ -0,0 +1,99 @@
+package object
+
+import (
+	"math"
+	"strconv"
+)
+
+// bType returns the type of its argument as a string. Functions report FUNCTION whether they are
+// evaluated or compiled to closures, so that scripts behave the same on either engine
+func bType(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+
+	return &String{Value: string(typeName(args[0]))}
+}
+
+// typeName returns obj's type as scripts see it, which is the same on either engine
+func typeName(obj Object) ObjectType {
+	switch obj.Type() {
+	case ClosureObj, CompiledFunctionObj:
+		return FunctionObj
+	default:
+		return obj.Type()
+	}
+}
+
+// bInt converts a string of decimal digits or a boolean (1 or 0) to an Integer, and truncates
+// a Float
+func bInt(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return arg
+	case *Float:
+		return wholeInteger(math.Trunc(arg.Value), arg)
+	case *Boolean:
+		if arg.Value {
+			return &Integer{Value: 1}
+		}
+		return &Integer{Value: 0}
+	case *String:
+		value, err := strconv.ParseInt(arg.Value, 10, 64)
+		if err != nil {
+			return newError("Could not convert %q to an Integer", arg.Value)
+		}
+		return &Integer{Value: value}
+	default:
+		return newError("Argument to `int` not supported. Got: %s", args[0].Type())
+	}
+}
+
+// wholeInteger converts whole, a whole number worked out from arg, to an Integer, or returns an
+// error if it is NaN or out of range
+func wholeInteger(whole float64, arg Object) Object {
+	if math.IsNaN(whole) || whole < math.MinInt64 || whole >= math.MaxInt64 {
+		return newError("Cannot convert %s to an Integer", arg.Inspect())
+	}
+	return &Integer{Value: int64(whole)}
+}
+
+// bStr converts any value to the string it prints as
+func bStr(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	if str, ok := args[0].(*String); ok {
+		return str
+	}
+
+	return &String{Value: args[0].Inspect()}
+}
+
+// bBool converts any value to a Boolean the way conditions do, so only false and null are false
+func bBool(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+
+	return NativeBoolToBoolean(IsTruthy(args[0]))
+}
+
+// isType returns a builtin that reports whether its argument has one of the given types
+func isType(types ...ObjectType) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+		}
+		for _, t := range types {
+			if args[0].Type() == t {
+				return TrueValue
+			}
+		}
+		return FalseValue
+	}
+}
@@ -0,0 +1,80 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Channel wraps a Go channel of Objects. Channels are how spawned functions hand values to
+// each other, and are the only Monkey value that is meant to be shared for synchronization
+type Channel struct {
+	ch     chan Object
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewChannel creates and returns a pointer to a Channel with the given buffer size
+func NewChannel(size int) *Channel {
+	return &Channel{ch: make(chan Object, size)}
+}
+
+// Type returns our Channel's ObjectType (ChannelObj)
+func (c *Channel) Type() ObjectType { return ChannelObj }
+
+// Inspect returns a string representation of the Channel with its address
+func (c *Channel) Inspect() string { return fmt.Sprintf("Channel[%p]", c) }
+
+// Send blocks until val is handed to a receiver or buffered. Sending on a closed channel
+// returns an error instead of panicking
+func (c *Channel) Send(val Object) error {
+	return c.SendContext(context.Background(), val)
+}
+
+// SendContext is Send that gives up with ctx's error once ctx is done
+func (c *Channel) SendContext(ctx context.Context, val Object) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = errors.New("send on closed channel")
+		}
+	}()
+
+	select {
+	case c.ch <- val:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Recv blocks until a value is available. It returns false once the channel is closed and drained
+func (c *Channel) Recv() (Object, bool) {
+	val, ok, _ := c.RecvContext(context.Background())
+	return val, ok
+}
+
+// RecvContext is Recv that gives up with ctx's error once ctx is done
+func (c *Channel) RecvContext(ctx context.Context) (Object, bool, error) {
+	select {
+	case val, ok := <-c.ch:
+		return val, ok, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// Close closes the channel. Closing an already closed channel returns an error
+func (c *Channel) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errors.New("close of closed channel")
+	}
+
+	c.closed = true
+	close(c.ch)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package object
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is where the time builtins get the time from. A Runtime uses the system clock unless it
+// is given another, such as a ManualClock for tests that shouldn't depend on the real time
+type Clock interface {
+	Now() time.Time
+	// Sleep pauses for d, or until ctx is done, when it returns ctx's error
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// SystemClock is the Clock backed by the time package
+type SystemClock struct{}
+
+// Now returns the current time, with its monotonic clock reading
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// Sleep pauses the calling goroutine for at least d, unless ctx is done first
+func (SystemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ManualClock is a Clock whose time only moves when it is told to. Sleeping advances it at once
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock creates a ManualClock set to now
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the clock's time
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep advances the clock by d without waiting, unless ctx is already done
+func (c *ManualClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Advance(d)
+	return nil
+}
+
+// Advance moves the clock forward by d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+}
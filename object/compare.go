@@ -0,0 +1,158 @@
+package object
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// typeOrder ranks types for compareObjects. Values of different types order by rank, and types
+// not listed here come after those that are
+var typeOrder = map[ObjectType]int{
+	NullObj:      0,
+	BooleanObj:   1,
+	IntegerObj:   2,
+	FloatObj:     2,
+	StringObj:    3,
+	EnumValueObj: 4,
+	ArrayObj:     5,
+	HashObj:      6,
+}
+
+// compareObjects returns -1, 0 or 1 as a orders before, the same as or after b. It is a total
+// ordering over every value, which is what `sort` and `uniq` need. Numbers and strings order
+// naturally, false before true, enum variants by enum name then declaration order, and arrays
+// and hashes (by their sorted pairs) element by element. Values with no natural order, such as
+// functions, are only equal to themselves. Integers and Floats order by value, an Integer coming
+// before a Float of the same value
+func compareObjects(a, b Object) int {
+	if a == b {
+		return 0
+	}
+
+	rankA, okA := typeOrder[a.Type()]
+	rankB, okB := typeOrder[b.Type()]
+	if !okA {
+		rankA = len(typeOrder)
+	}
+	if !okB {
+		rankB = len(typeOrder)
+	}
+	if rankA != rankB {
+		return compareInts(int64(rankA), int64(rankB))
+	}
+
+	switch a := a.(type) {
+	case *Null:
+		return 0
+	case *Boolean:
+		return compareBools(a.Value, b.(*Boolean).Value)
+	case *Integer, *Float:
+		return compareNumbers(a, b)
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value)
+	case *EnumValue:
+		b := b.(*EnumValue)
+		if a.Enum != b.Enum {
+			return strings.Compare(a.Enum, b.Enum)
+		}
+		return compareInts(int64(a.Ordinal), int64(b.Ordinal))
+	case *Array:
+		return compareSlices(a.Elements, b.(*Array).Elements)
+	case *Hash:
+		return compareSlices(sortedPairs(a), sortedPairs(b.(*Hash)))
+	default:
+		if c := strings.Compare(string(a.Type()), string(b.Type())); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Inspect(), b.Inspect())
+	}
+}
+
+// objectsEqual reports whether a and b are the same value, comparing arrays and hashes by contents
+func objectsEqual(a, b Object) bool {
+	return compareObjects(a, b) == 0
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareNumbers(a, b Object) int {
+	if a, ok := a.(*Integer); ok {
+		if b, ok := b.(*Integer); ok {
+			return compareInts(a.Value, b.Value)
+		}
+	}
+
+	// NaN orders before every other number so that the ordering stays total
+	x, _ := ToFloat(a)
+	y, _ := ToFloat(b)
+	switch {
+	case math.IsNaN(x) || math.IsNaN(y):
+		return compareBools(!math.IsNaN(x), !math.IsNaN(y))
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	case a.Type() == b.Type():
+		return 0
+	case a.Type() == IntegerObj:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareSlices(a, b []Object) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareObjects(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
+
+// sortedPairs flattens a hash into [key, value, key, value, ...] ordered by key
+func sortedPairs(h *Hash) []Object {
+	pairs := sortedHashPairs(h)
+
+	flat := make([]Object, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		flat = append(flat, pair.Key, pair.Value)
+	}
+
+	return flat
+}
+
+// sortedHashPairs returns a hash's pairs ordered by key, so that builtins like `keys` return
+// them in the same order every time
+func sortedHashPairs(h *Hash) []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return compareObjects(pairs[i].Key, pairs[j].Key) < 0
+	})
+
+	return pairs
+}
@@ -10,11 +10,14 @@ import (
 // literal and is an object.Object, which means we can add it as a constant to our
 // compiler.Bytecode and load it in the VM. It also holds the NumLocals which we pass
 // to the VM to allocate the correct amount of stack space ("hole") to save the local
-// bindings
+// bindings. IsGenerator tells the VM that calling the function creates a Generator rather
+// than running its body. Name is the name the function was bound to, if any, for error traces
 type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	IsGenerator   bool
+	Name          string
 }
 
 // Type returns our CompiledFunction's ObjectType (CompiledFunctionObj)
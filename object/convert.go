@@ -0,0 +1,475 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// FromGo and ToGo convert between Go values and Monkey objects, so Go hosts don't have to build
+// Arrays and Hashes by hand. Struct fields are named by their `monkey` tag when they have one,
+// otherwise by their Go name. A field tagged `monkey:"-"` and unexported fields are left out
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	hostType   = reflect.TypeOf((*Host)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// FromGo converts a Go value to a Monkey object:
+//
+//	nil, nil pointers, maps and slices   null
+//	bool                                 Boolean
+//	ints and uints                       Integer (uints that don't fit in an int64 are an error)
+//	floats                               Float
+//	string, []byte                       String
+//	slices and arrays                    Array
+//	maps with string, int or bool keys   Hash
+//	structs                              Hash with String keys, one for each field
+//	pointers                             what they point to
+//	funcs                                Builtin, see below
+//	Objects                              themselves
+//
+// A func becomes a builtin that converts its arguments with ToGo and its result with FromGo. It
+// may return nothing, a value, an error, or a value and an error; a non-nil error is returned to
+// the script as an *Error, and so is a panic. A func whose first parameter is a Host is given the
+// engine calling it. Values that contain themselves, such as a struct pointing back at itself,
+// can't be converted and are an error
+func FromGo(v any) (Object, error) {
+	if v == nil {
+		return NullValue, nil
+	}
+	return (&fromGo{visiting: map[visit]bool{}}).value(reflect.ValueOf(v))
+}
+
+// fromGo converts a Go value, keeping track of the pointers, maps and slices it is inside of so
+// that it notices when one of them refers back to itself
+type fromGo struct {
+	visiting map[visit]bool
+}
+
+// visit identifies a pointer, map or slice being converted. Slices sharing an array are only the
+// same value when they have the same length
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// enter records that v is being converted, failing if it already is
+func (c *fromGo) enter(v reflect.Value) (visit, error) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if c.visiting[key] {
+		return key, fmt.Errorf("cannot convert %s: it contains itself", v.Type())
+	}
+	c.visiting[key] = true
+	return key, nil
+}
+
+func (c *fromGo) value(v reflect.Value) (Object, error) {
+	if v.Type().Implements(objectType) {
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return NativeBoolToBoolean(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to an Integer: it is too large", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return c.value(v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		key, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c.visiting, key)
+		return c.value(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NullValue, nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return &String{Value: string(v.Bytes())}, nil
+		}
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			key, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(c.visiting, key)
+		}
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			element, err := c.value(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		visiting, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c.visiting, visiting)
+		pairs := make(map[HashKey]HashPair, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := c.value(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert %s to a Hash: %s can't be a hash key", v.Type(), describeType(typeName(key)))
+			}
+			value, err := c.value(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+	case reflect.Struct:
+		pairs := make(map[HashKey]HashPair)
+		for _, field := range structFields(v.Type()) {
+			value, err := c.value(v.FieldByIndex(field.index))
+			if err != nil {
+				return nil, err
+			}
+			key := &String{Value: field.name}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return fromGoFunc(v)
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Monkey value", v.Type())
+	}
+}
+
+// fromGoFunc wraps the func fn as a Builtin
+func fromGoFunc(fn reflect.Value) (Object, error) {
+	t := fn.Type()
+	withHost := t.NumIn() > 0 && t.In(0) == hostType
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("cannot convert %s to a Builtin: it must return at most a value and an error", t)
+	}
+
+	call := func(h Host, args ...Object) (result Object) {
+		params := t.NumIn()
+		in := make([]reflect.Value, 0, params)
+		if withHost {
+			in = append(in, reflect.ValueOf(&h).Elem())
+			params--
+		}
+
+		switch {
+		case t.IsVariadic() && len(args) < params-1:
+			return newError("Wrong number of arguments. Got: %d, Expected: at least %d", len(args), params-1)
+		case !t.IsVariadic() && len(args) != params:
+			return newError("Wrong number of arguments. Got: %d, Expected: %d", len(args), params)
+		}
+
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= params-1 {
+				paramType = t.In(t.NumIn() - 1).Elem()
+			} else {
+				paramType = t.In(len(in))
+			}
+			param := reflect.New(paramType)
+			if err := ToGo(arg, param.Interface()); err != nil {
+				return newError("Argument %d: %s", i+1, err)
+			}
+			in = append(in, param.Elem())
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				result = newError("Go function panicked: %v", r)
+			}
+		}()
+		out := fn.Call(in)
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return newError("%s", err)
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return nil
+		}
+
+		result, err := FromGo(out[0].Interface())
+		if err != nil {
+			return newError("%s", err)
+		}
+		// Objects fn returns may already exist, but whatever FromGo converts is new
+		if _, ok := out[0].Interface().(Object); !ok {
+			if err := allocate(h, builtSize(result, -1)); err != nil {
+				return err
+			}
+		}
+		return result
+	}
+
+	return &Builtin{HostFn: call}, nil
+}
+
+// ToGo converts a Monkey object to the Go value target points to, the reverse of FromGo. An Integer
+// converts to any int or uint it fits in, or a float. An Array converts to a slice, or an array of
+// the same length. A Hash converts to a map whose keys its keys convert to, or to a struct, whose
+// fields are set from the keys that name them; other keys are ignored. Null converts to the zero
+// value. When target points to an interface such as any, objects convert to the closest Go type:
+// int64, float64, string, bool, nil, []any and map[string]any (map[any]any if any key isn't a
+// String). A target of a type objects implement, such as Object, is simply assigned. A nil obj
+// converts like Null
+func ToGo(obj Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("cannot convert to %T: target must be a non-nil pointer", target)
+	}
+	return toGoValue(obj, v.Elem())
+}
+
+func toGoValue(obj Object, dst reflect.Value) error {
+	if obj == nil {
+		obj = NullValue
+	}
+	t := dst.Type()
+	if reflect.TypeOf(obj).AssignableTo(t) && (t.Kind() != reflect.Interface || t.Implements(objectType)) {
+		dst.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if obj.Type() == NullObj {
+		dst.SetZero()
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			break
+		}
+		value, err := toGoAny(obj)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			dst.SetZero()
+		} else {
+			dst.Set(reflect.ValueOf(value))
+		}
+		return nil
+	case reflect.Pointer:
+		elem := reflect.New(t.Elem())
+		if err := toGoValue(obj, elem.Elem()); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			dst.SetBool(b.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*Integer); ok {
+			if dst.OverflowInt(i.Value) {
+				return fmt.Errorf("cannot convert %d to %s: it is out of range", i.Value, t)
+			}
+			dst.SetInt(i.Value)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*Integer); ok {
+			if i.Value < 0 || dst.OverflowUint(uint64(i.Value)) {
+				return fmt.Errorf("cannot convert %d to %s: it is out of range", i.Value, t)
+			}
+			dst.SetUint(uint64(i.Value))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := ToFloat(obj); ok {
+			dst.SetFloat(f)
+			return nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			dst.SetString(s.Value)
+			return nil
+		}
+	case reflect.Slice:
+		if s, ok := obj.(*String); ok && t.Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes([]byte(s.Value))
+			return nil
+		}
+		if arr, ok := obj.(*Array); ok {
+			slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+			for i, element := range arr.Elements {
+				if err := toGoValue(element, slice.Index(i)); err != nil {
+					return err
+				}
+			}
+			dst.Set(slice)
+			return nil
+		}
+	case reflect.Array:
+		if arr, ok := obj.(*Array); ok {
+			if len(arr.Elements) != t.Len() {
+				return fmt.Errorf("cannot convert an Array of %d elements to %s", len(arr.Elements), t)
+			}
+			for i, element := range arr.Elements {
+				if err := toGoValue(element, dst.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*Hash); ok {
+			m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+			for _, pair := range hash.Pairs {
+				key := reflect.New(t.Key()).Elem()
+				if err := toGoValue(pair.Key, key); err != nil {
+					return err
+				}
+				value := reflect.New(t.Elem()).Elem()
+				if err := toGoValue(pair.Value, value); err != nil {
+					return err
+				}
+				m.SetMapIndex(key, value)
+			}
+			dst.Set(m)
+			return nil
+		}
+	case reflect.Struct:
+		if hash, ok := obj.(*Hash); ok {
+			for _, field := range structFields(t) {
+				pair, ok := hash.Pairs[(&String{Value: field.name}).HashKey()]
+				if !ok {
+					continue
+				}
+				if err := toGoValue(pair.Value, dst.FieldByIndex(field.index)); err != nil {
+					return fmt.Errorf("field %s: %w", field.name, err)
+				}
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", describeType(typeName(obj)), t)
+}
+
+// toGoAny converts obj to the Go value closest to it, for targets of type any
+func toGoAny(obj Object) (any, error) {
+	switch obj := obj.(type) {
+	case nil:
+		return nil, nil
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+	case *Array:
+		elements := make([]any, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := toGoAny(element)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *Hash:
+		stringKeys := make(map[string]any, len(obj.Pairs))
+		anyKeys := make(map[any]any, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, err := toGoAny(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := toGoAny(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := key.(string); ok && stringKeys != nil {
+				stringKeys[s] = value
+			} else {
+				stringKeys = nil
+			}
+			anyKeys[key] = value
+		}
+		if stringKeys != nil {
+			return stringKeys, nil
+		}
+		return anyKeys, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Go value", describeType(typeName(obj)))
+	}
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields lists the fields of the struct type t that convert to and from Hash keys
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("monkey"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag, _, _ = strings.Cut(tag, ","); tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: field.Index})
+	}
+	return fields
+}
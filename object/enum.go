@@ -0,0 +1,75 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Enum is the namespace produced by an enum declaration. It holds the enum's Name and
+// its Variants in declaration order
+type Enum struct {
+	Name     string
+	Variants []*EnumValue
+}
+
+// NewEnum creates and returns a pointer to an Enum with one EnumValue per variant name
+func NewEnum(name string, variants []string) *Enum {
+	enum := &Enum{Name: name}
+
+	for i, v := range variants {
+		enum.Variants = append(enum.Variants, &EnumValue{Enum: name, Name: v, Ordinal: i})
+	}
+
+	return enum
+}
+
+// Type returns our Enum's ObjectType (EnumObj)
+func (e *Enum) Type() ObjectType { return EnumObj }
+
+// Inspect returns a string representation of the Enum: enum Color { Red, Green, Blue }
+func (e *Enum) Inspect() string {
+	var out bytes.Buffer
+
+	variants := []string{}
+	for _, v := range e.Variants {
+		variants = append(variants, v.Name)
+	}
+
+	out.WriteString("enum ")
+	out.WriteString(e.Name)
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(variants, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// Variant looks up one of the Enum's variants by name
+func (e *Enum) Variant(name string) (*EnumValue, bool) {
+	for _, v := range e.Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// EnumValue is a single variant of an Enum. Two EnumValues are equal when they belong
+// to the same enum and have the same name
+type EnumValue struct {
+	Enum    string
+	Name    string
+	Ordinal int
+}
+
+// Type returns our EnumValue's ObjectType (EnumValueObj)
+func (ev *EnumValue) Type() ObjectType { return EnumValueObj }
+
+// Inspect returns a string representation of the EnumValue: Color.Red
+func (ev *EnumValue) Inspect() string { return ev.Enum + "." + ev.Name }
+
+// Equals reports whether other is the same variant of the same enum
+func (ev *EnumValue) Equals(other *EnumValue) bool {
+	return ev.Enum == other.Enum && ev.Name == other.Name
+}
@@ -1,15 +1,22 @@
 package object
 
-// Environment holds a store of key value pairs and a pointer to an "outer", enclosing environment
+import "sync"
+
+// Environment holds a store of key value pairs and a pointer to an "outer", enclosing environment.
+// The store is guarded so that spawned functions can share the environments they close over
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu      sync.RWMutex
+	store   map[string]Object
+	outer   *Environment
+	runtime *Runtime
 }
 
 // Get retrieves a key from an Environment's store by name. If it does not find it, it recursively looks
 // for the key in the enclosing environment(s)
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -18,20 +25,33 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // Set sets a key to an Environment's store by name
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
 
-// NewEnvironment creates and returns a pointer to an Environment
+// Runtime returns the Runtime shared by an Environment and all the environments enclosed by it
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
+// NewEnvironment creates and returns a pointer to an Environment with a Runtime of its own
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, runtime: NewRuntime()}
+}
+
+// NewEnvironmentWithRuntime creates an Environment with nothing enclosing it that shares rt,
+// such as one to expand macros in for code that runs with rt
+func NewEnvironmentWithRuntime(rt *Runtime) *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: nil, runtime: rt}
 }
 
 // NewEnclosedEnvironment creates a new Environment and attaches the outer environment
 // that's passed in, to the new environment, as it's enclosing environment
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: outer, runtime: outer.runtime}
 }
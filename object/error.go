@@ -3,6 +3,11 @@ package object
 // Error type holds an error Message
 type Error struct {
 	Message string
+
+	// Set on the error `exit` returns. It stops the script like any other error, but the host
+	// should end with the exit status Code rather than report a failure
+	Exit bool
+	Code int
 }
 
 // Type returns our Error's ObjectType (ErrorObj)
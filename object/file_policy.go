@@ -0,0 +1,73 @@
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FilePolicy lists the directories a script's file builtins may use. Each directory allows the
+// files and directories under it too. The zero value allows nothing, which is what interpreters
+// start with, so only scripts that are explicitly given access can touch the file system
+type FilePolicy struct {
+	Read  []string // Directories whose contents may be read and listed
+	Write []string // Directories whose contents may be written and removed
+}
+
+// CanRead reports whether the policy allows reading path
+func (p FilePolicy) CanRead(path string) bool {
+	return allowedPath(p.Read, path)
+}
+
+// CanWrite reports whether the policy allows writing or removing path
+func (p FilePolicy) CanWrite(path string) bool {
+	return allowedPath(p.Write, path)
+}
+
+// allowedPath reports whether path is, or is under, one of dirs. Symbolic links are followed
+// first so that a link inside an allowed directory can't reach outside of it
+func allowedPath(dirs []string, path string) bool {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return false
+	}
+
+	for _, dir := range dirs {
+		allowed, err := resolvePath(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(allowed, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolvePath returns the absolute path of path with symbolic links followed. Paths that don't
+// exist yet, such as a file about to be written, are resolved through their closest existing parent
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", err
+		}
+		missing = filepath.Join(filepath.Base(abs), missing)
+		abs = parent
+	}
+}
@@ -0,0 +1,37 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Float type holds the value of the float as a float64
+type Float struct {
+	Value float64
+}
+
+// Type returns our Float's ObjectType
+func (f *Float) Type() ObjectType { return FloatObj }
+
+// Inspect returns a string representation of the Float's Value. Whole numbers keep a decimal
+// point (2.0) so that they can't be mistaken for Integers
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(str, ".eIN") {
+		return str
+	}
+	return str + ".0"
+}
+
+// ToFloat returns the value of an Integer or Float as a float64, reporting false for anything
+// else. Arithmetic that mixes Integers and Floats converts both sides with it
+func ToFloat(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
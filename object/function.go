@@ -7,12 +7,13 @@ import (
 	"github.com/bradford-hamilton/monkey-lang/ast"
 )
 
-// Function holds Parameters as a slice of *Identifier, a Body which is a *ast.BlockStatement
-// and a pointer to it's environment
+// Function holds Parameters as a slice of *Identifier, a Body which is a *ast.BlockStatement,
+// a pointer to it's environment, and whether calling it creates a Generator
 type Function struct {
-	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	Parameters  []*ast.Identifier
+	Body        *ast.BlockStatement
+	Env         *Environment
+	IsGenerator bool
 }
 
 // Type returns our Function's ObjectType (FunctionObj)
@@ -0,0 +1,43 @@
+package object
+
+import "fmt"
+
+// Generator is the iterator returned by calling a function that contains a yield. The engine
+// that created it supplies resume, which runs the suspended function body until its next yield
+// and reports whether a value was produced
+type Generator struct {
+	resume func() (Object, bool)
+	done   bool
+}
+
+// NewGenerator creates and returns a pointer to a Generator driven by the given resume function
+func NewGenerator(resume func() (Object, bool)) *Generator {
+	return &Generator{resume: resume}
+}
+
+// Type returns our Generator's ObjectType (GeneratorObj)
+func (g *Generator) Type() ObjectType { return GeneratorObj }
+
+// Inspect returns a string representation of the Generator with its address
+func (g *Generator) Inspect() string { return fmt.Sprintf("Generator[%p]", g) }
+
+// Next resumes the generator and returns the next yielded value. Once the function body
+// finishes (or fails with an Error, which is handed back as the final value) Next keeps
+// returning false
+func (g *Generator) Next() (Object, bool) {
+	if g.done {
+		return nil, false
+	}
+
+	val, ok := g.resume()
+	if !ok {
+		g.done = true
+		return nil, false
+	}
+
+	if _, isErr := val.(*Error); isErr {
+		g.done = true
+	}
+
+	return val, true
+}
@@ -8,7 +8,7 @@ import (
 )
 
 // Hashable is one method called HashKey. Any object that that can be used as a HashKey
-// must implement this interface (*object.String, *object.boolean, *object.integer)
+// must implement this interface (*object.String, *object.boolean, *object.integer, *object.EnumValue)
 type Hashable interface {
 	HashKey() HashKey
 }
@@ -54,6 +54,18 @@ func (s *String) HashKey() HashKey {
 	}
 }
 
+// HashKey returns a HashKey with a Value of a 64-bit FNV-1a hash of the qualified variant
+// name (Color.Red) and a Type of EnumValueObj
+func (ev *EnumValue) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(ev.Inspect()))
+
+	return HashKey{
+		Type:  ev.Type(),
+		Value: h.Sum64(),
+	}
+}
+
 // HashPair holds key value pairs
 type HashPair struct {
 	Key   Object
@@ -61,7 +73,8 @@ type HashPair struct {
 }
 
 // Hash hold Pairs which are a map of HashKey -> HashPair. We map the keys to HashPairs instead
-// of Objects for a better ability to Inspect() and see the key and value
+// of Objects for a better ability to Inspect() and see the key and value. Pairs is never modified
+// once the Hash is built, which is what lets spawned functions share hashes without locking
 type Hash struct {
 	Pairs map[HashKey]HashPair
 }
@@ -0,0 +1,109 @@
+package object
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrStepLimit is the error a script stops with once it has taken more steps than its Runtime's
+// Limits allow
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// ErrMemoryLimit is the error a script stops with once it has created more Arrays, Hashes and
+// Strings than its Runtime's Limits allow
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// Limits bound how much work a script may do before it is stopped. A zero field is no limit,
+// so the zero value lets scripts run until they finish, which is what Runtimes start with
+type Limits struct {
+	// Steps is how many steps a script may take: instructions on the VM, which counts them in
+	// batches of a thousand or so and may overshoot by less than a batch, and function calls in
+	// the evaluator
+	Steps int64
+
+	// Memory is how many bytes of Arrays, Hashes and Strings a script may create, as measured by
+	// Size. It counts what the engines and builtins create rather than what is still in use, so
+	// it bounds the garbage a script makes as well as what it keeps
+	Memory int64
+}
+
+// Rough sizes, in bytes, of what Arrays, Hashes and Strings are made of, for memory accounting
+const (
+	headerSize   = 32 // An object and the slice, map or string header in it
+	elementSize  = 16 // An Object in an Array
+	hashPairSize = 64 // A HashKey and HashPair in a Hash
+)
+
+// ArraySize returns roughly how many bytes an Array of length elements takes up, not counting
+// the elements themselves
+func ArraySize(length int) int64 {
+	return headerSize + int64(length)*elementSize
+}
+
+// HashSize returns roughly how many bytes a Hash of pairs pairs takes up, not counting its keys
+// and values themselves
+func HashSize(pairs int) int64 {
+	return headerSize + int64(pairs)*hashPairSize
+}
+
+// StringSize returns roughly how many bytes a String of length bytes takes up
+func StringSize(length int) int64 {
+	return headerSize + int64(length)
+}
+
+// Size returns roughly how many bytes obj takes up if it is an Array, Hash or String, not counting
+// the objects it holds, which are counted when they are created. Anything else counts as 0
+func Size(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *Array:
+		return ArraySize(len(obj.Elements))
+	case *Hash:
+		return HashSize(len(obj.Pairs))
+	case *String:
+		return StringSize(len(obj.Value))
+	default:
+		return 0
+	}
+}
+
+// builtSize returns the Size of obj and of the objects it holds, depth levels down. A negative
+// depth counts everything it holds
+func builtSize(obj Object, depth int) int64 {
+	if depth == 0 {
+		return 0
+	}
+	size := Size(obj)
+	switch obj := obj.(type) {
+	case *Array:
+		for _, el := range obj.Elements {
+			size += builtSize(el, depth-1)
+		}
+	case *Hash:
+		for _, pair := range obj.Pairs {
+			size += builtSize(pair.Key, depth-1) + builtSize(pair.Value, depth-1)
+		}
+	}
+	return size
+}
+
+// allocate counts size bytes a builtin built against the memory limit of h's Runtime, returning
+// ErrMemoryLimit as an error object once the script has gone over it
+func allocate(h Host, size int64) *Error {
+	if h == nil || h.Runtime() == nil || size == 0 {
+		return nil
+	}
+	if err := h.Runtime().Allocate(size); err != nil {
+		return newError("%s", err)
+	}
+	return nil
+}
+
+// checkAllocation returns ErrMemoryLimit as an error object if building a value of roughly size
+// bytes would take the script over its memory limit. size is a float so that sizes worked out
+// from huge arguments can't overflow
+func checkAllocation(h Host, size float64) *Error {
+	if err := h.Runtime().CheckAllocation(int64(min(size, math.MaxInt64/2))); err != nil {
+		return newError("%s", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/bradford-hamilton/monkey-lang/ast"
+)
+
+// Macro holds Parameters as a slice of *Identifier, a Body which is a *ast.BlockStatement, and
+// a pointer to the environment it was defined in. Macros only exist during macro expansion
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+// Type returns our Macro's ObjectType (MacroObj)
+func (m *Macro) Type() ObjectType { return MacroObj }
+
+// Inspect returns a string representation of the macro definition
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
@@ -0,0 +1,33 @@
+package object
+
+// Modules are namespaces of builtins, and constants, that scripts reach with dot syntax:
+// math.sqrt(2). Each one is a Hash from member names to values, so it needs no support beyond
+// indexing from either engine, and keeping its members out of Builtins keeps them from taking
+// names scripts might want for themselves. Like Builtins, the compiler refers to them by index
+// in this slice, so new modules must be appended
+var Modules = []struct {
+	Name   string
+	Module *Hash
+}{
+	{"math", mathModule},
+}
+
+// GetModuleByName returns the module with the given name, or nil if there isn't one
+func GetModuleByName(name string) *Hash {
+	for _, def := range Modules {
+		if def.Name == name {
+			return def.Module
+		}
+	}
+	return nil
+}
+
+// newModule builds a module's Hash from its members
+func newModule(members map[string]Object) *Hash {
+	pairs := make(map[HashKey]HashPair, len(members))
+	for name, member := range members {
+		key := &String{Value: name}
+		pairs[key.HashKey()] = HashPair{Key: key, Value: member}
+	}
+	return &Hash{Pairs: pairs}
+}
@@ -0,0 +1,219 @@
+package object
+
+import "math"
+
+// mathModule holds the members of the math module. Functions that take numbers accept Integers
+// and Floats, returning an Integer when every argument is one and the result is a whole number
+var mathModule = newModule(map[string]Object{
+	"PI":    &Float{Value: math.Pi},
+	"E":     &Float{Value: math.E},
+	"abs":   &Builtin{Fn: mathAbs},
+	"min":   &Builtin{Fn: mathMin},
+	"max":   &Builtin{Fn: mathMax},
+	"pow":   &Builtin{Fn: mathPow},
+	"sqrt":  &Builtin{Fn: mathSqrt},
+	"floor": &Builtin{Fn: mathRounding("floor", math.Floor)},
+	"ceil":  &Builtin{Fn: mathRounding("ceil", math.Ceil)},
+	"round": &Builtin{Fn: mathRounding("round", math.Round)},
+	"gcd":   &Builtin{Fn: mathGcd},
+	"clamp": &Builtin{Fn: mathClamp},
+})
+
+// checkNumbers checks that every argument to the builtin name is an Integer or a Float
+func checkNumbers(name string, args []Object) *Error {
+	for _, arg := range args {
+		if _, ok := ToFloat(arg); !ok {
+			return newError("Arguments to `math.%s` must be Integers or Floats. Got: %s", name, arg.Type())
+		}
+	}
+	return nil
+}
+
+func mathAbs(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	if err := checkNumbers("abs", args); err != nil {
+		return err
+	}
+
+	if integer, ok := args[0].(*Integer); ok {
+		if integer.Value == math.MinInt64 {
+			return newError("Result of `math.abs` is too large for an Integer. Got: %d", integer.Value)
+		}
+		if integer.Value < 0 {
+			return &Integer{Value: -integer.Value}
+		}
+		return integer
+	}
+
+	return &Float{Value: math.Abs(args[0].(*Float).Value)}
+}
+
+func mathMin(args ...Object) Object {
+	return extreme("min", args, -1)
+}
+
+func mathMax(args ...Object) Object {
+	return extreme("max", args, 1)
+}
+
+// extreme returns the argument that compares as sign against all the others, keeping its type.
+// A single Array argument is treated as the list of numbers
+func extreme(name string, args []Object, sign int) Object {
+	if len(args) == 1 {
+		if array, ok := args[0].(*Array); ok {
+			args = array.Elements
+		}
+	}
+	if len(args) == 0 {
+		return newError("`math.%s` needs at least one number", name)
+	}
+	if err := checkNumbers(name, args); err != nil {
+		return err
+	}
+
+	result := args[0]
+	for _, arg := range args[1:] {
+		if compareNumbers(arg, result) == sign {
+			result = arg
+		}
+	}
+
+	return result
+}
+
+// mathPow raises its first argument to the power of its second. Integers raised to non-negative
+// Integer powers stay Integers, and are an error if the result doesn't fit in one
+func mathPow(args ...Object) Object {
+	if len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2", len(args))
+	}
+	if err := checkNumbers("pow", args); err != nil {
+		return err
+	}
+
+	base, baseIsInt := args[0].(*Integer)
+	exp, expIsInt := args[1].(*Integer)
+	if baseIsInt && expIsInt && exp.Value >= 0 {
+		result := int64(1)
+		for b, e := base.Value, exp.Value; e > 0; e >>= 1 {
+			var ok bool
+			if e&1 == 1 {
+				if result, ok = multiplyIntegers(result, b); !ok {
+					return newError("Result of `math.pow` is too large for an Integer. Got: %d and %d", base.Value, exp.Value)
+				}
+			}
+			// b is only squared when a higher bit of e will multiply it into the result
+			if e > 1 {
+				if b, ok = multiplyIntegers(b, b); !ok {
+					return newError("Result of `math.pow` is too large for an Integer. Got: %d and %d", base.Value, exp.Value)
+				}
+			}
+		}
+		return &Integer{Value: result}
+	}
+
+	x, _ := ToFloat(args[0])
+	y, _ := ToFloat(args[1])
+
+	return &Float{Value: math.Pow(x, y)}
+}
+
+func mathSqrt(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+	}
+	if err := checkNumbers("sqrt", args); err != nil {
+		return err
+	}
+
+	x, _ := ToFloat(args[0])
+	if x < 0 {
+		return newError("Argument to `math.sqrt` must not be negative. Got: %s", args[0].Inspect())
+	}
+
+	return &Float{Value: math.Sqrt(x)}
+}
+
+// mathRounding returns a builtin that rounds a Float to an Integer with round. Integers are
+// returned as they are
+func mathRounding(name string, round func(float64) float64) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("Wrong number of arguments. Got: %d, Expected: 1", len(args))
+		}
+		if err := checkNumbers(name, args); err != nil {
+			return err
+		}
+		if integer, ok := args[0].(*Integer); ok {
+			return integer
+		}
+
+		return wholeInteger(round(args[0].(*Float).Value), args[0])
+	}
+}
+
+// mathGcd returns the greatest common divisor of two Integers, which is never negative
+func mathGcd(args ...Object) Object {
+	if len(args) != 2 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 2", len(args))
+	}
+	a, ok := args[0].(*Integer)
+	if !ok {
+		return newError("Arguments to `math.gcd` must be Integers. Got: %s", args[0].Type())
+	}
+	b, ok := args[1].(*Integer)
+	if !ok {
+		return newError("Arguments to `math.gcd` must be Integers. Got: %s", args[1].Type())
+	}
+
+	x, y := a.Value, b.Value
+	for y != 0 {
+		x, y = y, x%y
+	}
+	if x == math.MinInt64 {
+		return newError("Result of `math.gcd` is too large for an Integer. Got: %d and %d", a.Value, b.Value)
+	}
+	if x < 0 {
+		x = -x
+	}
+
+	return &Integer{Value: x}
+}
+
+// multiplyIntegers returns a * b, reporting false if it overflows an int64
+func multiplyIntegers(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	c := a * b
+	return c, c/b == a
+}
+
+// mathClamp returns its first argument limited to the range given by its second and third
+func mathClamp(args ...Object) Object {
+	if len(args) != 3 {
+		return newError("Wrong number of arguments. Got: %d, Expected: 3", len(args))
+	}
+	if err := checkNumbers("clamp", args); err != nil {
+		return err
+	}
+
+	value, low, high := args[0], args[1], args[2]
+	if compareNumbers(low, high) > 0 {
+		return newError("Lower bound to `math.clamp` must not be greater than the upper bound. Got: %s and %s", low.Inspect(), high.Inspect())
+	}
+
+	switch {
+	case compareNumbers(value, low) < 0:
+		return low
+	case compareNumbers(value, high) > 0:
+		return high
+	default:
+		return value
+	}
+}
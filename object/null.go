@@ -8,3 +8,7 @@ func (n *Null) Type() ObjectType { return NullObj }
 
 // Inspect returns a string representation of Null ("null")
 func (n *Null) Inspect() string { return "null" }
+
+// NullValue is the one Null both engines use. Builtins that need to produce null inside of
+// another value (an Array element, say) use it so that identity checks keep working
+var NullValue = &Null{}
@@ -6,17 +6,26 @@ type ObjectType string
 // Define object types
 const (
 	IntegerObj          = "INTEGER"
+	FloatObj            = "FLOAT"
 	BooleanObj          = "BOOLEAN"
 	NullObj             = "NULL"
 	ReturnValueObj      = "RETURN_VALUE"
 	ErrorObj            = "ERROR"
 	FunctionObj         = "FUNCTION"
 	StringObj           = "STRING"
+	RegexObj            = "REGEX"
+	AnyObj              = "ANY" // Not the type of any object, matches every type in Builtin.Params
 	BuiltinObj          = "BUILTIN"
 	ArrayObj            = "ARRAY"
 	HashObj             = "HASH"
 	CompiledFunctionObj = "COMPILED_FUNCTION_OBJ"
 	ClosureObj          = "CLOSURE"
+	EnumObj             = "ENUM"
+	EnumValueObj        = "ENUM_VALUE"
+	GeneratorObj        = "GENERATOR"
+	ChannelObj          = "CHANNEL"
+	QuoteObj            = "QUOTE"
+	MacroObj            = "MACRO"
 )
 
 // Object represents monkey's object system. Every value in monkey-lang
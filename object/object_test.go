@@ -1,8 +1,18 @@
 package object
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bradford-hamilton/monkey-lang/ast"
 	"github.com/bradford-hamilton/monkey-lang/token"
@@ -65,6 +75,58 @@ func TestIntegerHashKey(t *testing.T) {
 	}
 }
 
+func TestEnumValueHashKey(t *testing.T) {
+	red1 := &EnumValue{Enum: "Color", Name: "Red"}
+	red2 := &EnumValue{Enum: "Color", Name: "Red"}
+	green := &EnumValue{Enum: "Color", Name: "Green", Ordinal: 1}
+	fruitRed := &EnumValue{Enum: "Fruit", Name: "Red"}
+
+	if red1.HashKey() != red2.HashKey() {
+		t.Errorf("enum values with same enum and name have different hash keys")
+	}
+
+	if red1.HashKey() == green.HashKey() {
+		t.Errorf("enum values with different names have same hash keys")
+	}
+
+	if red1.HashKey() == fruitRed.HashKey() {
+		t.Errorf("enum values from different enums have same hash keys")
+	}
+}
+
+func TestEnum(t *testing.T) {
+	enum := NewEnum("Color", []string{"Red", "Green", "Blue"})
+
+	if enum.Type() != EnumObj {
+		t.Errorf("enum.Type() returned wrong type. Expected: EnumObj. Got: %s", enum.Type())
+	}
+
+	if enum.Inspect() != "enum Color { Red, Green, Blue }" {
+		t.Errorf("enum.Inspect() returned wrong string representation. Expected: enum Color { Red, Green, Blue }. Got: %s", enum.Inspect())
+	}
+
+	blue, ok := enum.Variant("Blue")
+	if !ok {
+		t.Fatalf("enum.Variant(\"Blue\") not found")
+	}
+
+	if blue.Type() != EnumValueObj {
+		t.Errorf("blue.Type() returned wrong type. Expected: EnumValueObj. Got: %s", blue.Type())
+	}
+
+	if blue.Inspect() != "Color.Blue" {
+		t.Errorf("blue.Inspect() returned wrong string representation. Expected: Color.Blue. Got: %s", blue.Inspect())
+	}
+
+	if blue.Ordinal != 2 {
+		t.Errorf("blue.Ordinal wrong. Expected: 2. Got: %d", blue.Ordinal)
+	}
+
+	if _, ok := enum.Variant("Purple"); ok {
+		t.Errorf("enum.Variant(\"Purple\") should not be found")
+	}
+}
+
 func TestHash(t *testing.T) {
 	h := &Hash{
 		Pairs: map[HashKey]HashPair{
@@ -312,10 +374,73 @@ func TestLen(t *testing.T) {
 
 func TestPrint(t *testing.T) {
 	str := &String{Value: "neat string"}
+	var out bytes.Buffer
+	rt := NewRuntime()
+	rt.SetOutput(&out)
 
 	printBuiltin := GetBuiltinByName("print")
-	if printBuiltin.Fn(str) != nil {
-		t.Errorf("print builtin should print its arguments and return nil. Returned: %s", printBuiltin.Fn(str))
+	if res := printBuiltin.Call(builtinHost{rt: rt}, str, &Integer{Value: 5}); res != nil {
+		t.Errorf("print builtin should print its arguments and return nil. Returned: %s", res)
+	}
+	if out.String() != "neat string\n5\n" {
+		t.Errorf("print builtin wrote wrong output. Expected: %q. Got: %q", "neat string\n5\n", out.String())
+	}
+}
+
+func TestFormatBuiltins(t *testing.T) {
+	var out bytes.Buffer
+	rt := NewRuntime()
+	rt.SetOutput(&out)
+	h := builtinHost{rt: rt}
+	call := func(name string, args ...Object) string {
+		res := GetBuiltinByName(name).Call(h, args...)
+		if res == nil {
+			return "null"
+		}
+		return res.Inspect()
+	}
+	str := func(s string) *String { return &String{Value: s} }
+	arr := &Array{Elements: []Object{&Integer{Value: 1}, str("a")}}
+
+	tests := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{str("no verbs")}, "no verbs"},
+		{[]Object{str("%s=%v %v"), str("x"), arr, NullValue}, "x=[1, a] null"},
+		{[]Object{str("%d|%5d|%-5d|%05d|%+d|%x|%X|%b|%o"), &Integer{Value: 42}, &Integer{Value: 42}, &Integer{Value: 42}, &Integer{Value: 42}, &Integer{Value: 42}, &Integer{Value: 255}, str("hi"), &Integer{Value: 5}, &Integer{Value: 8}}, "42|   42|42   |00042|+42|ff|6869|101|10"},
+		{[]Object{str("%.2f %f %.1e %g"), &Float{Value: 3.14159}, &Integer{Value: 2}, &Float{Value: 1234.5}, &Float{Value: 0.5}}, "3.14 2.000000 1.2e+03 0.5"},
+		{[]Object{str("%q %q %t %T %T %%"), str("a b"), arr, TrueValue, &Float{Value: 1}, &Closure{}}, `"a b" "[1, a]" true FLOAT FUNCTION %`},
+		{[]Object{str("%d"), str("1")}, "Error: Verb %d in the format string passed to `sprintf` needs an Integer. Got: STRING"},
+		{[]Object{str("%x"), TrueValue}, "Error: Verb %x in the format string passed to `sprintf` needs an Integer or String. Got: BOOLEAN"},
+		{[]Object{str("%f"), str("1")}, "Error: Verb %f in the format string passed to `sprintf` needs a number. Got: STRING"},
+		{[]Object{str("%t"), &Integer{Value: 1}}, "Error: Verb %t in the format string passed to `sprintf` needs a Boolean. Got: INTEGER"},
+		{[]Object{str("%y"), &Integer{Value: 1}}, "Error: Unknown verb %y in the format string passed to `sprintf`"},
+		{[]Object{str("%d %d"), &Integer{Value: 1}}, "Error: Not enough arguments for the format string passed to `sprintf`. Got: 1"},
+		{[]Object{str("%d"), &Integer{Value: 1}, &Integer{Value: 2}}, "Error: Too many arguments for the format string passed to `sprintf`. Got: 2, Expected: 1"},
+		{[]Object{str("%.f|%8.3f|%-6.2s|"), &Float{Value: 2.5}, &Float{Value: 3.14159}, str("abc")}, "2|   3.142|ab    |"},
+		{[]Object{str("%999999999999d"), &Integer{Value: 1}}, "Error: Width in the format string passed to `sprintf` must be at most 1000000. Got: 999999999999"},
+		{[]Object{str("%.99999999999999999999f"), &Float{Value: 1}}, "Error: Precision in the format string passed to `sprintf` must be at most 1000000. Got: 99999999999999999999"},
+		{[]Object{str("%1.2.3f"), &Float{Value: 1}}, "Error: Unknown verb %. in the format string passed to `sprintf`"},
+		{[]Object{str("%5-d"), &Integer{Value: 1}}, "Error: Unknown verb %- in the format string passed to `sprintf`"},
+		{[]Object{str("100%")}, "Error: Format string passed to `sprintf` ends in an unfinished verb: %"},
+		{[]Object{&Integer{Value: 1}}, "Error: First argument to `sprintf` must be a String. Got: INTEGER"},
+		{[]Object{}, "Error: Wrong number of arguments. Got: 0, Expected: at least 1"},
+	}
+
+	for _, tt := range tests {
+		if res := call("sprintf", tt.args...); res != tt.expected {
+			t.Errorf("sprintf builtin returned wrong result. Expected: %s. Got: %s", tt.expected, res)
+		}
+	}
+
+	if res := call("printf", str("%s:%03d;"), str("id"), &Integer{Value: 7}); res != "null" {
+		t.Errorf("printf builtin should return null. Got: %s", res)
+	}
+	call("write", str("a"), &Integer{Value: 1}, arr)
+	call("write")
+	if out.String() != "id:007;a1[1, a]" {
+		t.Errorf("printf and write builtins wrote wrong output. Got: %q", out.String())
 	}
 }
 
@@ -486,3 +611,1274 @@ func TestJoin(t *testing.T) {
 		t.Errorf("split builtin returned wrong result. Expected: My name is brad. Got: %s", joinBuiltin.Fn(array, joinOn).Inspect())
 	}
 }
+
+func TestGenerator(t *testing.T) {
+	values := []Object{&Integer{Value: 1}, &Integer{Value: 2}}
+	i := 0
+	gen := NewGenerator(func() (Object, bool) {
+		if i >= len(values) {
+			return nil, false
+		}
+		i++
+		return values[i-1], true
+	})
+
+	if gen.Type() != GeneratorObj {
+		t.Errorf("gen.Type() returned wrong type. Expected: GeneratorObj. Got: %s", gen.Type())
+	}
+
+	if gen.Inspect() != fmt.Sprintf("Generator[%p]", gen) {
+		t.Errorf("gen.Inspect() returned wrong string representation. Expected: Generator[%p]. Got: %s", gen, gen.Inspect())
+	}
+
+	nextBuiltin := GetBuiltinByName("next")
+	if nextBuiltin.Fn(gen).Inspect() != "1" {
+		t.Errorf("next builtin returned wrong result. Expected: 1")
+	}
+	if nextBuiltin.Fn(gen).Inspect() != "2" {
+		t.Errorf("next builtin returned wrong result. Expected: 2")
+	}
+	if nextBuiltin.Fn(gen) != nil {
+		t.Errorf("next builtin should return nil once the generator is exhausted")
+	}
+	if nextBuiltin.Fn(&Integer{}).Inspect() != "Error: Argument to `next` must be a Generator. Got: INTEGER" {
+		t.Errorf("next builtin returned wrong result. Got: %s", nextBuiltin.Fn(&Integer{}).Inspect())
+	}
+
+	failing := NewGenerator(func() (Object, bool) {
+		return &Error{Message: "boom"}, true
+	})
+	if _, ok := failing.Next(); !ok {
+		t.Errorf("failing generator should hand back its error")
+	}
+	if _, ok := failing.Next(); ok {
+		t.Errorf("failing generator should be exhausted after its error")
+	}
+}
+
+func TestChannel(t *testing.T) {
+	ch := NewChannel(1)
+
+	if ch.Type() != ChannelObj {
+		t.Errorf("ch.Type() returned wrong type. Expected: ChannelObj. Got: %s", ch.Type())
+	}
+
+	if ch.Inspect() != fmt.Sprintf("Channel[%p]", ch) {
+		t.Errorf("ch.Inspect() returned wrong string representation. Expected: Channel[%p]. Got: %s", ch, ch.Inspect())
+	}
+
+	if err := ch.Send(&Integer{Value: 1}); err != nil {
+		t.Fatalf("ch.Send returned an error: %s", err)
+	}
+	if val, ok := ch.Recv(); !ok || val.Inspect() != "1" {
+		t.Errorf("ch.Recv returned wrong result. Expected: 1. Got: %v", val)
+	}
+
+	if err := ch.Close(); err != nil {
+		t.Fatalf("ch.Close returned an error: %s", err)
+	}
+	if _, ok := ch.Recv(); ok {
+		t.Errorf("ch.Recv should report a closed channel")
+	}
+	if err := ch.Send(&Integer{Value: 2}); err == nil || err.Error() != "send on closed channel" {
+		t.Errorf("ch.Send on a closed channel returned wrong error. Got: %v", err)
+	}
+	if err := ch.Close(); err == nil || err.Error() != "close of closed channel" {
+		t.Errorf("ch.Close on a closed channel returned wrong error. Got: %v", err)
+	}
+
+	selectBuiltin := GetBuiltinByName("select")
+	h := builtinHost{rt: NewRuntime()}
+	ready := NewChannel(1)
+	ready.Send(&String{Value: "hi"})
+	if res := selectBuiltin.Call(h, &Array{Elements: []Object{NewChannel(0), ready}}); res.Inspect() != "[1, hi]" {
+		t.Errorf("select builtin returned wrong result. Expected: [1, hi]. Got: %s", res.Inspect())
+	}
+	if res := selectBuiltin.Call(h, &Array{Elements: []Object{ch}}); res.Inspect() != "[0, null]" {
+		t.Errorf("select builtin returned wrong result. Expected: [0, null]. Got: %s", res.Inspect())
+	}
+	if res := selectBuiltin.Call(h, &Array{}); res.Inspect() != "Error: Argument to `select` must not be empty" {
+		t.Errorf("select builtin returned wrong result. Got: %s", res.Inspect())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := NewChannel(0).RecvContext(ctx); err != context.Canceled {
+		t.Errorf("ch.RecvContext with a cancelled context returned wrong error. Got: %v", err)
+	}
+	if err := NewChannel(0).SendContext(ctx, NullValue); err != context.Canceled {
+		t.Errorf("ch.SendContext with a cancelled context returned wrong error. Got: %v", err)
+	}
+}
+
+func TestBlockingBuiltinsStop(t *testing.T) {
+	input, w := io.Pipe()
+	defer w.Close()
+
+	rt := NewRuntime()
+	rt.SetStdin(input)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rt.SetContext(ctx)
+	h := builtinHost{rt: rt}
+
+	tests := []struct {
+		name string
+		args []Object
+	}{
+		{"sleep", []Object{&Integer{Value: 5000}}},
+		{"recv", []Object{NewChannel(0)}},
+		{"send", []Object{NewChannel(0), NullValue}},
+		{"select", []Object{&Array{Elements: []Object{NewChannel(0)}}}},
+		{"read_line", nil},
+		{"stdin_lines", nil},
+	}
+
+	for _, tt := range tests {
+		start := time.Now()
+		res := GetBuiltinByName(tt.name).Call(h, tt.args...)
+		if err, ok := res.(*Error); !ok || err.Message != context.DeadlineExceeded.Error() {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %v", tt.name, context.DeadlineExceeded, res)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s builtin took %s to stop", tt.name, elapsed)
+		}
+	}
+}
+
+func TestQuoteAndMacro(t *testing.T) {
+	node := &ast.InfixExpression{
+		Token:    token.Token{Type: token.Plus, Literal: "+"},
+		Left:     &ast.Identifier{Token: token.Token{Type: token.Identifier, Literal: "a"}, Value: "a"},
+		Operator: "+",
+		Right:    &ast.Identifier{Token: token.Token{Type: token.Identifier, Literal: "b"}, Value: "b"},
+	}
+
+	quote := &Quote{Node: node}
+	if quote.Type() != QuoteObj {
+		t.Errorf("quote.Type() returned wrong type. Expected: QuoteObj. Got: %s", quote.Type())
+	}
+	if quote.Inspect() != "QUOTE((a + b))" {
+		t.Errorf("quote.Inspect() returned wrong string representation. Expected: QUOTE((a + b)). Got: %s", quote.Inspect())
+	}
+
+	macro := &Macro{
+		Parameters: []*ast.Identifier{{Token: token.Token{Type: token.Identifier, Literal: "a"}, Value: "a"}},
+		Body:       &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: node}}},
+		Env:        NewEnvironment(),
+	}
+	if macro.Type() != MacroObj {
+		t.Errorf("macro.Type() returned wrong type. Expected: MacroObj. Got: %s", macro.Type())
+	}
+	if macro.Inspect() != "macro(a) {\n(a + b)\n}" {
+		t.Errorf("macro.Inspect() returned wrong string representation. Got: %q", macro.Inspect())
+	}
+}
+
+// builtinHost is a Host that can only call builtins, enough to test the builtins that need a Host
+type builtinHost struct {
+	rt *Runtime
+}
+
+func (h builtinHost) Runtime() *Runtime { return h.rt }
+
+func (h builtinHost) Call(fn Object, args ...Object) Object {
+	b, ok := fn.(*Builtin)
+	if !ok {
+		return newError("not a builtin: %s", fn.Type())
+	}
+	return b.Call(h, args...)
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	h := builtinHost{}
+	lenBuiltin := GetBuiltinByName("len")
+	words := &Array{Elements: []Object{&String{Value: "ccc"}, &String{Value: "a"}, &String{Value: "bb"}}}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"map", []Object{words, lenBuiltin}, "[3, 1, 2]"},
+		{"filter", []Object{words, lenBuiltin}, "[ccc, a, bb]"},
+		{"any", []Object{&Array{}, lenBuiltin}, "false"},
+		{"all", []Object{&Array{}, lenBuiltin}, "true"},
+		{"find", []Object{words, lenBuiltin}, "ccc"},
+		{"sort_by", []Object{words, lenBuiltin}, "[a, bb, ccc]"},
+		{"map", []Object{words}, "Error: Wrong number of arguments. Got: 1, Expected: 2"},
+		{"reduce", []Object{words, lenBuiltin}, "Error: Wrong number of arguments. Got: 2, Expected: 3"},
+		{"each", []Object{&Integer{Value: 1}, lenBuiltin}, "Error: First argument to `each` must be an Array or a Generator. Got: INTEGER"},
+		{"filter", []Object{words, words}, "Error: Last argument to `filter` must be a function. Got: ARRAY"},
+		{"map", []Object{&Array{Elements: []Object{&Integer{Value: 1}}}, lenBuiltin}, "Error: Argument to `len` not supported. Got: INTEGER"},
+		{"map", []Object{words, &Closure{}}, "Error: not a builtin: CLOSURE"},
+	}
+
+	for _, tt := range tests {
+		res := GetBuiltinByName(tt.name).Call(h, tt.args...)
+		if res.Inspect() != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res.Inspect())
+		}
+	}
+
+	if !IsTruthy(&Integer{Value: 0}) || IsTruthy(NullValue) || IsTruthy(FalseValue) {
+		t.Errorf("IsTruthy should treat only false and null as falsey")
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	str := &String{Value: "  Héllo  "}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"len", []Object{str}, "9"},
+		{"upper", []Object{str}, "  HÉLLO  "},
+		{"trim", []Object{str}, "Héllo"},
+		{"trim_left", []Object{str}, "Héllo  "},
+		{"trim_right", []Object{str}, "  Héllo"},
+		{"index_of", []Object{str, &String{Value: "l"}}, "4"},
+		{"pad_right", []Object{&String{Value: "é"}, &Integer{Value: 3}, &String{Value: "."}}, "é.."},
+		{"chr", []Object{&Integer{Value: 0x110000}}, "Error: Argument to `chr` is not a valid code point. Got: 1114112"},
+		{"ends_with", []Object{str}, "Error: Wrong number of arguments. Got: 1, Expected: 2"},
+		{"pad_left", []Object{str}, "Error: Wrong number of arguments. Got: 1, Expected: 2 or 3"},
+		{"repeat", []Object{str, str}, "Error: Second argument to `repeat` must be an Integer. Got: STRING"},
+		{"repeat", []Object{&String{Value: "ab"}, &Integer{Value: math.MaxInt64}}, "Error: Result of `repeat` would be too long. Got: 18446744073709551616 bytes, Max: 1073741824"},
+		{"pad_left", []Object{&String{Value: "a"}, &Integer{Value: math.MaxInt64}}, "Error: Result of `pad_left` would be too long. Got: 9223372036854775808 bytes, Max: 1073741824"},
+		{"pad_right", []Object{&String{Value: "a"}, &Integer{Value: 1 << 31}, &String{Value: "é"}}, "Error: Result of `pad_right` would be too long. Got: 4294967295 bytes, Max: 1073741824"},
+	}
+
+	for _, tt := range tests {
+		res := GetBuiltinByName(tt.name).Call(builtinHost{rt: NewRuntime()}, tt.args...)
+		if res.Inspect() != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res.Inspect())
+		}
+	}
+}
+
+func TestCompareObjects(t *testing.T) {
+	enum := NewEnum("Color", []string{"Red", "Green"})
+	ordered := []Object{
+		NullValue,
+		FalseValue,
+		TrueValue,
+		&Integer{Value: -1},
+		&Integer{Value: 2},
+		&String{Value: "B"},
+		&String{Value: "a"},
+		enum.Variants[0],
+		enum.Variants[1],
+		&Array{Elements: []Object{&Integer{Value: 1}}},
+		&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 0}}},
+		&Array{Elements: []Object{&Integer{Value: 2}}},
+		&Hash{Pairs: map[HashKey]HashPair{}},
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			want := compareInts(int64(i), int64(j))
+			if got := compareObjects(ordered[i], ordered[j]); got != want {
+				t.Errorf("compareObjects(%s, %s) returned wrong result. Expected: %d. Got: %d", ordered[i].Inspect(), ordered[j].Inspect(), want, got)
+			}
+		}
+	}
+
+	if !objectsEqual(&Array{Elements: []Object{&String{Value: "x"}}}, &Array{Elements: []Object{&String{Value: "x"}}}) {
+		t.Errorf("objectsEqual should compare arrays by their elements")
+	}
+
+	sortBuiltin := GetBuiltinByName("sort")
+	shuffled := &Array{Elements: []Object{ordered[6], ordered[0], ordered[12], ordered[3], ordered[9], ordered[1]}}
+	if res := sortBuiltin.Fn(shuffled).Inspect(); res != "[null, false, -1, a, [1], {}]" {
+		t.Errorf("sort builtin returned wrong result. Got: %s", res)
+	}
+	if res := shuffled.Inspect(); res != "[a, null, {}, -1, [1], false]" {
+		t.Errorf("sort builtin should not modify its argument. Got: %s", res)
+	}
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"type", []Object{&Closure{Fn: &CompiledFunction{}}}, "FUNCTION"},
+		{"type", []Object{&Function{}}, "FUNCTION"},
+		{"type", []Object{NewChannel(0)}, "CHANNEL"},
+		{"int", []Object{&String{Value: "9223372036854775808"}}, "Error: Could not convert \"9223372036854775808\" to an Integer"},
+		{"int", []Object{NullValue}, "Error: Argument to `int` not supported. Got: NULL"},
+		{"int", []Object{&Float{Value: -2.9}}, "-2"},
+		{"int", []Object{&Float{Value: 1e300}}, "Error: Cannot convert 1e+300 to an Integer"},
+		{"int", []Object{&Float{Value: math.NaN()}}, "Error: Cannot convert NaN to an Integer"},
+		{"str", []Object{TrueValue}, "true"},
+		{"bool", []Object{FalseValue}, "false"},
+		{"is_function", []Object{&Builtin{}}, "true"},
+		{"is_array", []Object{}, "Error: Wrong number of arguments. Got: 0, Expected: 1"},
+	}
+
+	for _, tt := range tests {
+		res := GetBuiltinByName(tt.name).Fn(tt.args...)
+		if res.Inspect() != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res.Inspect())
+		}
+	}
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-0.25, "-0.25"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Type() != FloatObj {
+			t.Errorf("f.Type() returned wrong type. Expected: FloatObj. Got: %s", f.Type())
+		}
+		if f.Inspect() != tt.expected {
+			t.Errorf("f.Inspect() returned wrong string representation. Expected: %s. Got: %s", tt.expected, f.Inspect())
+		}
+	}
+
+	if v, ok := ToFloat(&Integer{Value: 3}); !ok || v != 3 {
+		t.Errorf("ToFloat should convert Integers. Got: %v, %t", v, ok)
+	}
+	if _, ok := ToFloat(&String{Value: "3"}); ok {
+		t.Errorf("ToFloat should not convert Strings")
+	}
+
+	ordered := []Object{&Float{Value: math.NaN()}, &Float{Value: -1.5}, &Integer{Value: 1}, &Float{Value: 1}, &Float{Value: 1.5}, &Integer{Value: 2}}
+	for i := range ordered {
+		for j := range ordered {
+			want := compareInts(int64(i), int64(j))
+			if got := compareObjects(ordered[i], ordered[j]); got != want {
+				t.Errorf("compareObjects(%s, %s) returned wrong result. Expected: %d. Got: %d", ordered[i].Inspect(), ordered[j].Inspect(), want, got)
+			}
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	mathModule := GetModuleByName("math")
+	if mathModule == nil {
+		t.Fatalf("GetModuleByName(\"math\") returned nil")
+	}
+	if GetModuleByName("notAModule") != nil {
+		t.Errorf("GetModuleByName(\"notAModule\") should have returned nil")
+	}
+	if GetBuiltinByName("sqrt") != nil {
+		t.Errorf("math members should not be flat builtins")
+	}
+
+	member := func(name string) *Builtin {
+		key := &String{Value: name}
+		return mathModule.Pairs[key.HashKey()].Value.(*Builtin)
+	}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"abs", []Object{&Integer{Value: -4}}, "4"},
+		{"max", []Object{&Integer{Value: 1}, &Float{Value: 1.5}}, "1.5"},
+		{"pow", []Object{&Integer{Value: 3}, &Integer{Value: 3}}, "27"},
+		{"pow", []Object{&Float{Value: 4}, &Float{Value: 0.5}}, "2.0"},
+		{"floor", []Object{&Float{Value: -1.5}}, "-2"},
+		{"ceil", []Object{&Float{Value: math.NaN()}}, "Error: Cannot convert NaN to an Integer"},
+		{"round", []Object{&Integer{Value: 7}}, "7"},
+		{"gcd", []Object{&Integer{Value: 0}, &Integer{Value: 0}}, "0"},
+		{"sqrt", []Object{}, "Error: Wrong number of arguments. Got: 0, Expected: 1"},
+		{"clamp", []Object{&Integer{Value: 5}, &Integer{Value: 0}}, "Error: Wrong number of arguments. Got: 2, Expected: 3"},
+	}
+
+	for _, tt := range tests {
+		res := member(tt.name).Fn(tt.args...)
+		if res.Inspect() != tt.expected {
+			t.Errorf("math.%s returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res.Inspect())
+		}
+	}
+}
+
+func TestRandomBuiltins(t *testing.T) {
+	call := func(h Host, name string, args ...Object) Object {
+		return GetBuiltinByName(name).Call(h, args...)
+	}
+	draw := func(h Host) string {
+		arr := &Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}, &Integer{Value: 4}}}
+		return call(h, "random").Inspect() + " " +
+			call(h, "random_int", &Integer{Value: -5}, &Integer{Value: 5}).Inspect() + " " +
+			call(h, "choice", arr).Inspect() + " " +
+			call(h, "shuffle", arr).Inspect()
+	}
+
+	first, second := builtinHost{rt: NewRuntime()}, builtinHost{rt: NewRuntime()}
+	call(first, "seed", &Integer{Value: 42})
+	call(second, "seed", &Integer{Value: 42})
+	if a, b := draw(first), draw(second); a != b {
+		t.Errorf("runtimes seeded the same should draw the same numbers. Got: %s and %s", a, b)
+	}
+
+	h := builtinHost{rt: NewRuntime()}
+	for i := 0; i < 100; i++ {
+		if v := call(h, "random").(*Float).Value; v < 0 || v >= 1 {
+			t.Fatalf("random returned a number outside [0, 1). Got: %v", v)
+		}
+		if v := call(h, "random_int", &Integer{Value: 1}, &Integer{Value: 3}).(*Integer).Value; v < 1 || v > 3 {
+			t.Fatalf("random_int returned a number outside [1, 3]. Got: %d", v)
+		}
+	}
+	if v := call(h, "random_int", &Integer{Value: math.MinInt64}, &Integer{Value: math.MaxInt64}); v.Type() != IntegerObj {
+		t.Errorf("random_int over every int64 returned wrong result. Got: %s", v.Inspect())
+	}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"choice", []Object{&Array{}}, "null"},
+		{"shuffle", []Object{&Array{}}, "[]"},
+		{"random", []Object{NullValue}, "Error: Wrong number of arguments. Got: 1, Expected: 0"},
+		{"random_int", []Object{&Integer{Value: 2}, &Integer{Value: 1}}, "Error: First argument to `random_int` must not be greater than the second. Got: 2 and 1"},
+		{"seed", []Object{&String{Value: "a"}}, "Error: Argument to `seed` must be an Integer. Got: STRING"},
+	}
+
+	for _, tt := range tests {
+		res := call(h, tt.name, tt.args...)
+		if res == nil {
+			res = NullValue
+		}
+		if res.Inspect() != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res.Inspect())
+		}
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	encode := GetBuiltinByName("json_encode")
+	decode := GetBuiltinByName("json_decode")
+
+	value := decode.Fn(&String{Value: `{"b": [1, 2.5, 1e2, null, false, "hé"], "a": {"n": -3}}`})
+	if _, ok := value.(*Hash); !ok {
+		t.Fatalf("json_decode returned wrong result. Got: %s", value.Inspect())
+	}
+
+	encodeTests := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{value}, `{"a":{"n":-3},"b":[1,2.5,100,null,false,"hé"]}`},
+		{[]Object{value, &Integer{Value: 1}}, "{\n \"a\": {\n  \"n\": -3\n },\n \"b\": [\n  1,\n  2.5,\n  100,\n  null,\n  false,\n  \"hé\"\n ]\n}"},
+		{[]Object{&Array{}, &String{Value: "\t"}}, "[]"},
+		{[]Object{&String{Value: "<a & b>"}}, `"<a & b>"`},
+		{[]Object{&Float{Value: 2}}, "2"},
+		{[]Object{&Hash{Pairs: map[HashKey]HashPair{(&Integer{Value: 1}).HashKey(): {Key: &Integer{Value: 1}, Value: NullValue}}}}, "Error: Could not encode JSON: hash keys must be Strings. Got: INTEGER"},
+		{[]Object{&Float{Value: math.Inf(1)}}, "Error: Could not encode JSON: +Inf has no JSON representation"},
+		{[]Object{NewChannel(0)}, "Error: Could not encode JSON: CHANNEL has no JSON representation"},
+		{[]Object{NullValue, NullValue}, "Error: Second argument to `json_encode` must be an Integer or a String. Got: NULL"},
+		{[]Object{&Array{Elements: []Object{NullValue}}, &Integer{Value: 10}}, "[\n          null\n]"},
+		{[]Object{NullValue, &Integer{Value: math.MaxInt64}}, "Error: Second argument to `json_encode` must be at most 10. Got: 9223372036854775807"},
+	}
+
+	for _, tt := range encodeTests {
+		res := encode.Fn(tt.args...)
+		if res.Inspect() != tt.expected {
+			t.Errorf("json_encode returned wrong result. Expected: %s. Got: %s", tt.expected, res.Inspect())
+		}
+	}
+
+	decodeTests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, -2, 2.5, 99999999999999999999]`, "[1, -2, 2.5, 1e+20]"},
+		{` "x" `, "x"},
+		{`null`, "null"},
+		{`[1, 2,`, "Error: Invalid JSON at line 1, column 7: unexpected end of JSON input"},
+		{`1 2`, "Error: Invalid JSON at line 1, column 3: unexpected data after the top-level value"},
+		{"[1,\n  tru]", "Error: Invalid JSON at line 2, column 6: invalid character ']' in literal true (expecting 'e')"},
+		{``, "Error: Invalid JSON at line 1, column 1: unexpected end of JSON input"},
+	}
+
+	for _, tt := range decodeTests {
+		res := decode.Fn(&String{Value: tt.input})
+		if res.Inspect() != tt.expected {
+			t.Errorf("json_decode(%q) returned wrong result. Expected: %s. Got: %s", tt.input, tt.expected, res.Inspect())
+		}
+	}
+}
+
+func TestFileBuiltins(t *testing.T) {
+	data, outside := t.TempDir(), t.TempDir()
+	call := func(h Host, name string, args ...Object) Object {
+		res := GetBuiltinByName(name).Call(h, args...)
+		if res == nil {
+			return NullValue
+		}
+		return res
+	}
+	path := func(dir, name string) *String { return &String{Value: filepath.Join(dir, name)} }
+
+	// With no policy nothing can be read or written
+	denied := builtinHost{rt: NewRuntime()}
+	if res := call(denied, "exists", &String{Value: data}); res.Inspect() != "Error: `exists` is not allowed to read "+data {
+		t.Errorf("exists builtin should be denied without a policy. Got: %s", res.Inspect())
+	}
+
+	rt := NewRuntime()
+	rt.SetFilePolicy(FilePolicy{Read: []string{data}, Write: []string{data}})
+	h := builtinHost{rt: rt}
+
+	if err := os.Symlink(outside, filepath.Join(data, "link")); err != nil {
+		t.Fatalf("could not create symlink: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"write_file", []Object{path(data, "a.txt"), &String{Value: "hello"}}, "null"},
+		{"append_file", []Object{path(data, "a.txt"), &String{Value: " world"}}, "null"},
+		{"read_file", []Object{path(data, "a.txt")}, "hello world"},
+		{"exists", []Object{path(data, "a.txt")}, "true"},
+		{"exists", []Object{path(data, "b.txt")}, "false"},
+		{"list_dir", []Object{&String{Value: data}}, "[a.txt, link]"},
+		{"remove", []Object{path(data, "a.txt")}, "null"},
+		{"exists", []Object{path(data, "a.txt")}, "false"},
+		{"read_file", []Object{path(data, "a.txt")}, "Error: `read_file` failed: " + path(data, "a.txt").Value + ": no such file or directory"},
+		{"read_file", []Object{path(outside, "a.txt")}, "Error: `read_file` is not allowed to read " + path(outside, "a.txt").Value},
+		{"write_file", []Object{path(data, "link/a.txt"), &String{Value: "x"}}, "Error: `write_file` is not allowed to write " + path(data, "link/a.txt").Value},
+		{"read_file", []Object{path(data, "../"+filepath.Base(outside))}, "Error: `read_file` is not allowed to read " + path(data, "../"+filepath.Base(outside)).Value},
+		{"write_file", []Object{path(data, "a.txt"), &Integer{Value: 1}}, "Error: Second argument to `write_file` must be a String. Got: INTEGER"},
+		{"list_dir", []Object{&Integer{Value: 1}}, "Error: Argument to `list_dir` must be a String. Got: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		if res := call(h, tt.name, tt.args...); res.Inspect() != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res.Inspect())
+		}
+	}
+
+	readOnly := NewRuntime()
+	readOnly.SetFilePolicy(FilePolicy{Read: []string{data}})
+	if res := call(builtinHost{rt: readOnly}, "remove", path(data, "link")); res.Inspect() != "Error: `remove` is not allowed to write "+path(data, "link").Value {
+		t.Errorf("remove builtin should need write access. Got: %s", res.Inspect())
+	}
+}
+
+func TestProcessBuiltins(t *testing.T) {
+	t.Setenv("MONKEY_TEST_VAR", "banana")
+
+	rt := NewRuntime()
+	rt.SetStdin(strings.NewReader("one\r\ntwo\n\nfour"))
+	h := builtinHost{rt: rt}
+	call := func(name string, args ...Object) string {
+		res := GetBuiltinByName(name).Call(h, args...)
+		if res == nil {
+			return "null"
+		}
+		return res.Inspect()
+	}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"env", []Object{&String{Value: "MONKEY_TEST_VAR"}}, "banana"},
+		{"env", []Object{&String{Value: "MONKEY_TEST_UNSET"}}, "null"},
+		{"env", []Object{&Integer{Value: 1}}, "Error: Argument to `env` must be a String. Got: INTEGER"},
+		{"read_line", nil, "one"},
+		{"stdin_lines", nil, "[two, , four]"},
+		{"read_line", nil, "null"},
+		{"stdin_lines", nil, "[]"},
+		{"read_line", []Object{NullValue}, "Error: Wrong number of arguments. Got: 1, Expected: 0"},
+		{"exit", []Object{&String{Value: "1"}}, "Error: Argument to `exit` must be an Integer. Got: STRING"},
+		{"exit", []Object{&Integer{Value: 256}}, "Error: Argument to `exit` must be between 0 and 255. Got: 256"},
+		{"exit", []Object{&Integer{Value: -1}}, "Error: Argument to `exit` must be between 0 and 255. Got: -1"},
+	}
+
+	for _, tt := range tests {
+		if res := call(tt.name, tt.args...); res != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res)
+		}
+	}
+
+	exit := GetBuiltinByName("exit")
+	for _, tt := range []struct {
+		args     []Object
+		expected int
+	}{{nil, 0}, {[]Object{&Integer{Value: 3}}, 3}, {[]Object{&Integer{Value: 255}}, 255}} {
+		err, ok := exit.Fn(tt.args...).(*Error)
+		if !ok || !err.Exit || err.Code != tt.expected {
+			t.Errorf("exit builtin returned wrong result. Expected exit status %d. Got: %+v", tt.expected, err)
+		}
+	}
+}
+
+func TestSharedStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe returned an error: %s", err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	systemStdin.r = nil
+	defer func() {
+		os.Stdin = stdin
+		systemStdin.r = nil
+		r.Close()
+	}()
+
+	w.WriteString("one\ntwo\n")
+	w.Close()
+
+	// Runtimes that read the process's input don't lose what one buffered to the other
+	readLine := GetBuiltinByName("read_line")
+	for i, expected := range []string{"one", "two"} {
+		res := readLine.Call(builtinHost{rt: NewRuntime()}, nil...)
+		if res == nil || res.Inspect() != expected {
+			t.Errorf("read_line on Runtime %d returned wrong result. Expected: %s. Got: %v", i, expected, res)
+		}
+	}
+}
+
+func TestTimeBuiltins(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 9, 14, 30, 0, 0, time.UTC))
+	rt := NewRuntime()
+	rt.SetClock(clock)
+	h := builtinHost{rt: rt}
+	call := func(name string, args ...Object) string {
+		res := GetBuiltinByName(name).Call(h, args...)
+		if res == nil {
+			return "null"
+		}
+		return res.Inspect()
+	}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"now", nil, "1709994600000"},
+		{"clock", nil, "0.0"},
+		{"sleep", []Object{&Integer{Value: 1500}}, "null"},
+		{"now", nil, "1709994601500"},
+		{"clock", nil, "1500.0"},
+		{"format_time", []Object{&Integer{Value: 1709994601500}, &String{Value: "2006-01-02 15:04:05.000"}}, "2024-03-09 14:30:01.500"},
+		{"parse_time", []Object{&String{Value: "2024-03-09"}, &String{Value: "2006-01-02"}}, "1709942400000"},
+		{"parse_time", []Object{&String{Value: "2024-03-09T14:30:00+01:00"}, &String{Value: time.RFC3339}}, "1709991000000"},
+		{"parse_time", []Object{&String{Value: "March"}, &String{Value: "2006-01-02"}}, "Error: Could not parse time: parsing time \"March\" as \"2006-01-02\": cannot parse \"March\" as \"2006\""},
+		{"sleep", []Object{&Float{Value: 1}}, "Error: Argument to `sleep` must be an Integer. Got: FLOAT"},
+		{"format_time", []Object{&String{Value: "0"}, &String{Value: ""}}, "Error: First argument to `format_time` must be an Integer. Got: STRING"},
+		{"now", []Object{NullValue}, "Error: Wrong number of arguments. Got: 1, Expected: 0"},
+	}
+
+	for _, tt := range tests {
+		if res := call(tt.name, tt.args...); res != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res)
+		}
+	}
+
+	// The system clock is the default
+	before := time.Now().UnixMilli()
+	now := GetBuiltinByName("now").Call(builtinHost{rt: NewRuntime()}).(*Integer).Value
+	if now < before || now > time.Now().UnixMilli() {
+		t.Errorf("now builtin should use the system clock. Got: %d", now)
+	}
+}
+
+func TestRegexBuiltins(t *testing.T) {
+	h := builtinHost{}
+	call := func(name string, args ...Object) string {
+		res := GetBuiltinByName(name).Call(h, args...)
+		if res == nil {
+			return "null"
+		}
+		return res.Inspect()
+	}
+	str := func(s string) *String { return &String{Value: s} }
+
+	date := GetBuiltinByName("regex").Fn(str(`(?P<year>\d{4})-(?P<month>\d\d)(-(?P<day>\d\d))?`))
+	if date.Type() != RegexObj {
+		t.Fatalf("regex builtin returned wrong result. Got: %s", date.Inspect())
+	}
+	digits := &Regex{Value: regexp.MustCompile(`\d+`)}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"match", []Object{digits, str("ab12cd345")}, "12"},
+		{"match", []Object{digits, str("abc")}, "null"},
+		{"match", []Object{date, str("on 2024-03")}, "[2024-03, 2024, 03, null, null]"},
+		{"captures", []Object{date, str("never")}, "null"},
+		{"find_all", []Object{digits, str("ab12cd345")}, "[12, 345]"},
+		{"find_all", []Object{digits, str("abc")}, "[]"},
+		{"find_all", []Object{date, str("2024-03 and 2025-12-31")}, "[[2024-03, 2024, 03, null, null], [2025-12-31, 2025, 12, -31, 31]]"},
+		{"replace_all", []Object{date, str("2024-03-09"), str("${day}/$month/$year")}, "09/03/2024"},
+		{"replace_all", []Object{digits, str("a1b22"), GetBuiltinByName("len")}, "Error: Function passed to `replace_all` must return a String. Got: INTEGER"},
+		{"replace_all", []Object{digits, str("a1b22"), GetBuiltinByName("repeat")}, "Error: Wrong number of arguments. Got: 1, Expected: 2"},
+		{"replace_all", []Object{digits, str("a1b22c"), GetBuiltinByName("str")}, "a1b22c"},
+		{"replace_all", []Object{digits, str("x"), &Integer{Value: 1}}, "Error: Third argument to `replace_all` must be a String or a function. Got: INTEGER"},
+		{"regex", []Object{str("(")}, "Error: Invalid regular expression \"(\": error parsing regexp: missing closing ): `(`"},
+		{"match", []Object{str("a"), str("a")}, "Error: First argument to `match` must be a Regex. Got: STRING"},
+		{"find_all", []Object{digits, &Integer{Value: 1}}, "Error: Second argument to `find_all` must be a String. Got: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		if res := call(tt.name, tt.args...); res != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res)
+		}
+	}
+
+	captures := GetBuiltinByName("captures").Fn(date, str("on 2024-03-09"))
+	if res := call("items", captures); res != "[[day, 09], [month, 03], [year, 2024]]" {
+		t.Errorf("captures builtin returned wrong result. Got: %s", res)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	double := &Builtin{Fn: func(args ...Object) Object { return &Integer{Value: 2 * args[0].(*Integer).Value} }}
+	version := &String{Value: "1.0"}
+
+	if i := r.Register("double", double); i != 0 {
+		t.Errorf("Register returned wrong position. Expected: 0. Got: %d", i)
+	}
+	if i := r.Register("VERSION", version); i != 1 {
+		t.Errorf("Register returned wrong position. Expected: 1. Got: %d", i)
+	}
+	if double.Name != "double" {
+		t.Errorf("Register should name an unnamed builtin. Got: %q", double.Name)
+	}
+
+	// Registering a name again replaces its value in place
+	newVersion := &String{Value: "2.0"}
+	if i := r.Register("VERSION", newVersion); i != 1 {
+		t.Errorf("Register returned wrong position. Expected: 1. Got: %d", i)
+	}
+	if v, ok := r.Lookup("VERSION"); !ok || v != newVersion {
+		t.Errorf("Lookup returned wrong value. Got: %v, %v", v, ok)
+	}
+	if _, ok := r.Lookup("missing"); ok {
+		t.Errorf("Lookup should not find an unregistered name")
+	}
+	if r.Get(0) != double || r.Get(2) != nil || r.Get(-1) != nil {
+		t.Errorf("Get returned wrong values")
+	}
+
+	names := r.Names()
+	names[0] = "changed"
+	if fmt.Sprint(r.Names()) != "[double VERSION]" {
+		t.Errorf("Names returned wrong names. Got: %v", r.Names())
+	}
+
+	// Default registries hold the Builtins in order, and are independent of each other
+	first, second := DefaultRegistry(), DefaultRegistry()
+	for i, def := range Builtins {
+		if first.Get(i) != def.Builtin || first.Names()[i] != def.Name || def.Builtin.Name != def.Name {
+			t.Fatalf("DefaultRegistry has the wrong builtin at %d. Expected: %s. Got: %s", i, def.Name, first.Names()[i])
+		}
+	}
+	first.Register("double", double)
+	if _, ok := second.Lookup("double"); ok {
+		t.Errorf("Registering in one DefaultRegistry should not affect another")
+	}
+}
+
+func TestBuiltinParams(t *testing.T) {
+	echo := func(args ...Object) Object { return &Integer{Value: int64(len(args))} }
+	one := &Builtin{Name: "one", Fn: echo, Params: []ObjectType{IntegerObj}}
+	two := &Builtin{Name: "two", Fn: echo, Params: []ObjectType{AnyObj, FunctionObj}}
+	many := &Builtin{Name: "many", Fn: echo, Params: []ObjectType{StringObj, ArrayObj}, Variadic: true}
+	none := &Builtin{Name: "none", Fn: echo, Params: []ObjectType{}}
+	arr := &Array{}
+
+	tests := []struct {
+		builtin  *Builtin
+		args     []Object
+		expected string
+	}{
+		{one, []Object{&Integer{Value: 1}}, "1"},
+		{one, []Object{}, "Error: Wrong number of arguments. Got: 0, Expected: 1"},
+		{one, []Object{arr}, "Error: Argument to `one` must be an Integer. Got: ARRAY"},
+		{two, []Object{NullValue, GetBuiltinByName("len")}, "2"},
+		{two, []Object{NullValue, &Closure{}}, "2"},
+		{two, []Object{NullValue, arr}, "Error: Second argument to `two` must be a Function. Got: ARRAY"},
+		{many, []Object{&String{}}, "1"},
+		{many, []Object{&String{}, arr, arr, arr}, "4"},
+		{many, []Object{}, "Error: Wrong number of arguments. Got: 0, Expected: at least 1"},
+		{many, []Object{&String{}, arr, arr, &Hash{}}, "Error: Argument 4 to `many` must be an Array. Got: HASH"},
+		{none, []Object{arr}, "Error: Wrong number of arguments. Got: 1, Expected: 0"},
+	}
+
+	for _, tt := range tests {
+		if res := tt.builtin.Call(builtinHost{}, tt.args...); res.Inspect() != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.builtin.Name, tt.expected, res.Inspect())
+		}
+	}
+}
+
+type convertConfig struct {
+	Name    string         `monkey:"name"`
+	Port    uint16         `monkey:"port"`
+	Tags    []string       `monkey:"tags"`
+	Limits  map[string]int `monkey:"limits"`
+	Ratio   float64
+	Debug   *bool  `monkey:"debug"`
+	Secret  string `monkey:"-"`
+	private int
+}
+
+// convertNode can point back at itself
+type convertNode struct {
+	Next *convertNode
+}
+
+func TestFromGo(t *testing.T) {
+	debug := true
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{-3.5, "-3.5"},
+		{"hi", "hi"},
+		{[]byte("bytes"), "bytes"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]any{1, "a", nil}, "[1, a, null]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{map[int][]string{1: {"x"}}, "{1: [x]}"},
+		{(*int)(nil), "null"},
+		{[]int(nil), "null"},
+		{&debug, "true"},
+		{[]*bool{&debug, &debug}, "[true, true]"},
+		{&Integer{Value: 9}, "9"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v) returned an error: %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) returned wrong object. Expected: %s. Got: %s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	obj, err := FromGo(convertConfig{Name: "srv", Port: 80, Tags: []string{"a"}, Debug: &debug, Secret: "x", private: 1})
+	if err != nil {
+		t.Fatalf("FromGo returned an error: %s", err)
+	}
+	hash := obj.(*Hash)
+	if len(hash.Pairs) != 6 {
+		t.Errorf("Struct converted to wrong number of pairs. Expected: 6. Got: %d", len(hash.Pairs))
+	}
+	expected := map[string]string{"name": "srv", "port": "80", "tags": "[a]", "limits": "null", "Ratio": "0.0", "debug": "true"}
+	for key, value := range expected {
+		pair, ok := hash.Pairs[(&String{Value: key}).HashKey()]
+		if !ok || pair.Value.Inspect() != value {
+			t.Errorf("Struct field %s converted wrong. Expected: %s. Got: %v", key, value, pair.Value)
+		}
+	}
+
+	errorTests := []struct {
+		input    any
+		expected string
+	}{
+		{uint64(math.MaxUint64), "cannot convert 18446744073709551615 to an Integer: it is too large"},
+		{map[float64]int{1: 1}, "cannot convert map[float64]int to a Hash: a Float can't be a hash key"},
+		{make(chan int), "cannot convert chan int to a Monkey value"},
+		{func() (int, int) { return 0, 0 }, "cannot convert func() (int, int) to a Builtin: it must return at most a value and an error"},
+		{cyclicNode(), "cannot convert *object.convertNode: it contains itself"},
+		{cyclicSlice(), "cannot convert []interface {}: it contains itself"},
+		{cyclicMap(), "cannot convert map[string]interface {}: it contains itself"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := FromGo(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromGo(%T) returned wrong error. Expected: %q. Got: %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func cyclicNode() *convertNode {
+	n := &convertNode{}
+	n.Next = n
+	return n
+}
+
+func cyclicSlice() []any {
+	s := []any{nil}
+	s[0] = s
+	return s
+}
+
+func cyclicMap() map[string]any {
+	m := map[string]any{}
+	m["m"] = m
+	return m
+}
+
+func TestToGo(t *testing.T) {
+	var i int
+	if err := ToGo(&Integer{Value: 5}, &i); err != nil || i != 5 {
+		t.Errorf("ToGo to int failed. Got: %d, %v", i, err)
+	}
+	var f float32
+	if err := ToGo(&Integer{Value: 2}, &f); err != nil || f != 2 {
+		t.Errorf("ToGo to float32 failed. Got: %v, %v", f, err)
+	}
+	var b []byte
+	if err := ToGo(&String{Value: "abc"}, &b); err != nil || string(b) != "abc" {
+		t.Errorf("ToGo to []byte failed. Got: %q, %v", b, err)
+	}
+	arr := &Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}
+	var ints []int64
+	if err := ToGo(arr, &ints); err != nil || fmt.Sprint(ints) != "[1 2]" {
+		t.Errorf("ToGo to []int64 failed. Got: %v, %v", ints, err)
+	}
+	var pair [2]int
+	if err := ToGo(arr, &pair); err != nil || pair != [2]int{1, 2} {
+		t.Errorf("ToGo to [2]int failed. Got: %v, %v", pair, err)
+	}
+	var obj Object
+	if err := ToGo(arr, &obj); err != nil || obj != arr {
+		t.Errorf("ToGo to Object should assign the object itself. Got: %v, %v", obj, err)
+	}
+
+	hash, err := FromGo(map[string]any{
+		"name":   "srv",
+		"port":   8080,
+		"tags":   []string{"a", "b"},
+		"limits": map[string]int{"cpu": 2},
+		"Ratio":  1,
+		"debug":  false,
+		"Secret": "leaked",
+		"extra":  "ignored",
+	})
+	if err != nil {
+		t.Fatalf("FromGo returned an error: %s", err)
+	}
+	var cfg convertConfig
+	if err := ToGo(hash, &cfg); err != nil {
+		t.Fatalf("ToGo to a struct returned an error: %s", err)
+	}
+	if cfg.Name != "srv" || cfg.Port != 8080 || fmt.Sprint(cfg.Tags) != "[a b]" || cfg.Limits["cpu"] != 2 ||
+		cfg.Ratio != 1 || cfg.Debug == nil || *cfg.Debug || cfg.Secret != "" {
+		t.Errorf("ToGo to a struct set wrong fields. Got: %+v", cfg)
+	}
+
+	var generic any
+	if err := ToGo(hash, &generic); err != nil {
+		t.Fatalf("ToGo to any returned an error: %s", err)
+	}
+	m, ok := generic.(map[string]any)
+	if !ok || m["port"] != int64(8080) || fmt.Sprint(m["tags"]) != "[a b]" || fmt.Sprint(m["limits"]) != "map[cpu:2]" {
+		t.Errorf("ToGo to any returned wrong value. Got: %#v", generic)
+	}
+	mixed := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Hashable{&Integer{Value: 1}, &String{Value: "a"}} {
+		mixed.Pairs[key.HashKey()] = HashPair{Key: key.(Object), Value: NullValue}
+	}
+	if err := ToGo(mixed, &generic); err != nil || len(generic.(map[any]any)) != 2 {
+		t.Errorf("ToGo to any with mixed keys returned wrong value. Got: %#v, %v", generic, err)
+	}
+
+	ptr := &cfg
+	if err := ToGo(NullValue, &ptr); err != nil || ptr != nil {
+		t.Errorf("ToGo of null should set a pointer to nil. Got: %v, %v", ptr, err)
+	}
+	i = 5
+	if err := ToGo(nil, &i); err != nil || i != 0 {
+		t.Errorf("ToGo of nil should convert like null. Got: %d, %v", i, err)
+	}
+	if err := ToGo(nil, &generic); err != nil || generic != nil {
+		t.Errorf("ToGo of nil to any should set nil. Got: %#v, %v", generic, err)
+	}
+
+	var small int8
+	var u uint
+	var s string
+	errorTests := []struct {
+		obj      Object
+		target   any
+		expected string
+	}{
+		{&Integer{Value: 300}, &small, "cannot convert 300 to int8: it is out of range"},
+		{&Integer{Value: -1}, &u, "cannot convert -1 to uint: it is out of range"},
+		{&Integer{Value: 1}, &s, "cannot convert an Integer to string"},
+		{arr, &[3]int{}, "cannot convert an Array of 2 elements to [3]int"},
+		{&Hash{Pairs: map[HashKey]HashPair{(&String{Value: "port"}).HashKey(): {Key: &String{Value: "port"}, Value: &String{Value: "x"}}}}, &cfg, "field port: cannot convert a String to uint16"},
+		{&Integer{Value: 1}, i, "cannot convert to int: target must be a non-nil pointer"},
+	}
+
+	for _, tt := range errorTests {
+		err := ToGo(tt.obj, tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ToGo(%s, %T) returned wrong error. Expected: %q. Got: %v", tt.obj.Inspect(), tt.target, tt.expected, err)
+		}
+	}
+}
+
+func TestFromGoFunc(t *testing.T) {
+	h := builtinHost{rt: NewRuntime()}
+	convert := func(fn any) *Builtin {
+		obj, err := FromGo(fn)
+		if err != nil {
+			t.Fatalf("FromGo(%T) returned an error: %s", fn, err)
+		}
+		return obj.(*Builtin)
+	}
+
+	add := convert(func(a, b int) int { return a + b })
+	if res := add.Call(h, &Integer{Value: 2}, &Integer{Value: 3}); res.Inspect() != "5" {
+		t.Errorf("Wrapped func returned wrong result. Got: %s", res.Inspect())
+	}
+	if res := add.Call(h, &Integer{Value: 2}); res.Inspect() != "Error: Wrong number of arguments. Got: 1, Expected: 2" {
+		t.Errorf("Wrapped func returned wrong error. Got: %s", res.Inspect())
+	}
+	if res := add.Call(h, &Integer{Value: 2}, &String{Value: "3"}); res.Inspect() != "Error: Argument 2: cannot convert a String to int" {
+		t.Errorf("Wrapped func returned wrong error. Got: %s", res.Inspect())
+	}
+
+	join := convert(func(sep string, parts ...string) string { return strings.Join(parts, sep) })
+	if res := join.Call(h, &String{Value: "-"}, &String{Value: "a"}, &String{Value: "b"}); res.Inspect() != "a-b" {
+		t.Errorf("Wrapped variadic func returned wrong result. Got: %s", res.Inspect())
+	}
+	if res := join.Call(h); res.Inspect() != "Error: Wrong number of arguments. Got: 0, Expected: at least 1" {
+		t.Errorf("Wrapped variadic func returned wrong error. Got: %s", res.Inspect())
+	}
+
+	parse := convert(func(s string) (int, error) { return strconv.Atoi(s) })
+	if res := parse.Call(h, &String{Value: "12"}); res.Inspect() != "12" {
+		t.Errorf("Wrapped func returned wrong result. Got: %s", res.Inspect())
+	}
+	if res, ok := parse.Call(h, &String{Value: "x"}).(*Error); !ok || !strings.Contains(res.Message, "invalid syntax") {
+		t.Errorf("Wrapped func should return its error as an *Error. Got: %v", res)
+	}
+
+	var called bool
+	noop := convert(func() { called = true })
+	if res := noop.Call(h); res != nil || !called {
+		t.Errorf("Wrapped func with no results should return nil. Got: %v", res)
+	}
+
+	panics := convert(func() int { panic("boom") })
+	if res := panics.Call(h); res.Inspect() != "Error: Go function panicked: boom" {
+		t.Errorf("Wrapped func that panics should return an *Error. Got: %v", res)
+	}
+
+	apply := convert(func(h Host, fn Object, x int) Object { return h.Call(fn, &Integer{Value: int64(x)}) })
+	double := convert(func(x int) int { return x * 2 })
+	if res := apply.Call(h, double, &Integer{Value: 4}); res.Inspect() != "8" {
+		t.Errorf("Wrapped func taking a Host returned wrong result. Got: %s", res.Inspect())
+	}
+}
+
+func TestRuntimeLimits(t *testing.T) {
+	rt := NewRuntime()
+	if err := rt.Step(1 << 40); err != nil || rt.Stopped() != nil {
+		t.Errorf("A Runtime without limits should not stop. Got: %v", err)
+	}
+
+	rt.SetLimits(Limits{Steps: 10})
+	if err := rt.Step(10); err != nil {
+		t.Errorf("Step within the limit returned an error: %s", err)
+	}
+	if rt.Stopped() != nil {
+		t.Errorf("Stopped should be nil within the limit")
+	}
+	if err := rt.Step(1); err != ErrStepLimit {
+		t.Errorf("Step past the limit returned wrong error. Expected: %s. Got: %v", ErrStepLimit, err)
+	}
+	if err := rt.Step(0); err != ErrStepLimit || rt.Stopped() != ErrStepLimit {
+		t.Errorf("A stopped Runtime should keep reporting ErrStepLimit. Got: %v", err)
+	}
+
+	// Setting the limits again starts counting afresh
+	rt.SetLimits(Limits{Steps: 10})
+	if err := rt.Step(5); err != nil {
+		t.Errorf("Step after SetLimits returned an error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rt.SetContext(ctx)
+	cancel()
+	if err := rt.Step(1); err != context.Canceled || rt.Stopped() != context.Canceled {
+		t.Errorf("Step after the context was cancelled returned wrong error. Got: %v", err)
+	}
+}
+
+func TestMemoryLimits(t *testing.T) {
+	sizes := []struct {
+		obj      Object
+		expected int64
+	}{
+		{&String{Value: "abc"}, StringSize(3)},
+		{&Array{Elements: []Object{NullValue, NullValue}}, ArraySize(2)},
+		{&Hash{Pairs: map[HashKey]HashPair{}}, HashSize(0)},
+		{&Integer{Value: 1}, 0},
+		{nil, 0},
+	}
+	for _, tt := range sizes {
+		if size := Size(tt.obj); size != tt.expected {
+			t.Errorf("Size(%v) returned wrong size. Expected: %d. Got: %d", tt.obj, tt.expected, size)
+		}
+	}
+	if StringSize(10) <= StringSize(0) || ArraySize(10) <= ArraySize(1) || HashSize(10) <= HashSize(1) {
+		t.Errorf("Sizes should grow with their contents")
+	}
+
+	rt := NewRuntime()
+	if err := rt.Allocate(1 << 40); err != nil {
+		t.Errorf("A Runtime without limits should not run out of memory. Got: %s", err)
+	}
+
+	rt.SetLimits(Limits{Memory: 100})
+	if err := rt.CheckAllocation(101); err != ErrMemoryLimit || rt.Stopped() != ErrMemoryLimit {
+		t.Errorf("A failed CheckAllocation should stop the script. Got: %v", err)
+	}
+
+	rt.SetLimits(Limits{Memory: 100})
+	if err := rt.CheckAllocation(100); err != nil || rt.Stopped() != nil {
+		t.Errorf("CheckAllocation within the limit returned an error: %v", err)
+	}
+	if err := rt.Allocate(100); err != nil {
+		t.Errorf("Allocate within the limit returned an error: %s", err)
+	}
+	if err := rt.Allocate(1); err != ErrMemoryLimit {
+		t.Errorf("Allocate past the limit returned wrong error. Expected: %s. Got: %v", ErrMemoryLimit, err)
+	}
+	if err := rt.Step(0); err != ErrMemoryLimit {
+		t.Errorf("Step should stop a script that ran out of memory. Got: %v", err)
+	}
+
+	// Builtins that build values as big as their arguments ask for check before building them
+	tests := []struct {
+		name string
+		args []Object
+	}{
+		{"range", []Object{&Integer{Value: math.MaxInt64}}},
+		{"range", []Object{&Integer{Value: math.MinInt64}, &Integer{Value: math.MaxInt64}}},
+		{"repeat", []Object{&String{Value: "ab"}, &Integer{Value: 1 << 20}}},
+		{"pad_left", []Object{&String{Value: "ab"}, &Integer{Value: 1 << 21}}},
+	}
+	for _, tt := range tests {
+		rt := NewRuntime()
+		rt.SetLimits(Limits{Memory: 1 << 20})
+		res := GetBuiltinByName(tt.name).Call(builtinHost{rt: rt}, tt.args...)
+		if res.Inspect() != "Error: memory limit exceeded" {
+			t.Errorf("%s builtin returned wrong result. Expected: Error: memory limit exceeded. Got: %s", tt.name, res.Inspect())
+		}
+	}
+	rt.SetLimits(Limits{Memory: 1 << 20})
+	if res := GetBuiltinByName("range").Call(builtinHost{rt: rt}, &Integer{Value: 3}); res.Inspect() != "[0, 1, 2]" {
+		t.Errorf("range builtin returned wrong result. Got: %s", res.Inspect())
+	}
+
+	// Builtins count everything they build, including the values inside their results, but not
+	// arguments they hand back
+	ab := &Regex{Value: regexp.MustCompile("(a)(b)")}
+	built := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"split", []Object{&String{Value: "a,b"}, &String{Value: ","}}, "Error: memory limit exceeded"},
+		{"json_decode", []Object{&String{Value: `[["abcdefgh"]]`}}, "Error: memory limit exceeded"},
+		{"find_all", []Object{ab, &String{Value: "abab"}}, "Error: memory limit exceeded"},
+		{"items", []Object{&Hash{Pairs: map[HashKey]HashPair{
+			(&Integer{Value: 1}).HashKey(): {Key: &Integer{Value: 1}, Value: NullValue},
+		}}}, "Error: memory limit exceeded"},
+		{"str", []Object{&String{Value: strings.Repeat("x", 200)}}, strings.Repeat("x", 200)},
+		{"first", []Object{&Array{Elements: []Object{&String{Value: strings.Repeat("x", 200)}}}}, strings.Repeat("x", 200)},
+	}
+	for _, tt := range built {
+		rt := NewRuntime()
+		rt.SetLimits(Limits{Memory: 100})
+		res := GetBuiltinByName(tt.name).Call(builtinHost{rt: rt}, tt.args...)
+		if res.Inspect() != tt.expected {
+			t.Errorf("%s builtin returned wrong result. Expected: %s. Got: %s", tt.name, tt.expected, res.Inspect())
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	// Every builtin a profile leaves out exists, so renaming one can't quietly open a sandbox
+	for _, groups := range profileDenies {
+		for _, group := range groups {
+			for _, name := range group {
+				if GetBuiltinByName(name) == nil {
+					t.Errorf("Sandbox profiles name %q, which isn't a builtin", name)
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		sandbox Sandbox
+		allowed []string
+		denied  []string
+	}{
+		{Sandbox{}, []string{"len", "print", "read_file", "exit"}, nil},
+		{Sandbox{Profile: Full}, []string{"len", "print", "read_file", "exit"}, nil},
+		{Sandbox{Profile: Standard}, []string{"len", "print", "now", "random"}, []string{"read_file", "read_line", "exit", "env"}},
+		{Sandbox{Profile: Pure}, []string{"len", "map", "sprintf", "format_time", "json_encode"}, []string{"print", "printf", "now", "random", "read_file", "exit"}},
+		{Sandbox{Profile: Pure, Allow: []string{"print"}, Deny: []string{"len"}}, []string{"print", "map"}, []string{"len", "printf"}},
+		{Sandbox{Allow: []string{"exit"}, Deny: []string{"exit"}}, []string{"print"}, []string{"exit"}},
+	}
+
+	for _, tt := range tests {
+		r, err := tt.sandbox.Registry()
+		if err != nil {
+			t.Fatalf("Registry for %+v returned an error: %s", tt.sandbox, err)
+		}
+		for _, name := range tt.allowed {
+			if v, ok := r.Lookup(name); !tt.sandbox.Allows(name) || !ok || v != GetBuiltinByName(name) {
+				t.Errorf("Sandbox %+v should allow %s", tt.sandbox, name)
+			}
+		}
+		for _, name := range tt.denied {
+			if _, ok := r.Lookup(name); tt.sandbox.Allows(name) || ok {
+				t.Errorf("Sandbox %+v should not allow %s", tt.sandbox, name)
+			}
+		}
+	}
+
+	errorTests := []struct {
+		sandbox  Sandbox
+		expected string
+	}{
+		{Sandbox{Profile: "strict"}, `unknown sandbox profile "strict". Expected: "pure", "standard" or "full"`},
+		{Sandbox{Allow: []string{"prnt"}}, `unknown builtin "prnt" in sandbox`},
+		{Sandbox{Deny: []string{"len", "readfile"}}, `unknown builtin "readfile" in sandbox`},
+	}
+
+	for _, tt := range errorTests {
+		if _, err := tt.sandbox.Registry(); err == nil || err.Error() != tt.expected {
+			t.Errorf("Registry for %+v returned wrong error. Expected: %q. Got: %v", tt.sandbox, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package object
+
+import "github.com/bradford-hamilton/monkey-lang/ast"
+
+// Quote wraps the unevaluated ast.Node passed to quote(). Macros receive their arguments as
+// Quotes and must return one, which is spliced into the program in place of the macro call
+type Quote struct {
+	Node ast.Node
+}
+
+// Type returns our Quote's ObjectType (QuoteObj)
+func (q *Quote) Type() ObjectType { return QuoteObj }
+
+// Inspect returns a string representation of the quoted node: QUOTE(<node>)
+func (q *Quote) Inspect() string { return "QUOTE(" + q.Node.String() + ")" }
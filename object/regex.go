@@ -0,0 +1,14 @@
+package object
+
+import "regexp"
+
+// Regex type holds a compiled regular expression, from a re"..." literal or the regex builtin
+type Regex struct {
+	Value *regexp.Regexp
+}
+
+// Type returns our Regex's ObjectType (RegexObj)
+func (r *Regex) Type() ObjectType { return RegexObj }
+
+// Inspect returns the Regex the way it would be written as a literal
+func (r *Regex) Inspect() string { return "re\"" + r.Value.String() + "\"" }
@@ -0,0 +1,67 @@
+package object
+
+// Registry holds the builtins an interpreter's scripts can use by name: builtin functions, and any
+// other values a host wants every script to see. The compiler refers to them by their position in
+// the registry, so entries are only ever added or replaced, never removed or reordered. A registry
+// is read by running code without locking, so it must not change while code runs
+type Registry struct {
+	names  []string
+	values []Object
+	index  map[string]int
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{index: make(map[string]int)}
+}
+
+// DefaultRegistry creates a Registry holding the standard Builtins, at the positions they have in
+// Builtins. Each call returns a new Registry, so adding to one doesn't affect any other
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, def := range Builtins {
+		r.Register(def.Name, def.Builtin)
+	}
+	return r
+}
+
+// Register makes value available to scripts as name, replacing what was registered as name before,
+// and returns its position. A *Builtin without a Name takes the name it's registered as
+func (r *Registry) Register(name string, value Object) int {
+	if b, ok := value.(*Builtin); ok && b.Name == "" {
+		b.Name = name
+	}
+
+	if i, ok := r.index[name]; ok {
+		r.values[i] = value
+		return i
+	}
+
+	r.index[name] = len(r.values)
+	r.names = append(r.names, name)
+	r.values = append(r.values, value)
+
+	return len(r.values) - 1
+}
+
+// Lookup returns the value registered as name
+func (r *Registry) Lookup(name string) (Object, bool) {
+	i, ok := r.index[name]
+	if !ok {
+		return nil, false
+	}
+	return r.values[i], true
+}
+
+// Get returns the value at position i, or nil if there isn't one
+func (r *Registry) Get(i int) Object {
+	if i < 0 || i >= len(r.values) {
+		return nil
+	}
+	return r.values[i]
+}
+
+// Names returns the registered names in the order of their positions
+func (r *Registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
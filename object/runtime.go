@@ -0,0 +1,289 @@
+package object
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Runtime holds the state builtins keep for one interpreter: one VM (and the VMs it starts for
+// generators and spawned calls) or one tree of evaluator environments. Builtins get at it through
+// their Host. Spawned code shares its interpreter's Runtime, so its state is guarded
+type Runtime struct {
+	mu    sync.Mutex
+	rand  *rand.Rand
+	files FilePolicy
+	clock Clock
+	start time.Time // When clock() started counting from
+
+	// Only changed before code runs, see Registry, so it isn't guarded
+	builtins *Registry
+
+	// What stops scripts early, see Step. Code spawned by an earlier run can still be reading it
+	// when a host starts the next one, so it is replaced whole rather than changed
+	exec atomic.Pointer[execution]
+
+	// Reading a line can block for a long time, so stdin has a lock of its own. It is nil until
+	// a host sets one, when the Runtime reads the process's standard input, see systemStdin
+	stdinMu sync.Mutex
+	stdin   *bufio.Reader
+
+	// Output is locked separately too, so that lines printed by spawned code don't interleave
+	outMu sync.Mutex
+	out   io.Writer
+
+	// Guards the VM's globals once spawned code shares them, see GlobalsLock
+	globalsMu     sync.RWMutex
+	globalsShared atomic.Bool
+
+	// The evaluator's generators that haven't finished, see Generators, and what stops them once
+	// the Runtime is closed
+	generators sync.Map
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewRuntime creates a Runtime on the system clock whose random number generator is seeded from
+// the clock
+func NewRuntime() *Runtime {
+	now := time.Now()
+	rt := &Runtime{
+		rand:  rand.New(rand.NewSource(now.UnixNano())),
+		clock: SystemClock{},
+		start: now,
+		out:   os.Stdout,
+
+		builtins: DefaultRegistry(),
+		closed:   make(chan struct{}),
+	}
+	rt.exec.Store(&execution{ctx: context.Background()})
+	return rt
+}
+
+// Seed reseeds the Runtime's random number generator, making the numbers it produces afterwards
+// the same on every run
+func (rt *Runtime) Seed(seed int64) {
+	rt.mu.Lock()
+	rt.rand = rand.New(rand.NewSource(seed))
+	rt.mu.Unlock()
+}
+
+// Rand calls fn with the Runtime's random number generator, which only one caller may use at a time
+func (rt *Runtime) Rand(fn func(r *rand.Rand)) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	fn(rt.rand)
+}
+
+// SetFilePolicy sets which files the Runtime's file builtins may use. Until it is called they
+// may use none
+func (rt *Runtime) SetFilePolicy(policy FilePolicy) {
+	rt.mu.Lock()
+	rt.files = policy
+	rt.mu.Unlock()
+}
+
+// FilePolicy returns the policy the Runtime's file builtins follow
+func (rt *Runtime) FilePolicy() FilePolicy {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.files
+}
+
+// SetClock sets where the Runtime's time builtins get the time from, and restarts clock()
+func (rt *Runtime) SetClock(clock Clock) {
+	rt.mu.Lock()
+	rt.clock = clock
+	rt.start = clock.Now()
+	rt.mu.Unlock()
+}
+
+// Clock returns the Runtime's clock and the time clock() counts from
+func (rt *Runtime) Clock() (Clock, time.Time) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.clock, rt.start
+}
+
+// Builtins returns the Registry of the builtins the Runtime's scripts can use, which starts out
+// as the DefaultRegistry. Anything added to it must be added before code that uses it is compiled
+// or run
+func (rt *Runtime) Builtins() *Registry {
+	return rt.builtins
+}
+
+// SetBuiltins replaces the Registry of builtins the Runtime's scripts can use. Bytecode must be
+// compiled with the same Registry as the VM that runs it
+func (rt *Runtime) SetBuiltins(builtins *Registry) {
+	rt.builtins = builtins
+}
+
+// SetStdin sets where the Runtime's input builtins read from (os.Stdin by default)
+func (rt *Runtime) SetStdin(r io.Reader) {
+	rt.stdinMu.Lock()
+	rt.stdin = bufio.NewReader(r)
+	rt.stdinMu.Unlock()
+}
+
+// Stdin calls fn with the Runtime's input, which only one caller may read at a time
+func (rt *Runtime) Stdin(fn func(r *bufio.Reader)) {
+	rt.stdinMu.Lock()
+	defer rt.stdinMu.Unlock()
+	if rt.stdin != nil {
+		fn(rt.stdin)
+		return
+	}
+
+	systemStdin.mu.Lock()
+	defer systemStdin.mu.Unlock()
+	if systemStdin.r == nil {
+		systemStdin.r = bufio.NewReader(os.Stdin)
+	}
+	fn(systemStdin.r)
+}
+
+// systemStdin is the reader every Runtime without an input of its own shares. Each reader buffers
+// input ahead of what it has returned, so Runtimes with readers of their own would lose lines to
+// each other. It is only created once a script reads, leaving stdin alone for hosts that read it
+// themselves
+var systemStdin struct {
+	mu sync.Mutex
+	r  *bufio.Reader
+}
+
+// SetOutput sets where the Runtime's output builtins write to (os.Stdout by default)
+func (rt *Runtime) SetOutput(w io.Writer) {
+	rt.outMu.Lock()
+	rt.out = w
+	rt.outMu.Unlock()
+}
+
+// Output calls fn with the Runtime's output, which only one caller may write to at a time
+func (rt *Runtime) Output(fn func(w io.Writer)) {
+	rt.outMu.Lock()
+	defer rt.outMu.Unlock()
+	fn(rt.out)
+}
+
+// ShareGlobals records that code running on another goroutine, such as a spawned call, is about
+// to use the VM's globals too. From then on GlobalsLock returns the lock that guards them
+func (rt *Runtime) ShareGlobals() {
+	rt.globalsShared.Store(true)
+}
+
+// GlobalsLock returns the lock VMs hold while they read and write their globals, or nil while
+// only one goroutine uses them, so a program that never spawns doesn't pay for locking. (The
+// evaluator's Environments always lock, see Environment)
+func (rt *Runtime) GlobalsLock() *sync.RWMutex {
+	if rt.globalsShared.Load() {
+		return &rt.globalsMu
+	}
+	return nil
+}
+
+// Generators returns where the evaluator keeps the generators its environments are running, keyed
+// by the environment each generator's body runs in, so that a yield can find its generator
+func (rt *Runtime) Generators() *sync.Map {
+	return &rt.generators
+}
+
+// Close stops what scripts left running in the background once they are done with the Runtime,
+// such as generators that were never resumed to the end. Code shouldn't be run on it afterwards
+func (rt *Runtime) Close() {
+	rt.closeOnce.Do(func() { close(rt.closed) })
+}
+
+// Closed returns a channel that is closed once Close is called
+func (rt *Runtime) Closed() <-chan struct{} {
+	return rt.closed
+}
+
+// execution is a context and limits, and what the scripts they apply to have used up so far
+type execution struct {
+	ctx    context.Context
+	limits Limits
+	steps  atomic.Int64 // Steps taken since the limits were set
+	memory atomic.Int64 // Bytes allocated since the limits were set
+}
+
+// SetContext makes the Runtime's scripts stop with ctx's error once ctx is cancelled or its
+// deadline passes. Code still running from an earlier run, such as a spawned call, stops with
+// it too
+func (rt *Runtime) SetContext(ctx context.Context) {
+	old := rt.exec.Load()
+	exec := &execution{ctx: ctx, limits: old.limits}
+	exec.steps.Store(old.steps.Load())
+	exec.memory.Store(old.memory.Load())
+	rt.exec.Store(exec)
+}
+
+// Context returns the context the Runtime's scripts stop with (context.Background() by default)
+func (rt *Runtime) Context() context.Context {
+	return rt.exec.Load().ctx
+}
+
+// SetLimits sets how much work the Runtime's scripts may do, and starts counting their steps
+// and memory from zero again. Code still running from an earlier run, such as a spawned call,
+// counts against the new limits from then on
+func (rt *Runtime) SetLimits(limits Limits) {
+	rt.exec.Store(&execution{ctx: rt.exec.Load().ctx, limits: limits})
+}
+
+// Limits returns how much work the Runtime's scripts may do
+func (rt *Runtime) Limits() Limits {
+	return rt.exec.Load().limits
+}
+
+// Step counts n more steps taken by a script, and reports whether it should stop: with
+// ErrStepLimit once it has taken too many, ErrMemoryLimit once it has allocated too much, or with
+// the error of the Runtime's context once that is done. Engines call it as they run, and keep
+// getting the error once they have been told to stop
+func (rt *Runtime) Step(n int64) error {
+	exec := rt.exec.Load()
+	exec.steps.Add(n)
+	return exec.stopped()
+}
+
+// Allocate counts n more bytes of Arrays, Hashes and Strings created by a script, see Size, and
+// returns ErrMemoryLimit once it has created more than the limit allows
+func (rt *Runtime) Allocate(n int64) error {
+	exec := rt.exec.Load()
+	if memory := exec.memory.Add(n); exec.limits.Memory > 0 && memory > exec.limits.Memory {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// CheckAllocation returns ErrMemoryLimit if allocating n more bytes would go over the limit,
+// without counting them. Builtins that build a value whose size their arguments decide use it to
+// fail before building it, and count the value once they have built it. A failed
+// check counts, so the script stops as though it had allocated the bytes
+func (rt *Runtime) CheckAllocation(n int64) error {
+	exec := rt.exec.Load()
+	if exec.limits.Memory > 0 && exec.memory.Load()+n > exec.limits.Memory {
+		exec.memory.Add(n)
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// Stopped returns the error Step last stopped a script with, or nil if it hasn't stopped one.
+// Hosts use it to tell a script that was stopped from one that failed
+func (rt *Runtime) Stopped() error {
+	return rt.exec.Load().stopped()
+}
+
+func (e *execution) stopped() error {
+	if e.limits.Steps > 0 && e.steps.Load() > e.limits.Steps {
+		return ErrStepLimit
+	}
+	if e.limits.Memory > 0 && e.memory.Load() > e.limits.Memory {
+		return ErrMemoryLimit
+	}
+	return e.ctx.Err()
+}
@@ -0,0 +1,99 @@
+package object
+
+import "fmt"
+
+// Profile names a set of the standard Builtins, for running scripts that shouldn't be able to do
+// everything a script run from the command line can
+type Profile string
+
+const (
+	// Pure builtins only compute with their arguments. Scripts can't write output, read input,
+	// use files, the process, the time or random numbers, so a script given the same arguments
+	// always does the same thing
+	Pure Profile = "pure"
+	// Standard adds output, time and random numbers to Pure, leaving out input, files and the
+	// process: what a script needs to run as part of a service
+	Standard Profile = "standard"
+	// Full is every builtin, which is what scripts get unless they are sandboxed
+	Full Profile = "full"
+)
+
+// The builtins that reach outside of the script, by what they reach
+var (
+	outputBuiltins  = []string{"print", "write", "printf"}
+	timeBuiltins    = []string{"now", "clock", "sleep"}
+	randomBuiltins  = []string{"random", "random_int", "choice", "shuffle", "seed"}
+	inputBuiltins   = []string{"read_line", "stdin_lines"}
+	fileBuiltins    = []string{"read_file", "write_file", "append_file", "list_dir", "exists", "remove"}
+	processBuiltins = []string{"env", "exit"}
+)
+
+// profileDenies lists the builtins each profile leaves out
+var profileDenies = map[Profile][][]string{
+	Pure:     {outputBuiltins, timeBuiltins, randomBuiltins, inputBuiltins, fileBuiltins, processBuiltins},
+	Standard: {inputBuiltins, fileBuiltins, processBuiltins},
+	Full:     {},
+}
+
+// Sandbox picks which of the standard Builtins scripts may use: those in Profile (Full if it is
+// empty), plus those in Allow, less those in Deny. Scripts can't refer to the builtins it leaves
+// out at all, so using one is a compile error on the VM, "undefined variable", and an identifier
+// that isn't found in the evaluator, and the name is free for scripts to bind. Modules such as
+// math only compute, so every sandbox has them
+type Sandbox struct {
+	Profile Profile
+	Allow   []string
+	Deny    []string
+}
+
+// Allows reports whether the sandbox lets scripts use the standard builtin name
+func (s Sandbox) Allows(name string) bool {
+	for _, denied := range s.Deny {
+		if denied == name {
+			return false
+		}
+	}
+	for _, allowed := range s.Allow {
+		if allowed == name {
+			return true
+		}
+	}
+	for _, group := range profileDenies[s.profile()] {
+		for _, denied := range group {
+			if denied == name {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Registry creates a Registry holding the standard Builtins the sandbox allows, in the order they
+// have in Builtins. Hosts can register functions of their own in it as usual. It fails if the
+// profile is unknown or Allow or Deny names something that isn't a builtin, which is most likely
+// a typo that would otherwise leave the sandbox more open than intended
+func (s Sandbox) Registry() (*Registry, error) {
+	if _, ok := profileDenies[s.profile()]; !ok {
+		return nil, fmt.Errorf("unknown sandbox profile %q. Expected: %q, %q or %q", s.Profile, Pure, Standard, Full)
+	}
+	for _, name := range append(append([]string(nil), s.Allow...), s.Deny...) {
+		if GetBuiltinByName(name) == nil {
+			return nil, fmt.Errorf("unknown builtin %q in sandbox", name)
+		}
+	}
+
+	r := NewRegistry()
+	for _, def := range Builtins {
+		if s.Allows(def.Name) {
+			r.Register(def.Name, def.Builtin)
+		}
+	}
+	return r, nil
+}
+
+func (s Sandbox) profile() Profile {
+	if s.Profile == "" {
+		return Full
+	}
+	return s.Profile
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 
 	"github.com/bradford-hamilton/monkey-lang/ast"
@@ -43,6 +44,7 @@ var precedences = map[token.Type]int{
 	token.Or:           Logical,
 	token.LeftParen:    Call,
 	token.LeftBracket:  Index,
+	token.Dot:          Index,
 }
 
 type (
@@ -64,6 +66,9 @@ type Parser struct {
 	prefixParseFuncs  map[token.Type]prefixParseFunc
 	infixParseFuncs   map[token.Type]infixParseFunc
 	postfixParseFuncs map[token.Type]postfixParseFunc
+
+	funcDepth int  // how many function literals we're currently nested in
+	sawYield  bool // whether the innermost function literal contains a yield
 }
 
 // New takes a Lexer, creates a Parser with that Lexer, sets the current and
@@ -80,6 +85,8 @@ func New(l *lexer.Lexer) *Parser {
 	// Register all of our prefix parse funcs
 	p.registerPrefix(token.Identifier, p.parseIdentifier)
 	p.registerPrefix(token.Integer, p.parseIntegerLiteral)
+	p.registerPrefix(token.Float, p.parseFloatLiteral)
+	p.registerPrefix(token.Regex, p.parseRegexLiteral)
 	p.registerPrefix(token.Bang, p.parsePrefixExpression)
 	p.registerPrefix(token.Minus, p.parsePrefixExpression)
 	p.registerPrefix(token.True, p.parseBoolean)
@@ -90,6 +97,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.String, p.parseStringLiteral)
 	p.registerPrefix(token.LeftBracket, p.parseArrayLiteral)
 	p.registerPrefix(token.LeftBrace, p.parseHashLiteral)
+	p.registerPrefix(token.Spawn, p.parseSpawnExpression)
+	p.registerPrefix(token.Macro, p.parseMacroLiteral)
 
 	// Register all of our infix parse funcs
 	p.registerInfix(token.Plus, p.parseInfixExpression)
@@ -105,6 +114,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GreaterEqual, p.parseInfixExpression)
 	p.registerInfix(token.LeftParen, p.parseCallExpression)
 	p.registerInfix(token.LeftBracket, p.parseIndexExpr)
+	p.registerInfix(token.Dot, p.parseDotExpr)
 	p.registerInfix(token.And, p.parseInfixExpression)
 	p.registerInfix(token.Or, p.parseInfixExpression)
 
@@ -133,6 +143,10 @@ func (p *Parser) ParseProgram() *ast.RootNode {
 		p.nextToken()
 	}
 
+	if len(p.errors) == 0 {
+		ast.MarkTailCalls(rootNode)
+	}
+
 	return rootNode
 }
 
@@ -144,6 +158,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseConstStatement()
 	case token.Return:
 		return p.parseReturnStatement()
+	case token.Enum:
+		return p.parseEnumStatement()
+	case token.Yield:
+		return p.parseYieldStatement()
 	default:
 		return p.parseExprStatement()
 	}
@@ -276,6 +294,63 @@ func (p *Parser) parseConstStatement() *ast.ConstStatement {
 	return stmt
 }
 
+func (p *Parser) parseEnumStatement() *ast.EnumStatement {
+	stmt := &ast.EnumStatement{Token: p.currentToken}
+
+	if !p.expectPeekType(token.Identifier) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{
+		Token: p.currentToken,
+		Value: p.currentToken.Literal,
+	}
+
+	if !p.expectPeekType(token.LeftBrace) {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+
+	for !p.peekTokenTypeIs(token.RightBrace) {
+		if !p.expectPeekType(token.Identifier) {
+			return nil
+		}
+
+		if seen[p.currentToken.Literal] {
+			msg := fmt.Sprintf("Line %d: Duplicate variant %s in enum %s", p.currentToken.Line, p.currentToken.Literal, stmt.Name.Value)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		seen[p.currentToken.Literal] = true
+
+		stmt.Variants = append(stmt.Variants, &ast.Identifier{
+			Token: p.currentToken,
+			Value: p.currentToken.Literal,
+		})
+
+		if !p.peekTokenTypeIs(token.RightBrace) && !p.expectPeekType(token.Comma) {
+			return nil
+		}
+	}
+
+	if !p.expectPeekType(token.RightBrace) {
+		return nil
+	}
+
+	if len(stmt.Variants) == 0 {
+		msg := fmt.Sprintf("Line %d: Enum %s must declare at least one variant", stmt.Token.Line, stmt.Name.Value)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	if p.peekTokenTypeIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.currentToken}
 
@@ -290,6 +365,27 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseYieldStatement() *ast.YieldStatement {
+	stmt := &ast.YieldStatement{Token: p.currentToken}
+
+	if p.funcDepth == 0 {
+		msg := fmt.Sprintf("Line %d: yield outside of function", p.currentToken.Line)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	p.sawYield = true
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpr(Lowest)
+
+	if p.peekTokenTypeIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExprStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.currentToken}
 	stmt.Expression = p.parseExpr(Lowest)
@@ -337,6 +433,38 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.currentToken}
+
+	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("Line %d: Could not parse %q as float", p.currentToken.Line, p.currentToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+// Regular expressions are compiled as they're parsed, so a bad pattern is a parse error and
+// neither engine compiles the same literal twice
+func (p *Parser) parseRegexLiteral() ast.Expression {
+	lit := &ast.RegexLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	regex, err := regexp.Compile(lit.Value)
+	if err != nil {
+		msg := fmt.Sprintf("Line %d: Invalid regular expression %q: %s", p.currentToken.Line, lit.Value, err)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit.Regex = regex
+
+	return lit
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{
 		Token: p.currentToken,
@@ -453,7 +581,16 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	// A yield makes the innermost enclosing function a generator, so track it per function literal
+	outerSawYield := p.sawYield
+	p.sawYield = false
+	p.funcDepth++
+
 	lit.Body = p.parseBlockStatement()
+	lit.IsGenerator = p.sawYield
+
+	p.funcDepth--
+	p.sawYield = outerSawYield
 
 	return lit
 }
@@ -515,6 +652,22 @@ func (p *Parser) parseIndexExpr(left ast.Expression) ast.Expression {
 	return expr
 }
 
+// parseDotExpr parses member access (Color.Red) into an index expression with a string index
+func (p *Parser) parseDotExpr(left ast.Expression) ast.Expression {
+	expr := &ast.IndexExpression{Token: p.currentToken, Left: left}
+
+	if !p.expectPeekType(token.Identifier) {
+		return nil
+	}
+
+	expr.Index = &ast.StringLiteral{
+		Token: p.currentToken,
+		Value: p.currentToken.Literal,
+	}
+
+	return expr
+}
+
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
 
@@ -549,6 +702,40 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	return expr
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.currentToken}
+
+	if !p.expectPeekType(token.LeftParen) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeekType(token.LeftBrace) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	expr := &ast.SpawnExpression{Token: p.currentToken}
+
+	p.nextToken()
+
+	call, ok := p.parseExpr(Prefix).(*ast.CallExpression)
+	if !ok {
+		msg := fmt.Sprintf("Line %d: spawn expects a function call", expr.Token.Line)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	expr.Call = call
+
+	return expr
+}
+
 func (p *Parser) parseExprList(end token.Type) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -169,6 +169,61 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.5;", "2.5"},
+		{"-0.25;", "(-0.25)"},
+		{"1 + 2.0 * 3;", "(1 + (2.0 * 3))"},
+		{"math.PI * 2.0;", "((math.PI) * 2.0)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected: %q. Got: %q", tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("2.5")).ParseProgram()
+	literal, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("Expr not an *ast.FloatLiteral. Got: %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if literal.Value != 2.5 {
+		t.Errorf("literal.Value not %v. Got: %v", 2.5, literal.Value)
+	}
+}
+
+func TestRegexLiteralExpression(t *testing.T) {
+	program := New(lexer.New(`match(re"[a-z]+\d", x);`)).ParseProgram()
+	if program.String() != `match(re"[a-z]+\d", x)` {
+		t.Errorf("Expected: %q. Got: %q", `match(re"[a-z]+\d", x)`, program.String())
+	}
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	literal, ok := call.Arguments[0].(*ast.RegexLiteral)
+	if !ok {
+		t.Fatalf("Expr not an *ast.RegexLiteral. Got: %T", call.Arguments[0])
+	}
+	if literal.Value != `[a-z]+\d` || !literal.Regex.MatchString("ab1") {
+		t.Errorf("literal not compiled from %q. Got: %q", `[a-z]+\d`, literal.Value)
+	}
+
+	p := New(lexer.New(`re"(a"`))
+	p.ParseProgram()
+	expected := "Line 0: Invalid regular expression \"(a\": error parsing regexp: missing closing ): `(a`"
+	if len(p.Errors()) != 1 || p.Errors()[0] != expected {
+		t.Errorf("Expected parser error %q. Got: %v", expected, p.Errors())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -875,6 +930,139 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestEnumStatements(t *testing.T) {
+	input := `enum Color { Red, Green, Blue, };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. Got: %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.EnumStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.EnumStatement. Got: %T", program.Statements[0])
+	}
+
+	if stmt.Name.Value != "Color" {
+		t.Fatalf("stmt.Name.Value not 'Color'. Got: %s", stmt.Name.Value)
+	}
+
+	expected := []string{"Red", "Green", "Blue"}
+	if len(stmt.Variants) != len(expected) {
+		t.Fatalf("Wrong number of variants. Expected: %d. Got: %d", len(expected), len(stmt.Variants))
+	}
+
+	for i, name := range expected {
+		testIdentifier(t, stmt.Variants[i], name)
+	}
+}
+
+func TestEnumStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Color { Red, Red }", "Line 0: Duplicate variant Red in enum Color"},
+		{"enum Color { }", "Line 0: Enum Color must declare at least one variant"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("Expected parser errors for %q. Got none", tt.input)
+		}
+
+		if p.Errors()[0] != tt.expected {
+			t.Errorf("Wrong parser error. Expected: %q. Got: %q", tt.expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestParsingDotExpressions(t *testing.T) {
+	input := "Color.Red"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. Got: %T", program.Statements[0])
+	}
+
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("Expr not an *ast.IndexExpression. Got: %T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "Color") {
+		return
+	}
+
+	member, ok := indexExp.Index.(*ast.StringLiteral)
+	if !ok || member.Value != "Red" {
+		t.Fatalf("indexExp.Index is not a StringLiteral 'Red'. Got: %T (%+v)", indexExp.Index, indexExp.Index)
+	}
+
+	if indexExp.String() != "(Color.Red)" {
+		t.Errorf("Wrong String representation. Expected: '(Color.Red)'. Got: %s", indexExp.String())
+	}
+}
+
+func TestYieldStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		outerGenerator bool
+		innerGenerator bool
+	}{
+		{"func() { yield 1; func() { 2 } }", true, false},
+		{"func() { func() { yield 2; } }", false, true},
+		{"func() { if (true) { yield 1; } func() { yield 2; } }", true, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		outer, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. Got: %T", stmt.Expression)
+		}
+
+		if outer.IsGenerator != tt.outerGenerator {
+			t.Errorf("outer.IsGenerator wrong for %q. Expected: %t. Got: %t", tt.input, tt.outerGenerator, outer.IsGenerator)
+		}
+
+		lastStmt := outer.Body.Statements[len(outer.Body.Statements)-1].(*ast.ExpressionStatement)
+		inner, ok := lastStmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("last statement is not ast.FunctionLiteral. Got: %T", lastStmt.Expression)
+		}
+
+		if inner.IsGenerator != tt.innerGenerator {
+			t.Errorf("inner.IsGenerator wrong for %q. Expected: %t. Got: %t", tt.input, tt.innerGenerator, inner.IsGenerator)
+		}
+	}
+
+	l := lexer.New("yield 1;")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 || p.Errors()[0] != "Line 0: yield outside of function" {
+		t.Errorf("Expected yield outside of function error. Got: %q", p.Errors())
+	}
+}
+
 func TestFunctionLiteralWithName(t *testing.T) {
 	input := `let myFunction = func() { };`
 
@@ -1066,3 +1254,119 @@ func testConstStatement(t *testing.T, stmt ast.Statement, name string) bool {
 
 	return true
 }
+
+func TestSpawnExpressions(t *testing.T) {
+	l := lexer.New("spawn work(1, x);")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	spawn, ok := stmt.Expression.(*ast.SpawnExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.SpawnExpression. Got: %T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, spawn.Call.Function, "work") {
+		return
+	}
+
+	if len(spawn.Call.Arguments) != 2 {
+		t.Fatalf("Wrong number of arguments. Expected: 2. Got: %d", len(spawn.Call.Arguments))
+	}
+
+	l = lexer.New("spawn 5;")
+	p = New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 || p.Errors()[0] != "Line 0: spawn expects a function call" {
+		t.Errorf("Expected spawn expects a function call error. Got: %q", p.Errors())
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. Got: %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. Got: %T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. Got: %T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. Expected: 2. Got: %d", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements does not have 1 statement. Got: %d", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. Got: %T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestTailCallMarking(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[string]bool
+	}{
+		{`func() { f(1) }`, map[string]bool{"f(1)": true}},
+		{`func() { return f(1); }`, map[string]bool{"f(1)": true}},
+		{`func() { f(1); g(2) }`, map[string]bool{"f(1)": false, "g(2)": true}},
+		{`func() { 1 + f(1) }`, map[string]bool{"f(1)": false}},
+		{`func() { f(g(1)) }`, map[string]bool{"f(g(1))": true, "g(1)": false}},
+		{`func() { if (c) { f(1) } else { g(2) } }`, map[string]bool{"f(1)": true, "g(2)": true}},
+		{`func() { if (c) { return f(1); } g(2); 3 }`, map[string]bool{"f(1)": true, "g(2)": false}},
+		{`func() { let x = f(1); x }`, map[string]bool{"f(1)": false}},
+		{`func() { spawn f(1) }`, map[string]bool{"f(1)": false}},
+		{`f(1)`, map[string]bool{"f(1)": false}},
+		{`func() { func() { f(1) }; g(2) }`, map[string]bool{"f(1)": true, "g(2)": true}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		found := map[string]bool{}
+		if _, err := ast.Modify(program, func(node ast.Node) ast.Node {
+			if call, ok := node.(*ast.CallExpression); ok {
+				found[call.String()] = call.IsTailCall
+			}
+			return node
+		}); err != nil {
+			t.Fatalf("Modify returned an error: %s", err)
+		}
+
+		for call, expected := range tt.expected {
+			isTailCall, ok := found[call]
+			if !ok {
+				t.Fatalf("Call %s not found in %q", call, tt.input)
+			}
+			if isTailCall != expected {
+				t.Errorf("Wrong IsTailCall for %s in %q. Expected: %t. Got: %t", call, tt.input, expected, isTailCall)
+			}
+		}
+	}
+}
@@ -2,16 +2,12 @@ package repl
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 
-	"github.com/bradford-hamilton/monkey-lang/ast"
-	"github.com/bradford-hamilton/monkey-lang/compiler"
-	"github.com/bradford-hamilton/monkey-lang/evaluator"
-	"github.com/bradford-hamilton/monkey-lang/lexer"
-	"github.com/bradford-hamilton/monkey-lang/object"
-	"github.com/bradford-hamilton/monkey-lang/parser"
-	"github.com/bradford-hamilton/monkey-lang/vm"
+	"github.com/bradford-hamilton/monkey-lang/monkey"
 )
 
 // Oops ...need I explain?
@@ -24,84 +20,42 @@ o888   888o   ooooooo  ooooooooo    oooooooo8 8888
                        o888
 `
 
-// Start - starts REPL, passes stdin to lexer line by line
-func Start(in io.Reader, out io.Writer, engine *string) {
+// Start - starts REPL, passes stdin to the interpreter line by line, so that the REPL runs code
+// with the engine, builtins and limits it was configured with. It returns the exit status for
+// the process: the one given to `exit`, or 0 once the input runs out
+func Start(in io.Reader, out io.Writer, interpreter *monkey.Interpreter) int {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalsSize)
-	symbolTable := compiler.NewSymbolTable()
-
-	for i, v := range object.Builtins {
-		symbolTable.DefineBuiltin(i, v.Name)
-	}
+	interpreter.Runtime().SetOutput(out)
 
 	for {
-		fmt.Printf(">> ")
+		fmt.Fprint(out, ">> ")
 
 		scanned := scanner.Scan()
 		if !scanned {
-			return
-		}
-
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
+			return 0
 		}
 
-		if *engine == "eval" {
-			evaluate(program, env, out)
-		} else if *engine == "vm" {
-			if err := compileAndExecute(symbolTable, constants, program, globals, out); err != nil {
-				fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
-				continue
-			}
+		result, err := interpreter.Eval(context.Background(), scanner.Text())
+
+		var parseErr *monkey.ParseError
+		var runtimeErr *monkey.RuntimeError
+		var exit *monkey.ExitError
+		switch {
+		case errors.As(err, &exit):
+			return exit.Code
+		case errors.As(err, &parseErr):
+			printParserErrors(out, parseErr.Messages)
+		case errors.As(err, &runtimeErr):
+			fmt.Fprintf(out, "Woops! Executing the code failed:\n %s\n", runtimeErr.Message)
+		case err != nil:
+			fmt.Fprintf(out, "Woops! %s\n", err)
+		default:
+			io.WriteString(out, result.Inspect())
+			io.WriteString(out, "\n")
 		}
 	}
 }
 
-func evaluate(program *ast.RootNode, env *object.Environment, out io.Writer) {
-	evaluated := evaluator.Eval(program, env)
-	if evaluated != nil {
-		io.WriteString(out, evaluated.Inspect())
-		io.WriteString(out, "\n")
-	}
-}
-
-func compileAndExecute(
-	symbolTable *compiler.SymbolTable,
-	constants []object.Object,
-	program *ast.RootNode,
-	globals []object.Object,
-	out io.Writer,
-) error {
-	comp := compiler.NewWithState(symbolTable, constants)
-	err := comp.Compile(program)
-	if err != nil {
-		return err
-	}
-
-	code := comp.Bytecode()
-	constants = code.Constants
-
-	machine := vm.NewWithGlobalsState(code, globals)
-	err = machine.Run()
-	if err != nil {
-		fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
-	}
-
-	lastPopped := machine.LastPoppedStackElement()
-	io.WriteString(out, lastPopped.Inspect())
-	io.WriteString(out, "\n")
-
-	return nil
-}
-
 func printParserErrors(out io.Writer, errors []string) {
 	io.WriteString(out, Oops)
 	io.WriteString(out, "Oops! We ran into some monkey business here!\n")
@@ -8,7 +8,9 @@ const (
 	// Identifiers & literals
 	Identifier = "IDENTIFIER" // add, foobar, x, y, ...
 	Integer    = "INTEGER"
+	Float      = "FLOAT"
 	String     = "STRING"
+	Regex      = "REGEX" // re"[a-z]+"
 
 	// Operators
 	Equal        = "="
@@ -32,6 +34,7 @@ const (
 	// Delimiters
 	Comma        = ","
 	Colon        = ":"
+	Dot          = "."
 	Semicolon    = ";"
 	LeftParen    = "("
 	RightParen   = ")"
@@ -49,6 +52,10 @@ const (
 	If       = "IF"
 	Else     = "ELSE"
 	Return   = "RETURN"
+	Enum     = "ENUM"
+	Yield    = "YIELD"
+	Spawn    = "SPAWN"
+	Macro    = "MACRO"
 )
 
 // Type is a type alias for a string
@@ -70,6 +77,10 @@ var keywords = map[string]Type{
 	"if":     If,
 	"else":   Else,
 	"return": Return,
+	"enum":   Enum,
+	"yield":  Yield,
+	"spawn":  Spawn,
+	"macro":  Macro,
 }
 
 // LookupIdentifier checks our keywords map for the scanned keyword. If it finds one, then
@@ -0,0 +1,60 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/bradford-hamilton/monkey-lang/object"
+)
+
+// executeSpawn pops the callee and its arguments and runs the call on a new goroutine, pushing
+// a channel that receives the result once it finishes. A spawned closure runs on a VM of its own
+// that shares our globals, the same as spawned code in the evaluator shares its environment, so
+// each side sees the other's global assignments; from the first spawn on they are guarded by the
+// Runtime's GlobalsLock. Values are shared too, which is safe because they are never mutated
+// after they are created. Channels are how spawned code hands results back
+func (vm *VM) executeSpawn(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := object.NewChannel(1)
+
+	vm.runtime.ShareGlobals()
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		if numArgs != callee.Fn.NumParameters {
+			return fmt.Errorf("wrong number of arguments. Expected: %d. Got: %d", callee.Fn.NumParameters, numArgs)
+		}
+		child, err := vm.newChildVM(callee, args, vm.globals)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			if err := child.Run(); err != nil {
+				result.Send(errorObject(err))
+				return
+			}
+			result.Send(child.stack[child.sp-1])
+		}()
+
+	case *object.Builtin:
+		// Builtins that call back into closures need a VM that isn't ours to run them on
+		host := vm.newHostVM(vm.globals)
+		go func() {
+			val := callee.Call(host, args...)
+			if val == nil {
+				val = Null
+			}
+			result.Send(val)
+		}()
+
+	default:
+		return fmt.Errorf("spawning non-function and non-builtin")
+	}
+
+	vm.sp = vm.sp - numArgs - 1
+
+	return vm.push(result)
+}
@@ -0,0 +1,49 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxTraceFrames is how many of the innermost calls a StackOverflowError's Trace keeps
+const maxTraceFrames = 10
+
+// StackOverflowError is returned by Run when a program needs more stack or more frames than the
+// VM's limits allow. Trace names the functions that were running, innermost first, and is cut
+// off after maxTraceFrames entries; Depth is the number of calls that were in progress
+type StackOverflowError struct {
+	Trace []string
+	Depth int
+}
+
+// Error returns "stack overflow" followed by the call trace, one function per line
+func (e *StackOverflowError) Error() string {
+	var out strings.Builder
+
+	out.WriteString("stack overflow")
+	for _, name := range e.Trace {
+		out.WriteString("\n\tat ")
+		out.WriteString(name)
+	}
+	if e.Depth > len(e.Trace) {
+		out.WriteString(fmt.Sprintf("\n\t... %d more", e.Depth-len(e.Trace)))
+	}
+
+	return out.String()
+}
+
+func (vm *VM) stackOverflow() *StackOverflowError {
+	// frames[0] is the main program (or a child VM's empty frame) rather than a call
+	depth := vm.framesIndex - 1
+	trace := []string{}
+
+	for i := vm.framesIndex - 1; i > 0 && len(trace) < maxTraceFrames; i-- {
+		name := vm.frames[i].closure.Fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		trace = append(trace, name)
+	}
+
+	return &StackOverflowError{Trace: trace, Depth: depth}
+}
@@ -2,18 +2,32 @@ package vm
 
 import (
 	"fmt"
+	"io"
+	"math"
 
 	"github.com/bradford-hamilton/monkey-lang/code"
 	"github.com/bradford-hamilton/monkey-lang/compiler"
 	"github.com/bradford-hamilton/monkey-lang/object"
 )
 
-// StackSize is an integer defining the size of our stack
+// StackSize is the default limit on the number of elements our stack can grow to. Use
+// WithMaxStackSize to change it
 const StackSize = 2048
 
-// MaxFrames defines the maximum frames allowed in the VM
+// MaxFrames is the default limit on the number of frames (nested calls) allowed in the VM. Use
+// WithMaxFrames to change it
 const MaxFrames = 1024
 
+// The stack and frames start out this size and double whenever they run out of room, up to their limits
+const (
+	initialStackSize = 128
+	initialFrames    = 16
+)
+
+// stepBatch is how many instructions the VM runs between reports to its Runtime's Step, which is
+// where it finds out whether it has been cancelled or has run out of steps
+const stepBatch = 1024
+
 // GlobalsSize - The upper limit on the number of global bindings our VM supports
 const GlobalsSize = 65536
 
@@ -21,54 +35,149 @@ const GlobalsSize = 65536
 // need one. Makes comparison easier as well because they always point to same place in memory
 
 // True - Pointer to a Monkey object.Boolean with value true
-var True = &object.Boolean{Value: true}
+var True = object.TrueValue
 
 // False - Pointer to a Monkey object.Boolean of value false
-var False = &object.Boolean{Value: false}
+var False = object.FalseValue
 
 // Null - Pointer to a Monkey object.Null
-var Null = &object.Null{}
+var Null = object.NullValue
+
+// ExitError is the error Run returns when the script calls `exit`. Code is the exit status it asked for
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// errorObject turns an error from running Monkey code into an error object, for the builtins
+// that hand errors back to scripts as values. An ExitError stays an exit
+func errorObject(err error) *object.Error {
+	if exit, ok := err.(*ExitError); ok {
+		return &object.Error{Message: err.Error(), Exit: true, Code: exit.Code}
+	}
+	return &object.Error{Message: err.Error()}
+}
 
 // VM defines our Virtual Machine. It holds our constant pool, instructions, a stack, and an integer (index)
 // that points to the next free slot in the stack
 type VM struct {
-	constants     []object.Object
-	stack         []object.Object
-	sp            int // Stack pointer: always points to the next free slot in the stack. Top of stack is stack[ip-1]
-	globals       []object.Object
-	frames        []*Frame
-	framesIndex   int
-	maxFramesUsed int // maximum stack tops used
+	constants   []object.Object
+	stack       []object.Object
+	sp          int // Stack pointer: always points to the next free slot in the stack. Top of stack is stack[ip-1]
+	globals     []object.Object
+	frames      []*Frame // Frames past framesIndex are left in place to be reused by later calls
+	framesIndex int
+
+	maxStackSize int
+	maxFrames    int
+
+	// State kept for builtins, shared with the VMs this one starts for generators and spawned calls
+	runtime *object.Runtime
+	output  io.Writer // Given to the runtime by New, see WithOutput
+
+	// Set when a generator's VM stops at an OpYield rather than by running to completion
+	suspended bool
+	yielded   object.Object
+
+	// Set when a closure called back from a builtin fails, see Call
+	callErr error
+
+	// Instructions run since the last report to the runtime's Step
+	steps int
+}
+
+// Option configures a VM created by New
+type Option func(*VM)
+
+// WithMaxStackSize sets the number of elements the VM's stack may grow to (StackSize by default).
+// It must be at least 1; smaller sizes are ignored
+func WithMaxStackSize(size int) Option {
+	return func(vm *VM) {
+		if size >= 1 {
+			vm.maxStackSize = size
+		}
+	}
+}
+
+// WithMaxFrames sets the number of nested calls the VM allows (MaxFrames by default). It must be
+// at least 1, the frame the program itself runs in; smaller numbers are ignored
+func WithMaxFrames(frames int) Option {
+	return func(vm *VM) {
+		if frames >= 1 {
+			vm.maxFrames = frames
+		}
+	}
+}
+
+// WithRuntime makes the VM keep its builtins' state in rt rather than in a Runtime of its own.
+// The REPL uses it to keep that state, such as a seeded random number generator, between lines
+func WithRuntime(rt *object.Runtime) Option {
+	return func(vm *VM) { vm.runtime = rt }
+}
+
+// WithOutput makes print, write and printf write to w rather than os.Stdout. It sets the output of
+// the VM's Runtime, so it also applies to a Runtime passed with WithRuntime
+func WithOutput(w io.Writer) Option {
+	return func(vm *VM) { vm.output = w }
 }
 
 // New initializers and returns a pointer to a VM. It takes bytecode and sets the bytecode's instructions
-// and constants to the VM, creates a new stack with StackSize number of elements, and initializes the ip to 0
-func New(bytecode *compiler.Bytecode) *VM {
+// and constants to the VM, creates a new stack that grows on demand, and initializes the ip to 0
+func New(bytecode *compiler.Bytecode, opts ...Option) *VM {
 	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
-	frames := make([]*Frame, MaxFrames)
-	frames[0] = mainFrame
-
-	return &VM{
-		constants:   bytecode.Constants,
-		stack:       make([]object.Object, StackSize),
-		sp:          0,
-		globals:     make([]object.Object, GlobalsSize),
-		frames:      frames,
-		framesIndex: 1,
+	vm := &VM{
+		constants:    bytecode.Constants,
+		sp:           0,
+		globals:      make([]object.Object, GlobalsSize),
+		framesIndex:  1,
+		maxStackSize: StackSize,
+		maxFrames:    MaxFrames,
+	}
+	for _, opt := range opts {
+		opt(vm)
 	}
+	if vm.runtime == nil {
+		vm.runtime = object.NewRuntime()
+	}
+	if vm.output != nil {
+		vm.runtime.SetOutput(vm.output)
+	}
+
+	vm.stack = make([]object.Object, min(initialStackSize, vm.maxStackSize))
+	vm.frames = make([]*Frame, 1, min(initialFrames, vm.maxFrames))
+	vm.frames[0] = mainFrame
+
+	return vm
 }
 
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.framesIndex-1]
 }
 
-func (vm *VM) pushFrame(f *Frame) {
-	vm.frames[vm.framesIndex] = f
+// pushFrame makes a frame for cl the current frame, reusing one left behind by an earlier call
+// when there is one
+func (vm *VM) pushFrame(cl *object.Closure, basePointer int) error {
+	if vm.framesIndex >= vm.maxFrames {
+		return vm.stackOverflow()
+	}
+
+	if vm.framesIndex < len(vm.frames) {
+		frame := vm.frames[vm.framesIndex]
+		frame.closure = cl
+		frame.ip = -1
+		frame.basePointer = basePointer
+	} else {
+		vm.frames = append(vm.frames, NewFrame(cl, basePointer))
+	}
 	vm.framesIndex++
-	vm.maxFramesUsed++
+
+	return nil
 }
 
 func (vm *VM) popFrame() *Frame {
@@ -76,19 +185,64 @@ func (vm *VM) popFrame() *Frame {
 	return vm.frames[vm.framesIndex]
 }
 
+// growStack makes sure the stack has room for size elements, doubling it as many times as needed
+// without going over the VM's limit
+func (vm *VM) growStack(size int) error {
+	if size <= len(vm.stack) {
+		return nil
+	}
+	if size > vm.maxStackSize {
+		return vm.stackOverflow()
+	}
+
+	newSize := len(vm.stack) * 2
+	for newSize < size {
+		newSize *= 2
+	}
+
+	stack := make([]object.Object, min(newSize, vm.maxStackSize))
+	copy(stack, vm.stack[:vm.sp])
+	vm.stack = stack
+
+	return nil
+}
+
 // LastPoppedStackElement returns last popped element on the top of the stack. We do not explicitly
 // set them to nil or remove them when calling pop so it will point to last popped.
 func (vm *VM) LastPoppedStackElement() object.Object {
+	// After a stack overflow the stack can be full, with nothing popped yet
+	if vm.sp >= len(vm.stack) {
+		return Null
+	}
 	return vm.stack[vm.sp]
 }
 
-// Run runs our VM and starts the fetch-decode-execute cycle
+// Run runs our VM and starts the fetch-decode-execute cycle. It stops early with the error of
+// the runtime's Step: object.ErrStepLimit, or the error of the runtime's context
 func (vm *VM) Run() error {
+	if err := vm.runtime.Step(0); err != nil {
+		return err
+	}
+	return vm.run(0)
+}
+
+// run executes instructions until the frame at stopDepth returns (or the program ends), which
+// lets Call run a closure to completion from inside a builtin. Every stepBatch instructions it
+// checks with the runtime that it may go on
+func (vm *VM) run(stopDepth int) error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
 
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+	for vm.framesIndex > stopDepth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.steps++
+		if vm.steps == stepBatch {
+			vm.steps = 0
+			if err := vm.runtime.Step(stepBatch); err != nil {
+				return err
+			}
+		}
+
 		vm.currentFrame().ip++
 
 		ip = vm.currentFrame().ip
@@ -96,9 +250,8 @@ func (vm *VM) Run() error {
 		op = code.Opcode(ins[ip])
 
 		switch op {
-		case code.OpConstant:
-			constIndex := code.ReadUint16(ins[ip+1:])
-			vm.currentFrame().ip += 2
+		case code.OpConstant, code.OpConstantWide:
+			constIndex := vm.readOperand(ins, ip, op)
 			err := vm.push(vm.constants[constIndex])
 			if err != nil {
 				return err
@@ -176,13 +329,13 @@ func (vm *VM) Run() error {
 		case code.OpSetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
-			vm.globals[globalIndex] = vm.pop()
+			vm.setGlobal(globalIndex, vm.pop())
 
 		case code.OpGetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 
-			err := vm.push(vm.globals[globalIndex])
+			err := vm.push(vm.getGlobal(globalIndex))
 			if err != nil {
 				return err
 			}
@@ -191,10 +344,13 @@ func (vm *VM) Run() error {
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
 
-			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			array, err := vm.buildArray(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
 			vm.sp = vm.sp - numElements
 
-			err := vm.push(array)
+			err = vm.push(array)
 			if err != nil {
 				return err
 			}
@@ -223,22 +379,32 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpCall:
-			numArgs := code.ReadUint8(ins[ip+1:])
-			vm.currentFrame().ip++
+		case code.OpCall, code.OpCallWide:
+			numArgs := vm.readOperand(ins, ip, op)
 
-			err := vm.executeCall(int(numArgs))
+			err := vm.executeCall(numArgs)
 			if err != nil {
 				return err
 			}
 
-		case code.OpReturnValue:
-			returnValue := vm.pop()
+		case code.OpSpawn, code.OpSpawnWide:
+			numArgs := vm.readOperand(ins, ip, op)
 
-			frame := vm.popFrame()
-			vm.sp = frame.basePointer - 1
+			err := vm.executeSpawn(numArgs)
+			if err != nil {
+				return err
+			}
+
+		case code.OpTailCall, code.OpTailCallWide:
+			numArgs := vm.readOperand(ins, ip, op)
 
-			err := vm.push(returnValue)
+			err := vm.executeTailCall(numArgs)
+			if err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			err := vm.returnValue()
 			if err != nil {
 				return err
 			}
@@ -252,32 +418,40 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpSetLocal:
-			localIndex := code.ReadUint8(ins[ip+1:])
-			vm.currentFrame().ip++
+		case code.OpSetLocal, code.OpSetLocalWide:
+			localIndex := vm.readOperand(ins, ip, op)
 
 			frame := vm.currentFrame()
 
-			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+			vm.stack[frame.basePointer+localIndex] = vm.pop()
 
-		case code.OpGetLocal:
-			localIndex := code.ReadUint8(ins[ip+1:])
-			vm.currentFrame().ip++
+		case code.OpGetLocal, code.OpGetLocalWide:
+			localIndex := vm.readOperand(ins, ip, op)
 
 			frame := vm.currentFrame()
 
-			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			err := vm.push(vm.stack[frame.basePointer+localIndex])
 			if err != nil {
 				return err
 			}
 
-		case code.OpGetBuiltin:
-			builtinIndex := code.ReadUint8(ins[ip+1:])
-			vm.currentFrame().ip++
+		case code.OpGetBuiltin, code.OpGetBuiltinWide:
+			builtinIndex := vm.readOperand(ins, ip, op)
 
-			definition := object.Builtins[builtinIndex]
+			builtin := vm.runtime.Builtins().Get(builtinIndex)
+			if builtin == nil {
+				return fmt.Errorf("undefined builtin %d", builtinIndex)
+			}
+
+			err := vm.push(builtin)
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetModule:
+			moduleIndex := vm.readOperand(ins, ip, op)
 
-			err := vm.push(definition.Builtin)
+			err := vm.push(object.Modules[moduleIndex].Module)
 			if err != nil {
 				return err
 			}
@@ -292,9 +466,18 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpGetFree:
-			freeIndex := code.ReadUint8(ins[ip+1:])
-			vm.currentFrame().ip++
+		case code.OpClosureWide:
+			constIndex := code.ReadUint32(ins[ip+1:])
+			numFree := code.ReadUint16(ins[ip+5:])
+			vm.currentFrame().ip += 6
+
+			err := vm.pushClosure(int(constIndex), int(numFree))
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetFree, code.OpGetFreeWide:
+			freeIndex := vm.readOperand(ins, ip, op)
 			currentClosure := vm.currentFrame().closure
 
 			err := vm.push(currentClosure.Free[freeIndex])
@@ -309,15 +492,38 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+
+		case code.OpYield:
+			vm.yielded = vm.pop()
+			vm.suspended = true
+
+			return nil
 		}
 	}
 
 	return nil
 }
 
+// readOperand reads the operand of the single-operand instruction op at ip, in either its narrow
+// or its wide form, and moves the current frame's ip past it
+func (vm *VM) readOperand(ins code.Instructions, ip int, op code.Opcode) int {
+	switch op {
+	case code.OpConstantWide:
+		vm.currentFrame().ip += 4
+		return int(code.ReadUint32(ins[ip+1:]))
+	case code.OpConstant, code.OpGetLocalWide, code.OpSetLocalWide, code.OpGetBuiltinWide,
+		code.OpCallWide, code.OpTailCallWide, code.OpSpawnWide, code.OpGetFreeWide:
+		vm.currentFrame().ip += 2
+		return int(code.ReadUint16(ins[ip+1:]))
+	default:
+		vm.currentFrame().ip++
+		return int(code.ReadUint8(ins[ip+1:]))
+	}
+}
+
 func (vm *VM) push(obj object.Object) error {
-	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+	if err := vm.growStack(vm.sp + 1); err != nil {
+		return err
 	}
 
 	vm.stack[vm.sp] = obj
@@ -342,6 +548,8 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	switch {
 	case leftType == object.IntegerObj && rightType == object.IntegerObj:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
 	case leftType == object.StringObj && rightType == object.StringObj:
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
@@ -373,6 +581,32 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 	return vm.push(&object.Integer{Value: result})
 }
 
+// executeBinaryFloatOperation runs arithmetic where at least one side is a Float, converting an
+// Integer on the other side
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Object) error {
+	leftValue, _ := object.ToFloat(left)
+	rightValue, _ := object.ToFloat(right)
+
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	case code.OpMod:
+		result = math.Mod(leftValue, rightValue)
+	default:
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
 func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
 	if op != code.OpAdd {
 		return fmt.Errorf("unknown String operator %d", op)
@@ -380,6 +614,9 @@ func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Ob
 
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
+	if err := vm.runtime.Allocate(object.StringSize(len(leftValue) + len(rightValue))); err != nil {
+		return err
+	}
 
 	return vm.push(&object.String{Value: leftValue + rightValue})
 }
@@ -388,10 +625,18 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
+	if left.Type() == object.FloatObj && isNumber(right) || right.Type() == object.FloatObj && isNumber(left) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+
 	if left.Type() == object.IntegerObj || right.Type() == object.IntegerObj {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
+	if left.Type() == object.EnumValueObj && right.Type() == object.EnumValueObj {
+		return vm.executeEnumValueComparison(op, left, right)
+	}
+
 	switch op {
 	case code.OpEqualEqual:
 		if right.Type() == object.StringObj && left.Type() == object.StringObj {
@@ -441,6 +686,38 @@ func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object
 	}
 }
 
+func (vm *VM) executeFloatComparison(op code.Opcode, left, right object.Object) error {
+	leftValue, _ := object.ToFloat(left)
+	rightValue, _ := object.ToFloat(right)
+
+	switch op {
+	case code.OpEqualEqual:
+		return vm.push(nativeBoolToBooleanObj(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObj(rightValue != leftValue))
+	case code.OpGreater:
+		return vm.push(nativeBoolToBooleanObj(leftValue > rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObj(leftValue >= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeEnumValueComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.EnumValue)
+	rightValue := right.(*object.EnumValue)
+
+	switch op {
+	case code.OpEqualEqual:
+		return vm.push(nativeBoolToBooleanObj(leftValue.Equals(rightValue)))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObj(!leftValue.Equals(rightValue)))
+	default:
+		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
+	}
+}
+
 func nativeBoolToBooleanObj(input bool) *object.Boolean {
 	if input {
 		return True
@@ -490,6 +767,9 @@ func (vm *VM) executeBangOperator() error {
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 
+	if f, ok := operand.(*object.Float); ok {
+		return vm.push(&object.Float{Value: -f.Value})
+	}
 	if operand.Type() != object.IntegerObj {
 		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
 	}
@@ -506,21 +786,29 @@ func (vm *VM) executePostfixOperator(op code.Opcode, ins code.Instructions, ip i
 		return fmt.Errorf("invalid left-hand side expression in postfix operation: %s", operand.Type())
 	}
 
-	// Increment or decrement the operand based on opcode
+	// Increment or decrement the operand based on opcode. Integers may be shared with the constant
+	// pool or another goroutine, so the binding gets a new one rather than mutating it in place
+	value := operand.(*object.Integer).Value
 	if op == code.OpPlusPlus {
-		operand.(*object.Integer).Value++
+		operand = &object.Integer{Value: value + 1}
 	} else {
-		operand.(*object.Integer).Value--
+		operand = &object.Integer{Value: value - 1}
 	}
 
-	// Based on whether the operand was a global or local binding, set the new
-	// updated operand appropriately
-	if code.Opcode(ins[ip-3]) == code.OpGetGlobal {
-		globalIndex := code.ReadUint16(ins[ip-5:])
+	// Based on whether the operand was a global or local binding (the instruction that loaded
+	// it comes right before this one), set the new updated operand appropriately
+	switch {
+	case ip >= 3 && code.Opcode(ins[ip-3]) == code.OpGetGlobal:
+		globalIndex := code.ReadUint16(ins[ip-2:])
 		vm.currentFrame().ip++
-		vm.globals[globalIndex] = operand
-	} else if code.Opcode(ins[ip-2]) == code.OpGetLocal {
-		localIndex := code.ReadUint8(ins[ip-3:])
+		vm.setGlobal(globalIndex, operand)
+	case ip >= 3 && code.Opcode(ins[ip-3]) == code.OpGetLocalWide:
+		localIndex := code.ReadUint16(ins[ip-2:])
+		vm.currentFrame().ip++
+		frame := vm.currentFrame()
+		vm.stack[frame.basePointer+int(localIndex)] = operand
+	case ip >= 2 && code.Opcode(ins[ip-2]) == code.OpGetLocal:
+		localIndex := code.ReadUint8(ins[ip-1:])
 		vm.currentFrame().ip++
 		frame := vm.currentFrame()
 		vm.stack[frame.basePointer+int(localIndex)] = operand
@@ -529,17 +817,23 @@ func (vm *VM) executePostfixOperator(op code.Opcode, ins code.Instructions, ip i
 	return nil
 }
 
-func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+func (vm *VM) buildArray(startIndex, endIndex int) (object.Object, error) {
+	if err := vm.runtime.Allocate(object.ArraySize(endIndex - startIndex)); err != nil {
+		return nil, err
+	}
 	elements := make([]object.Object, endIndex-startIndex)
 
 	for i := startIndex; i < endIndex; i++ {
 		elements[i-startIndex] = vm.stack[i]
 	}
 
-	return &object.Array{Elements: elements}
+	return &object.Array{Elements: elements}, nil
 }
 
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	if err := vm.runtime.Allocate(object.HashSize((endIndex - startIndex) / 2)); err != nil {
+		return nil, err
+	}
 	hashedPairs := make(map[object.HashKey]object.HashPair)
 
 	for i := startIndex; i < endIndex; i += 2 {
@@ -568,6 +862,8 @@ func (vm *VM) executeIndexExpr(left, index object.Object) error {
 		return vm.executeArrayIndex(left, index)
 	case left.Type() == object.HashObj:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.EnumObj:
+		return vm.executeEnumIndex(left, index)
 	default:
 		return fmt.Errorf("index operator not supported: %s", left.Type())
 	}
@@ -601,6 +897,22 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	return vm.push(pair.Value)
 }
 
+func (vm *VM) executeEnumIndex(enum, index object.Object) error {
+	enumObject := enum.(*object.Enum)
+
+	name, ok := index.(*object.String)
+	if !ok {
+		return fmt.Errorf("enum variant must be accessed by name: %s", index.Type())
+	}
+
+	variant, ok := enumObject.Variant(name.Value)
+	if !ok {
+		return fmt.Errorf("enum %s has no variant %s", enumObject.Name, name.Value)
+	}
+
+	return vm.push(variant)
+}
+
 func (vm *VM) executeCall(numArgs int) error {
 	callee := vm.stack[vm.sp-1-numArgs]
 	switch callee := callee.(type) {
@@ -613,25 +925,140 @@ func (vm *VM) executeCall(numArgs int) error {
 	}
 }
 
+// executeTailCall calls a closure in place of the function that is running: the callee and its
+// arguments are moved down to where the current function's sit and the current frame is reused,
+// so tail recursion runs in constant space. Calls that don't run in a frame of their own (builtins
+// and generators) are made as usual and their result returned straight away
+func (vm *VM) executeTailCall(numArgs int) error {
+	cl, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok || cl.Fn.IsGenerator {
+		err := vm.executeCall(numArgs)
+		if err != nil {
+			return err
+		}
+		return vm.returnValue()
+	}
+
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments. Expected: %d. Got: %d", cl.Fn.NumParameters, numArgs)
+	}
+
+	frame := vm.currentFrame()
+	if err := vm.growStack(frame.basePointer + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	frame.closure = cl
+	frame.ip = -1
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+// returnValue returns from the current frame with the value on top of the stack
+func (vm *VM) returnValue() error {
+	returnValue := vm.pop()
+
+	frame := vm.popFrame()
+	vm.sp = frame.basePointer - 1
+
+	return vm.push(returnValue)
+}
+
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	if numArgs != cl.Fn.NumParameters {
 		return fmt.Errorf("wrong number of arguments. Expected: %d. Got: %d", cl.Fn.NumParameters, numArgs)
 	}
-	if vm.framesIndex < vm.maxFramesUsed {
-		vm.frames[vm.framesIndex].basePointer = vm.sp - numArgs
-		vm.frames[vm.framesIndex].ip = -1
-		vm.frames[vm.framesIndex].closure = cl
-		vm.framesIndex++
-		vm.sp = vm.sp - numArgs + cl.Fn.NumLocals
-	} else {
-		frame := NewFrame(cl, vm.sp-numArgs)
-		vm.pushFrame(frame)
-		vm.sp = frame.basePointer + cl.Fn.NumLocals
+	if cl.Fn.IsGenerator {
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp = vm.sp - numArgs - 1
+
+		gen, err := vm.newGenerator(cl, args)
+		if err != nil {
+			return err
+		}
+		return vm.push(gen)
+	}
+	return vm.enterClosure(cl, vm.sp-numArgs)
+}
+
+// enterClosure pushes a frame for cl whose arguments start at basePointer and makes room on the
+// stack for its locals
+func (vm *VM) enterClosure(cl *object.Closure, basePointer int) error {
+	if err := vm.growStack(basePointer + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+	if err := vm.pushFrame(cl, basePointer); err != nil {
+		return err
 	}
+	vm.sp = basePointer + cl.Fn.NumLocals
 
 	return nil
 }
 
+// newGenerator creates a Generator that runs cl on a VM of its own, sharing our constants and
+// globals. The generator VM's frame keeps the suspended function's ip, base pointer and stack
+// slice between calls to next()
+func (vm *VM) newGenerator(cl *object.Closure, args []object.Object) (*object.Generator, error) {
+	gen, err := vm.newChildVM(cl, args, vm.globals)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewGenerator(func() (object.Object, bool) {
+		err := gen.Run()
+		if err != nil {
+			return errorObject(err), true
+		}
+
+		if !gen.suspended {
+			return nil, false
+		}
+		gen.suspended = false
+
+		return gen.yielded, true
+	}), nil
+}
+
+// newChildVM creates a VM, sharing our constants, that is ready to run cl with args. Underneath
+// the function's frame sits an empty frame, so once the function returns the child's Run loop
+// ends on its own with the return value as the last element on its stack
+func (vm *VM) newChildVM(cl *object.Closure, args []object.Object, globals []object.Object) (*VM, error) {
+	child := vm.newHostVM(globals)
+
+	if err := child.growStack(1 + len(args)); err != nil {
+		return nil, err
+	}
+	child.stack[0] = cl
+	copy(child.stack[1:], args)
+	child.sp = 1 + len(args)
+
+	if err := child.enterClosure(cl, 1); err != nil {
+		return nil, err
+	}
+
+	return child, nil
+}
+
+// newHostVM creates a VM, sharing our constants, with nothing but an empty frame on it. It can
+// run closures through Call, so builtins running on another goroutine use one as their Host
+func (vm *VM) newHostVM(globals []object.Object) *VM {
+	host := &VM{
+		constants:    vm.constants,
+		stack:        make([]object.Object, min(initialStackSize, vm.maxStackSize)),
+		globals:      globals,
+		frames:       make([]*Frame, 1, min(initialFrames, vm.maxFrames)),
+		framesIndex:  1,
+		maxStackSize: vm.maxStackSize,
+		maxFrames:    vm.maxFrames,
+		runtime:      vm.runtime,
+	}
+	host.frames[0] = NewFrame(&object.Closure{Fn: &object.CompiledFunction{}}, 0)
+
+	return host
+}
+
 func (vm *VM) pushClosure(constIndex int, numFree int) error {
 	constant := vm.constants[constIndex]
 
@@ -654,7 +1081,20 @@ func (vm *VM) pushClosure(constIndex int, numFree int) error {
 
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
-	result := builtin.Fn(args...)
+	result := builtin.Call(vm, args...)
+	if err := vm.callErr; err != nil {
+		vm.callErr = nil
+		return err
+	}
+	if err, ok := result.(*object.Error); ok {
+		if err.Exit {
+			return &ExitError{Code: err.Code}
+		}
+		// A builtin that ran code on another VM, such as a generator's, may have been stopped
+		if stopped := vm.runtime.Stopped(); stopped != nil {
+			return stopped
+		}
+	}
 	vm.sp = vm.sp - numArgs - 1
 
 	if result != nil {
@@ -666,6 +1106,86 @@ func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	return nil
 }
 
+// Call implements object.Host, calling fn with args and running it to completion before
+// returning its result. Builtins such as map use it to call the closures they are passed. If the
+// call fails, the error is kept in callErr so callBuiltin can stop the VM with it once the
+// builtin returns
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		result := builtin.Call(vm, args...)
+		if _, ok := result.(*object.Error); ok {
+			// Such as the builtin going over the memory limit with what it built
+			if err := vm.runtime.Stopped(); err != nil && vm.callErr == nil {
+				vm.callErr = err
+			}
+		}
+		if result != nil {
+			return result
+		}
+		return Null
+	}
+
+	depth, sp := vm.framesIndex, vm.sp
+	err := vm.callback(fn, args, depth)
+	if err != nil {
+		vm.framesIndex, vm.sp = depth, sp
+		if vm.callErr == nil {
+			vm.callErr = err
+		}
+		return errorObject(err)
+	}
+
+	return vm.pop()
+}
+
+// CallFunction calls fn, a closure or builtin, with args from outside of the VM's Run, such as from
+// a Go program calling a function a script defined. Unlike Call it reports a failed call as an error
+func (vm *VM) CallFunction(fn object.Object, args ...object.Object) (object.Object, error) {
+	result := vm.Call(fn, args...)
+	if err := vm.callErr; err != nil {
+		vm.callErr = nil
+		return nil, err
+	}
+	if err, ok := result.(*object.Error); ok && err.Exit {
+		return nil, &ExitError{Code: err.Code}
+	}
+	return result, nil
+}
+
+// callback pushes fn and args and runs the call until the frame at depth is back on top, leaving
+// the result on the stack
+func (vm *VM) callback(fn object.Object, args []object.Object, depth int) error {
+	cl, ok := fn.(*object.Closure)
+	if !ok {
+		return fmt.Errorf("calling non-function and non-builtin")
+	}
+	if err := vm.growStack(vm.sp + 1 + len(args)); err != nil {
+		return err
+	}
+	vm.stack[vm.sp] = cl
+	copy(vm.stack[vm.sp+1:], args)
+	vm.sp += 1 + len(args)
+
+	if err := vm.callClosure(cl, len(args)); err != nil {
+		return err
+	}
+	// Generators are created without entering a frame of their own
+	if vm.framesIndex == depth {
+		return nil
+	}
+
+	return vm.run(depth)
+}
+
+// Runtime implements object.Host
+func (vm *VM) Runtime() *object.Runtime {
+	return vm.runtime
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntegerObj || obj.Type() == object.FloatObj
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 	case *object.Boolean:
@@ -677,10 +1197,29 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+// getGlobal reads the global at index. Spawned code shares our globals, so once there is any
+// they are read under the Runtime's lock
+func (vm *VM) getGlobal(index uint16) object.Object {
+	if lock := vm.runtime.GlobalsLock(); lock != nil {
+		lock.RLock()
+		defer lock.RUnlock()
+	}
+	return vm.globals[index]
+}
+
+// setGlobal sets the global at index, see getGlobal
+func (vm *VM) setGlobal(index uint16, val object.Object) {
+	if lock := vm.runtime.GlobalsLock(); lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	vm.globals[index] = val
+}
+
 // NewWithGlobalsState creates a new VM with a compiler's bytecode, sets the VMs globals
 // and returns a pointer to the VM (used in REPL)
-func NewWithGlobalsState(bytecode *compiler.Bytecode, s []object.Object) *VM {
-	vm := New(bytecode)
+func NewWithGlobalsState(bytecode *compiler.Bytecode, s []object.Object, opts ...Option) *VM {
+	vm := New(bytecode, opts...)
 	vm.globals = s
 	return vm
 }
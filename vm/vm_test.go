@@ -1,8 +1,13 @@
 package vm
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bradford-hamilton/monkey-lang/ast"
 	"github.com/bradford-hamilton/monkey-lang/compiler"
@@ -182,6 +187,51 @@ func TestIndexExpressions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestEnums(t *testing.T) {
+	tests := []vmTestCase{
+		{`enum Color { Red, Green }; Color.Red == Color.Red`, true},
+		{`enum Color { Red, Green }; Color.Red == Color.Green`, false},
+		{`enum Color { Red, Green }; Color.Red != Color.Green`, true},
+		{`enum Color { Red }; enum Fruit { Red }; Color.Red == Fruit.Red`, false},
+		{`enum Color { Red, Green }; let c = Color.Green; {Color.Red: 1, Color.Green: 2}[c]`, 2},
+		{`enum Color { Red, Green }; Color["Green"] == Color.Green`, true},
+		{`let f = func() { enum Dir { Up, Down }; Dir.Down }; f() == f()`, true},
+		{`enum Color { Red, Green }; Color.Green`, &object.EnumValue{Enum: "Color", Name: "Green", Ordinal: 1}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestEnumErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`enum Color { Red }; Color.Purple`, "enum Color has no variant Purple"},
+		{`enum Color { Red }; Color[0]`, "enum variant must be accessed by name: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error. Want: %q. Got: %q", tt.expected, err)
+		}
+	}
+}
+
 func TestCallingFunctionsWithoutArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -425,6 +475,10 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 			input:    `func(a, b) { a + b; }(1);`,
 			expected: `wrong number of arguments. Expected: 2. Got: 1`,
 		},
+		{
+			input:    `let f = func(a) { a; }; func() { f(); }();`,
+			expected: `wrong number of arguments. Expected: 1. Got: 0`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -647,6 +701,23 @@ func TestRecursiveFunctions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestGenerators(t *testing.T) {
+	tests := []vmTestCase{
+		{`let g = func() { yield 1; yield 2; }(); next(g)`, 1},
+		{`let g = func() { yield 1; yield 2; }(); next(g); next(g)`, 2},
+		{`let g = func() { yield 1; }(); next(g); next(g)`, Null},
+		{`let g = func() { yield 1; }(); next(g); next(g); next(g)`, Null},
+		{`let g = func(a, b) { let c = a + b; yield c; yield c * 2; }(1, 2); next(g) + next(g)`, 9},
+		{`let g = func(n) { if (n > 1) { yield "big"; } yield "done"; }(5); next(g)`, "big"},
+		{`let gen = func() { yield 1; }; let a = gen(); let b = gen(); next(a); next(b)`, 1},
+		{`let outer = 10; let g = func() { let f = func(x) { x + outer }; yield f(1); }(); next(g)`, 11},
+		{`let g = func() { yield 1; return 99; }(); next(g); next(g)`, Null},
+		{`let g = func() { yield 1 + true; }(); next(g)`, &object.Error{Message: "unsupported types for binary operation: INTEGER BOOLEAN"}},
+	}
+
+	runVMTests(t, tests)
+}
+
 type vmTestCase struct {
 	input    string
 	expected interface{}
@@ -704,6 +775,11 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		if err != nil {
 			t.Errorf("testStringObject failed: %s", err)
 		}
+	case float64:
+		float, ok := actual.(*object.Float)
+		if !ok || float.Value != expected {
+			t.Errorf("object is not Float %v. Got: %T (%+v)", expected, actual, actual)
+		}
 	case []int:
 		array, ok := actual.(*object.Array)
 		if !ok {
@@ -746,6 +822,15 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 				t.Errorf("testIntegerObject failed: %s", err)
 			}
 		}
+	case *object.EnumValue:
+		enumValue, ok := actual.(*object.EnumValue)
+		if !ok {
+			t.Errorf("object is not EnumValue: %T (%+v)", actual, actual)
+			return
+		}
+		if !enumValue.Equals(expected) || enumValue.Ordinal != expected.Ordinal {
+			t.Errorf("Wrong enum value. Expected: %s. Got: %s", expected.Inspect(), enumValue.Inspect())
+		}
 	case *object.Error:
 		errObj, ok := actual.(*object.Error)
 		if !ok {
@@ -796,3 +881,827 @@ func testStringObject(expected string, actual object.Object) error {
 
 	return nil
 }
+
+func TestSpawn(t *testing.T) {
+	tests := []vmTestCase{
+		{`let add = func(a, b) { a + b }; recv(spawn add(1, 2))`, 3},
+		{`let c = channel(); spawn func(ch) { send(ch, 42); }(c); recv(c)`, 42},
+		{`let c = channel(2); send(c, 1); send(c, 2); recv(c) + recv(c)`, 3},
+		{`let c = channel(1); close(c); recv(c)`, Null},
+		{`let c = channel(1); close(c); close(c)`, &object.Error{Message: "Cannot close: close of closed channel"}},
+		{`let c = channel(1); close(c); send(c, 1)`, &object.Error{Message: "Cannot send: send on closed channel"}},
+		{`let c = channel(1); let d = channel(1); send(d, 7); select([c, d])`, []int{1, 7}},
+		{`recv(spawn len("four"))`, 4},
+		{`recv(spawn func() { 1 + true }())`, &object.Error{Message: "unsupported types for binary operation: INTEGER BOOLEAN"}},
+		{`let n = 0; let done = channel(); spawn func() { send(done, n + 1); }(); recv(done)`, 1},
+		// Spawned code shares the globals, so it sees assignments made after it was spawned
+		{`let n = 0; let go = channel(); let r = spawn func() { recv(go); n }(); n++; send(go, 1); recv(r)`, 1},
+		{`let f = func() { let i = 0; i++; i }; let a = spawn f(); let b = spawn f(); recv(a) + recv(b)`, 2},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestSpawnErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`spawn func(a) { a }()`, "wrong number of arguments. Expected: 1. Got: 0"},
+		{`let x = 1; spawn x()`, "spawning non-function and non-builtin"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error. Want: %q. Got: %q", tt.expected, err)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{`let sum = func(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) }; sum(100000, 0)`, 5000050000},
+		{`let count = func(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(5000)`, 0},
+		{`let wrap = func() { let inner = func(n) { if (n == 0) { "done" } else { inner(n - 1) } }; inner(5000) }; wrap()`, "done"},
+		{`let f = func(x) { len(x) }; f("four")`, 4},
+		{`let f = func() { func() { yield 1; }() }; next(f())`, 1},
+		{`let g = func() { yield 1; }; let f = func(x) { x + 1 }; let h = func() { f(2) }; h() + 1`, 4},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestGrowingStackAndFrames(t *testing.T) {
+	elements := make([]string, 300)
+	for i := range elements {
+		elements[i] = "1"
+	}
+
+	tests := []vmTestCase{
+		{`let f = func(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(400)`, 400},
+		{`len([` + strings.Join(elements, ", ") + `])`, 300},
+		{`let f = func(n) { if (n == 0) { yield 0; } else { yield 1 + next(f(n - 1)); } }; next(f(200))`, 200},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []struct {
+		input         string
+		opts          []Option
+		expectedTrace []string
+		expectedDepth int
+	}{
+		{
+			input:         `let f = func(n) { 1 + f(n + 1) }; f(0)`,
+			opts:          []Option{WithMaxFrames(50), WithMaxStackSize(1000000)},
+			expectedTrace: []string{"f", "f", "f", "f", "f", "f", "f", "f", "f", "f"},
+			expectedDepth: 49,
+		},
+		{
+			input:         `let inner = func() { 1 + func() { [1, 2, 3, 4, 5, 6, 7, 8, 9, 10] }() }; let outer = func() { 1 + inner() }; outer()`,
+			opts:          []Option{WithMaxStackSize(8)},
+			expectedTrace: []string{"<anonymous>", "inner", "outer"},
+			expectedDepth: 3,
+		},
+		{
+			input:         `let f = func(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(2000)`,
+			opts:          nil,
+			expectedTrace: []string{"f", "f", "f", "f", "f", "f", "f", "f", "f", "f"},
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode(), tt.opts...)
+		err = vm.Run()
+
+		var overflow *StackOverflowError
+		if !errors.As(err, &overflow) {
+			t.Fatalf("Expected a StackOverflowError. Got: %v", err)
+		}
+
+		if strings.Join(overflow.Trace, " ") != strings.Join(tt.expectedTrace, " ") {
+			t.Errorf("Wrong trace. Want: %q. Got: %q", tt.expectedTrace, overflow.Trace)
+		}
+
+		if tt.expectedDepth != 0 && overflow.Depth != tt.expectedDepth {
+			t.Errorf("Wrong depth. Want: %d. Got: %d", tt.expectedDepth, overflow.Depth)
+		}
+
+		if !strings.HasPrefix(err.Error(), "stack overflow\n\tat ") {
+			t.Errorf("Wrong error message. Got: %q", err.Error())
+		}
+	}
+}
+
+func TestInvalidStackLimits(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let f = func(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(10)`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// Limits below 1 are ignored rather than breaking the VM
+	for _, opts := range [][]Option{{WithMaxFrames(0)}, {WithMaxFrames(-1)}, {WithMaxStackSize(0)}, {WithMaxStackSize(-5)}} {
+		vm := New(comp.Bytecode(), opts...)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		testExpectedObject(t, 10, vm.LastPoppedStackElement())
+	}
+
+	// The smallest limits are valid, if not much use
+	for _, opts := range [][]Option{{WithMaxFrames(1)}, {WithMaxStackSize(1)}} {
+		var overflow *StackOverflowError
+		if err := New(comp.Bytecode(), opts...).Run(); !errors.As(err, &overflow) {
+			t.Errorf("Expected a StackOverflowError. Got: %v", err)
+		}
+	}
+}
+
+func TestWideOperands(t *testing.T) {
+	params := make([]string, 300)
+	args := make([]string, 300)
+	lets := make([]string, 300)
+	for i := range params {
+		params[i] = letterName(i)
+		args[i] = fmt.Sprintf("%d", i)
+		lets[i] = fmt.Sprintf("let %s = %d;", letterName(i), i)
+	}
+
+	constants := make([]string, 65537)
+	for i := range constants {
+		constants[i] = fmt.Sprintf("%d;", i)
+	}
+
+	tests := []vmTestCase{
+		// OpCallWide and OpGetLocalWide
+		{fmt.Sprintf("func(%s) { %s + %s }(%s)", strings.Join(params, ", "), letterName(299), letterName(0), strings.Join(args, ", ")), 299},
+		// OpSetLocalWide and postfix on a wide local
+		{fmt.Sprintf("func() { %s %s++; %s }()", strings.Join(lets, " "), letterName(299), letterName(299)), 300},
+		// OpClosureWide and OpGetFreeWide
+		{fmt.Sprintf("func() { %s func() { %s + %s } }()()", strings.Join(lets, " "), letterName(299), letterName(1)), 300},
+		// OpConstantWide
+		{strings.Join(constants, " "), 65536},
+	}
+
+	runVMTests(t, tests)
+}
+
+// letterName returns a distinct identifier for i made only of letters, since identifiers can't hold digits
+func letterName(i int) string {
+	name := ""
+	for {
+		name = string(rune('a'+i%26)) + name
+		i /= 26
+		if i == 0 {
+			return "v" + name
+		}
+	}
+}
+
+func TestPostfixOperatorBindings(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 1; let b = 5; let c = 2; b++; b", 6},
+		{"let a = 1; let b = 5; let c = 2; b++; c", 2},
+		{"let f = func(x) { x++; x }; f(1)", 2},
+		{"let f = func() { let x = 1; let y = 7; let z = 3; y--; y * 10 + z }; f()", 63},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`map([1, 2, 3], func(x) { x * 2 })`, []int{2, 4, 6}},
+		{`map([], func(x) { x })`, []int{}},
+		{`map(["a", "bc"], len)`, []int{1, 2}},
+		{`let n = 10; map([1, 2], func(x) { x + n })`, []int{11, 12}},
+		{`filter([1, 2, 3, 4], func(x) { x % 2 == 0 })`, []int{2, 4}},
+		{`reduce([1, 2, 3], 10, func(acc, x) { acc + x })`, 16},
+		{`reduce([], 10, func(acc, x) { acc + x })`, 10},
+		{`let seen = channel(3); each([1, 2, 3], func(x) { send(seen, x) }); recv(seen) + recv(seen) + recv(seen)`, 6},
+		{`any([1, 2, 3], func(x) { x > 2 })`, true},
+		{`any([], func(x) { true })`, false},
+		{`all([1, 2, 3], func(x) { x > 0 })`, true},
+		{`all([1, 2, 3], func(x) { x > 1 })`, false},
+		{`find([1, 2, 3], func(x) { x > 1 })`, 2},
+		{`find([1, 2, 3], func(x) { x > 3 })`, Null},
+		{`sort_by([3, 1, 2], func(x) { x })`, []int{1, 2, 3}},
+		{`map(sort_by([[2, 1], [1, 2], [2, 3]], first), last)`, []int{2, 1, 3}},
+		{`join(sort_by(["bb", "a", "ccc"], func(x) { x }), ",")`, "a,bb,ccc"},
+		{`map(map([1, 2], func(x) { map([x], func(y) { y * 10 }) }), first)`, []int{10, 20}},
+		{`let f = func() { map([1, 2], func(x) { return x + 1; }) }; f()`, []int{2, 3}},
+		{`let sum = func(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; map([3, 4], sum)`, []int{6, 10}},
+		{`recv(spawn map([1, 2], func(x) { x * 3 }))`, []int{3, 6}},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; map(g(), func(x) { if (x) { x * 2 } else { 0 } })`, []int{2, 0, 6}},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; filter(g(), func(x) { x })`, []int{1, 3}},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; reduce(g(), 10, func(acc, x) { if (x) { acc + x } else { acc } })`, 14},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; let seen = channel(3); each(g(), func(x) { send(seen, x) }); recv(seen); recv(seen)`, Null},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; any(g(), func(x) { !x })`, true},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; all(g(), func(x) { x })`, false},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; find(g(), func(x) { if (x) { x > 1 } else { false } })`, 3},
+		{`let g = func() { yield 1; yield first([]); yield 3; }; sort_by(filter(g(), func(x) { x }), func(x) { -x })`, []int{3, 1}},
+		{`let g = func() { yield 1; yield 2; yield 3; }(); find(g, func(x) { x == 1 }); next(g)`, 2},
+		{`let g = func() { yield 1; yield 2; }(); map(g, func(x) { x }); map(g, func(x) { x })`, []int{}},
+		{`map(func() { yield 1; -"a"; }(), func(x) { x })`, &object.Error{Message: "unsupported type for negation: STRING"}},
+		{`map(1, len)`, &object.Error{Message: "First argument to `map` must be an Array or a Generator. Got: INTEGER"}},
+		{`map([1], 1)`, &object.Error{Message: "Last argument to `map` must be a function. Got: INTEGER"}},
+		{`sort_by([1, "a"], func(x) { x })`, &object.Error{Message: "Keys for `sort_by` must all be the same type. Got: INTEGER and STRING"}},
+		{`sort_by([[1]], func(x) { x })`, &object.Error{Message: "Keys for `sort_by` must be Integers or Strings. Got: ARRAY"}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestHigherOrderBuiltinErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{`map([1], func(x) { x + "a" })`, "unsupported types for binary operation: INTEGER STRING"},
+		{`map([1], func(x, y) { x })`, "wrong number of arguments. Expected: 2. Got: 1"},
+		{`let f = func() { filter([1], func(x) { -"a" }) }; f()`, "unsupported type for negation: STRING"},
+		{`map(func() { yield 1; yield "a"; }(), func(x) { x + 1 })`, "unsupported types for binary operation: STRING INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error. Want: %q. Got: %q", tt.expected, err)
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("héllo")`, 5},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("MoNKEY")`, "monkey"},
+		{`trim("  a b  ")`, "a b"},
+		{`trim_left("  a  ")`, "a  "},
+		{`trim_right("  a  ")`, "  a"},
+		{`contains("monkey", "key")`, true},
+		{`starts_with("monkey", "key")`, false},
+		{`ends_with("monkey", "key")`, true},
+		{`index_of("héllo", "llo")`, 2},
+		{`index_of("monkey", "z")`, -1},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`pad_left("7", 3, "0")`, "007"},
+		{`pad_right("ab", 4)`, "ab  "},
+		{`pad_left("abcd", 2)`, "abcd"},
+		{`join(chars("héy"), "-")`, "h-é-y"},
+		{`ord("é")`, 233},
+		{`chr(233)`, "é"},
+		{`upper(1)`, &object.Error{Message: "Argument to `upper` must be a String. Got: INTEGER"}},
+		{`replace("a", 1, "b")`, &object.Error{Message: "Second argument to `replace` must be a String. Got: INTEGER"}},
+		{`repeat("a", -1)`, &object.Error{Message: "Second argument to `repeat` must not be negative. Got: -1"}},
+		{`pad_left("a", 3, "xy")`, &object.Error{Message: "Third argument to `pad_left` must be a single character String. Got: xy"}},
+		{`ord("ab")`, &object.Error{Message: "Argument to `ord` must be a single character. Got: \"ab\""}},
+		{`chr(-1)`, &object.Error{Message: "Argument to `chr` is not a valid code point. Got: -1"}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`keys({3: 1, 1: 2, 2: 3})`, []int{1, 2, 3}},
+		{`values({3: 1, 1: 2, 2: 3})`, []int{2, 3, 1}},
+		{`map(items({2: 20, 1: 10}), last)`, []int{10, 20}},
+		{`has_key({"a": 1}, "a")`, true},
+		{`has_key({"a": 1}, "b")`, false},
+		{`let h = {1: 1, 2: 2}; delete(h, 1); keys(h)`, []int{1, 2}},
+		{`delete({1: 1, 2: 2}, 1)`, map[object.HashKey]int64{(&object.Integer{Value: 2}).HashKey(): 2}},
+		{`merge({1: 1, 2: 2}, {2: 3})`, map[object.HashKey]int64{
+			(&object.Integer{Value: 1}).HashKey(): 1,
+			(&object.Integer{Value: 2}).HashKey(): 3,
+		}},
+		{`reverse([1, 2, 3])`, []int{3, 2, 1}},
+		{`sort([3, 1, 2, 1])`, []int{1, 1, 2, 3}},
+		{`join(sort(["b", "C", "a"]), "")`, "Cab"},
+		{`map(sort([[2], [1, 5], [1]]), len)`, []int{1, 2, 1}},
+		{`slice([1, 2, 3, 4], 1)`, []int{2, 3, 4}},
+		{`slice([1, 2, 3, 4], -3, -1)`, []int{2, 3}},
+		{`slice([1, 2], 5)`, []int{}},
+		{`concat([1], [2, 3], [])`, []int{1, 2, 3}},
+		{`contains([1, [2]], [2])`, true},
+		{`contains([1], "1")`, false},
+		{`index_of([4, 5], 5)`, 1},
+		{`index_of([4, 5], 6)`, -1},
+		{`flatten([[1, 2], 3, []])`, []int{1, 2, 3}},
+		{`map(zip([1, 2, 3], [4, 5]), last)`, []int{4, 5}},
+		{`uniq([1, 2, 1, 3, 2])`, []int{1, 2, 3}},
+		{`range(3)`, []int{0, 1, 2}},
+		{`range(1, 4)`, []int{1, 2, 3}},
+		{`range(5, 0, -2)`, []int{5, 3, 1}},
+		{`range(0, 10, 3)`, []int{0, 3, 6, 9}},
+		{`len(range(9223372036854775800, 9223372036854775807, 5))`, 2},
+		{`len(range(-9223372036854775807 - 1, -9223372036854775800, 3))`, 3},
+		{`last(range(9223372036854775807, 9223372036854775800, -4))`, 9223372036854775803},
+		{`range(1, 2, 0)`, &object.Error{Message: "Step argument to `range` must not be 0"}},
+		{`keys([])`, &object.Error{Message: "Argument to `keys` must be a Hash. Got: ARRAY"}},
+		{`has_key({}, [1])`, &object.Error{Message: "Unusable as hash key: ARRAY"}},
+		{`merge({}, [])`, &object.Error{Message: "Second argument to `merge` must be a Hash. Got: ARRAY"}},
+		{`concat([1], 2)`, &object.Error{Message: "Arguments to `concat` must be Arrays. Got: INTEGER at position 1"}},
+		{`contains(1, 2)`, &object.Error{Message: "First argument to `contains` must be a String or an Array. Got: INTEGER"}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(first([]))`, "NULL"},
+		{`type({})`, "HASH"},
+		{`type(func() {})`, "FUNCTION"},
+		{`let f = func(x) { func() { x } }; type(f(1))`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`int("42")`, 42},
+		{`int("-7")`, -7},
+		{`int(true)`, 1},
+		{`int("abc")`, &object.Error{Message: "Could not convert \"abc\" to an Integer"}},
+		{`int([])`, &object.Error{Message: "Argument to `int` not supported. Got: ARRAY"}},
+		{`str(5)`, "5"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`str(first([]))`, "null"},
+		{`bool(0)`, true},
+		{`bool(first([]))`, false},
+		{`is_int(1)`, true},
+		{`is_string(1)`, false},
+		{`is_array([])`, true},
+		{`is_hash([])`, false},
+		{`is_null(first([]))`, true},
+		{`is_bool(false)`, true},
+		{`is_function(func(x) { x })`, true},
+		{`is_function(len)`, true},
+		{`type(1, 2)`, &object.Error{Message: "Wrong number of arguments. Got: 2, Expected: 1"}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestFloats(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"1.5 + 2", 3.5},
+		{"3 / 2.0", 1.5},
+		{"7.5 % 2", 1.5},
+		{"-2.5", -2.5},
+		{"2.5 > 2", true},
+		{"2 < 2.5", true},
+		{"1.0 == 1", true},
+		{"1.5 != 1.5", false},
+		{"2.0 >= 2", true},
+		{`type(2.0)`, "FLOAT"},
+		{`int(-2.9)`, -2},
+		{`str(2.0)`, "2.0"},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []vmTestCase{
+		{"math.PI", 3.141592653589793},
+		{"math.abs(-3)", 3},
+		{"math.abs(-2.5)", 2.5},
+		{"math.min(3, 1.5, 2)", 1.5},
+		{"math.max([1, 9, 4])", 9},
+		{"math.pow(2, 10)", 1024},
+		{"math.pow(2, -1)", 0.5},
+		{"math.sqrt(16)", 4.0},
+		{"math.floor(2.7)", 2},
+		{"math.ceil(2.1)", 3},
+		{"math.round(-2.5)", -3},
+		{"math.gcd(12, -18)", 6},
+		{"math.clamp(15, 0, 10)", 10},
+		{"math.clamp(-1.5, 0, 10)", 0},
+		{"let area = func(r) { math.PI * math.pow(r, 2) }; math.round(area(2))", 13},
+		{"map([1.4, 2.6], math.round)", []int{1, 3}},
+		{"let math = 2; math", 2},
+		{"math.sqrt(-1)", &object.Error{Message: "Argument to `math.sqrt` must not be negative. Got: -1"}},
+		{"math.gcd(1.5, 2)", &object.Error{Message: "Arguments to `math.gcd` must be Integers. Got: FLOAT"}},
+		{"math.abs(\"a\")", &object.Error{Message: "Arguments to `math.abs` must be Integers or Floats. Got: STRING"}},
+		{"math.pow(2, 62)", 4611686018427387904},
+		{"math.pow(-2, 63)", -9223372036854775808},
+		{"math.pow(-1, 9223372036854775807)", -1},
+		{"math.pow(2, 64)", &object.Error{Message: "Result of `math.pow` is too large for an Integer. Got: 2 and 64"}},
+		{"math.pow(3, 40)", &object.Error{Message: "Result of `math.pow` is too large for an Integer. Got: 3 and 40"}},
+		{"math.abs(-9223372036854775807 - 1)", &object.Error{Message: "Result of `math.abs` is too large for an Integer. Got: -9223372036854775808"}},
+		{"math.gcd(-9223372036854775807 - 1, 0)", &object.Error{Message: "Result of `math.gcd` is too large for an Integer. Got: -9223372036854775808 and 0"}},
+		{"math.gcd(-9223372036854775807 - 1, 6)", 2},
+		{"math.nope", Null},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestRandomBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`let draw = func() { str([random(), random_int(1, 100), choice([1, 2, 3]), shuffle(range(5))]) }; seed(7); let a = draw(); seed(7); a == draw()`, true},
+		{`seed(7); let a = random(); let b = random(); a == b`, false},
+		{`seed(1); recv(spawn random_int(5, 5))`, 5},
+		{`let x = random_int(3, 4); x >= 3 && x <= 4`, true},
+		{`len(shuffle([1, 2, 3]))`, 3},
+		{`sort(shuffle([3, 1, 2]))`, []int{1, 2, 3}},
+		{`choice([])`, Null},
+		{`seed(1)`, Null},
+	}
+
+	runVMTests(t, tests)
+
+	// Every VM has a generator of its own, so seeding one doesn't affect another
+	draw := func(input string) object.Object {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		return vm.LastPoppedStackElement()
+	}
+	seeded := draw(`seed(3); random()`).Inspect()
+	if again := draw(`seed(3); random()`).Inspect(); again != seeded {
+		t.Errorf("VMs seeded the same should draw the same numbers. Got: %s and %s", seeded, again)
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`json_encode({"b": [1, 2.5, "x<y>", true, first([])], "a": {"n": -3}})`, `{"a":{"n":-3},"b":[1,2.5,"x<y>",true,null]}`},
+		{`json_encode([1, [2]], 1)`, "[\n 1,\n [\n  2\n ]\n]"},
+		{`let v = {"a": [1, {"b": "c"}]}; json_encode(json_decode(json_encode(v))) == json_encode(v)`, true},
+		{`json_decode("[1, 2, 3]")`, []int{1, 2, 3}},
+		{`json_decode("[1, 2,")`, &object.Error{Message: "Invalid JSON at line 1, column 7: unexpected end of JSON input"}},
+		{`json_encode({1: 2})`, &object.Error{Message: "Could not encode JSON: hash keys must be Strings. Got: INTEGER"}},
+		{`json_encode(func() {})`, &object.Error{Message: "Could not encode JSON: FUNCTION has no JSON representation"}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestFileBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	run := func(input string, policy object.FilePolicy) object.Object {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		rt := object.NewRuntime()
+		rt.SetFilePolicy(policy)
+		vm := New(comp.Bytecode(), WithRuntime(rt))
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		return vm.LastPoppedStackElement()
+	}
+
+	input := fmt.Sprintf(`let p = "%s/notes.txt"; write_file(p, "a"); append_file(p, "b"); let s = read_file(p); remove(p); [s, exists(p), len(list_dir("%s"))]`, dir, dir)
+	if res := run(input, object.FilePolicy{Read: []string{dir}, Write: []string{dir}}); res.Inspect() != "[ab, false, 0]" {
+		t.Errorf("file builtins returned wrong result. Got: %s", res.Inspect())
+	}
+
+	// Without a policy the VM has no file system access
+	expected := fmt.Sprintf("Error: `list_dir` is not allowed to read %s", dir)
+	if res := run(fmt.Sprintf(`list_dir("%s")`, dir), object.FilePolicy{}); res.Inspect() != expected {
+		t.Errorf("file builtins should be denied by default. Expected: %s. Got: %s", expected, res.Inspect())
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`exit(); 1`, 0},
+		{`let x = 5; if (x > 1) { exit(3) }; x`, 3},
+		{`map([1, 2, 3], func(x) { if (x == 2) { exit(4) }; x }); 1`, 4},
+		{`let g = func() { yield 1; exit(5) }(); next(g); next(g); 1`, 5},
+		{`recv(spawn exit(6)); 1`, 6},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+
+		var exit *ExitError
+		if !errors.As(err, &exit) {
+			t.Errorf("expected VM to exit, got: %v", err)
+			continue
+		}
+		if exit.Code != tt.expected {
+			t.Errorf("wrong exit status. want=%d, got=%d", tt.expected, exit.Code)
+		}
+	}
+
+	rt := object.NewRuntime()
+	rt.SetStdin(strings.NewReader("a\nb\n"))
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let first = read_line(); [first, stdin_lines(), env("MONKEY_TEST_UNSET")]`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode(), WithRuntime(rt))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if res := vm.LastPoppedStackElement().Inspect(); res != "[a, [b], null]" {
+		t.Errorf("input builtins returned wrong result. Got: %s", res)
+	}
+}
+
+func TestTimeBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`format_time(parse_time("2024-03-09 14:30", "2006-01-02 15:04"), "Jan 2, 2006 at 3:04pm")`, "Mar 9, 2024 at 2:30pm"},
+		{`let start = clock(); sleep(1); clock() > start`, true},
+		{`now() > parse_time("2024", "2006")`, true},
+		{`sleep(0)`, Null},
+	}
+
+	runVMTests(t, tests)
+
+	// A manual clock makes sleeping scripts fast and their times predictable
+	rt := object.NewRuntime()
+	rt.SetClock(object.NewManualClock(time.UnixMilli(1000)))
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let start = now(); sleep(60000); [start, now(), clock()]`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode(), WithRuntime(rt))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if res := vm.LastPoppedStackElement().Inspect(); res != "[1000, 61000, 60000.0]" {
+		t.Errorf("time builtins returned wrong result. Got: %s", res)
+	}
+}
+
+func TestRegex(t *testing.T) {
+	tests := []vmTestCase{
+		{`match(re"\d+", "ab12cd345")`, "12"},
+		{`match(re"(\w+)@(\w+)", "mail bob@example now")[2]`, "example"},
+		{`match(re"\d", "abc")`, Null},
+		{`captures(re"(?P<user>\w+)@(?P<host>\w+)", "bob@example")["host"]`, "example"},
+		{`join(find_all(re"\d+", "a1b22c333"), ",")`, "1,22,333"},
+		{`join(map(find_all(re"(\w)=(\d)", "a=1 b=2"), func(m) { m[2] }), "")`, "12"},
+		{`replace_all(re"(\w+)@(\w+)", "bob@example", "$2 at $1")`, "example at bob"},
+		{`replace_all(re"\d+", "a1b22", func(m) { str(int(m) * 2) })`, "a2b44"},
+		{`let r = regex("^[a-z]+$"); is_null(match(r, "ab1")) && (match(r, "abc") == "abc")`, true},
+		{`type(re"a")`, "REGEX"},
+		{`str(re"a+b")`, `re"a+b"`},
+		{`let words = func(s) { find_all(re"[a-z]+", s) }; len(words("one two")) + len(words("three"))`, 3},
+		{`regex("(")`, &object.Error{Message: "Invalid regular expression \"(\": error parsing regexp: missing closing ): `(`"}},
+	}
+
+	runVMTests(t, tests)
+
+	// The compiler puts a literal in the constant pool once, compiled
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let f = func() { re"x+" }; f(); f()`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if vm.LastPoppedStackElement() != comp.Bytecode().Constants[0] {
+		t.Errorf("regex literal should be its constant. Got: %s", vm.LastPoppedStackElement().Inspect())
+	}
+}
+
+func TestOutput(t *testing.T) {
+	tests := []vmTestCase{
+		{`sprintf("%s scored %d (%.1f%%)", "ann", 9, 90)`, "ann scored 9 (90.0%)"},
+		{`sprintf("%v", {"a": [1, 2.5]})`, "{a: [1, 2.5]}"},
+		{`sprintf("%d", "x")`, &object.Error{Message: "Verb %d in the format string passed to `sprintf` needs an Integer. Got: STRING"}},
+	}
+
+	runVMTests(t, tests)
+
+	var out bytes.Buffer
+	comp := compiler.New()
+	if err := comp.Compile(parse(`print("a", 1); write("b", 2); printf("<%3d>", 3); each([4, 5], func(x) { write(x) }); recv(spawn print(6))`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode(), WithOutput(&out))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if out.String() != "a\n1\nb2<  3>456\n" {
+		t.Errorf("output builtins wrote wrong output. Got: %q", out.String())
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	builtins := object.DefaultRegistry()
+	builtins.Register("double", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Integer{Value: 2 * args[0].(*object.Integer).Value}
+		},
+		Params: []object.ObjectType{object.IntegerObj},
+	})
+	builtins.Register("VERSION", &object.String{Value: "1.0"})
+	// Replacing a default builtin keeps its position, so bytecode compiled with defaults still runs
+	builtins.Register("len", &object.Builtin{Fn: func(args ...object.Object) object.Object { return &object.Integer{Value: -1} }})
+
+	run := func(comp *compiler.Compiler, input string) object.Object {
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		rt := object.NewRuntime()
+		rt.SetBuiltins(builtins)
+		vm := New(comp.Bytecode(), WithRuntime(rt))
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		return vm.LastPoppedStackElement()
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[double(21), VERSION, map([1, 2], double), len([1])]`, "[42, 1.0, [2, 4], -1]"},
+		{`double("a")`, "Error: Argument to `double` must be an Integer. Got: STRING"},
+		{`let VERSION = 2; VERSION`, "2"},
+	}
+
+	for _, tt := range tests {
+		if res := run(compiler.NewWithBuiltins(builtins), tt.input); res.Inspect() != tt.expected {
+			t.Errorf("wrong result. Expected: %s. Got: %s", tt.expected, res.Inspect())
+		}
+	}
+
+	if res := run(compiler.New(), `len([1])`); res.Inspect() != "-1" {
+		t.Errorf("wrong result. Expected: -1. Got: %s", res.Inspect())
+	}
+	if err := compiler.New().Compile(parse(`double(1)`)); err == nil || err.Error() != "undefined variable double" {
+		t.Errorf("the default compiler should not know registered builtins. Got: %v", err)
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	loop := `let loop = func(n) { loop(n + 1) };`
+	runaway := []string{
+		loop + `loop(0)`,
+		loop + `map([1], func(x) { loop(x) })`,
+		loop + `let g = func() { loop(0); yield 1 }(); next(g)`,
+		loop + `recv(spawn loop(0))`,
+	}
+	run := func(input string, rt *object.Runtime) error {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		return New(comp.Bytecode(), WithRuntime(rt)).Run()
+	}
+
+	for _, input := range runaway {
+		rt := object.NewRuntime()
+		rt.SetLimits(object.Limits{Steps: 10000})
+		if err := run(input, rt); !errors.Is(err, object.ErrStepLimit) {
+			t.Errorf("expected %q to run out of steps, got: %v", input, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		rt = object.NewRuntime()
+		rt.SetContext(ctx)
+		if err := run(input, rt); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %q to time out, got: %v", input, err)
+		}
+		cancel()
+	}
+
+	// Builtins that block give up once the VM is stopped
+	for _, input := range []string{`sleep(5000); 1`, `let c = channel(); recv(c)`, `select([channel()])`} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		rt := object.NewRuntime()
+		rt.SetContext(ctx)
+		if err := run(input, rt); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %q to time out, got: %v", input, err)
+		}
+		cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rt := object.NewRuntime()
+	rt.SetContext(ctx)
+	if err := run(`1`, rt); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled context to stop the VM before it starts, got: %v", err)
+	}
+
+	// Scripts within their limits finish
+	rt = object.NewRuntime()
+	rt.SetLimits(object.Limits{Steps: 100000})
+	if err := run(`let sum = func(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(100, 0)`, rt); err != nil {
+		t.Errorf("expected the VM to finish, got: %s", err)
+	}
+}
+
+func TestMemoryLimits(t *testing.T) {
+	hungry := []string{
+		`let grow = func(arr, n) { if (n == 0) { arr } else { grow(push(arr, n), n - 1) } }; grow([], 100000)`,
+		`let double = func(s, n) { if (n == 0) { len(s) } else { double(s + s, n - 1) } }; double("ab", 30)`,
+		`let wrap = func(x, n) { if (n == 0) { x } else { wrap([x, x, x, x], n - 1) } }; wrap(1, 100000)`,
+		`let nest = func(x, n) { if (n == 0) { x } else { nest({"a": x, "b": x}, n - 1) } }; nest(1, 100000)`,
+		`range(1000000000)`,
+		`map([1], func(x) { repeat("x", 1000000000) })`,
+		`recv(spawn range(1000000000)); 1`,
+	}
+
+	for _, input := range hungry {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		rt := object.NewRuntime()
+		rt.SetLimits(object.Limits{Memory: 1 << 20})
+		if err := New(comp.Bytecode(), WithRuntime(rt)).Run(); !errors.Is(err, object.ErrMemoryLimit) {
+			t.Errorf("expected %q to run out of memory, got: %v", input, err)
+		}
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let grow = func(arr, n) { if (n == 0) { arr } else { grow(push(arr, n), n - 1) } }; len(grow([], 100))`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	rt := object.NewRuntime()
+	rt.SetLimits(object.Limits{Memory: 1 << 20})
+	vm := New(comp.Bytecode(), WithRuntime(rt))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("expected the VM to finish, got: %s", err)
+	}
+	testExpectedObject(t, 100, vm.LastPoppedStackElement())
+}
+
+func TestSandbox(t *testing.T) {
+	builtins, err := object.Sandbox{Profile: object.Pure, Deny: []string{"len"}}.Registry()
+	if err != nil {
+		t.Fatalf("sandbox error: %s", err)
+	}
+
+	for _, input := range []string{`print("hi")`, `len([1])`, `map([1], func(x) { read_file("x") })`} {
+		comp := compiler.NewWithBuiltins(builtins)
+		if err := comp.Compile(parse(input)); err == nil || !strings.HasPrefix(err.Error(), "undefined variable ") {
+			t.Errorf("expected %q to fail to compile with an undefined variable, got: %v", input, err)
+		}
+	}
+
+	// What the sandbox leaves out is free to be bound, and what it allows works as usual
+	comp := compiler.NewWithBuiltins(builtins)
+	if err := comp.Compile(parse(`let print = func(x) { x * 2 }; [print(21), math.abs(-1), first(map([1], func(x) { x + 1 }))]`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	rt := object.NewRuntime()
+	rt.SetBuiltins(builtins)
+	vm := New(comp.Bytecode(), WithRuntime(rt))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if res := vm.LastPoppedStackElement(); res.Inspect() != "[42, 1, 2]" {
+		t.Errorf("sandboxed program returned wrong result. Got: %s", res.Inspect())
+	}
+}